@@ -11,26 +11,47 @@ import (
 )
 
 // DestinationOptions указывает точку следования объекта мониторинга.
-// ToDo: заменить на Coordinates
+// Для получения координат во float32 используйте Lat.Float32() и Lon.Float32().
+// ExpectedTime передается в сервис с точностью до минуты в часовом поясе сервиса (API.Location).
 type DestinationOptions struct {
 	Text         string
-	Lon          float32
-	Lat          float32
+	Lon          Coordinate
+	Lat          Coordinate
 	ExpectedTime time.Time
 }
 
-func (do DestinationOptions) addValuesTo(idx int, v *url.Values) error {
+// SetLatLon устанавливает координаты точки назначения из значений float32.
+// Оставлено для совместимости с прежним вариантом DestinationOptions.
+func (do *DestinationOptions) SetLatLon(lat, lon float32) {
+	do.Lat = Coordinate(lat)
+	do.Lon = Coordinate(lon)
+}
+
+// SetCoordinates устанавливает координаты точки назначения.
+func (do *DestinationOptions) SetCoordinates(c Coordinates) {
+	do.Lat, do.Lon = c.Lat, c.Lon
+}
+
+// Coordinates возвращает координаты точки назначения.
+func (do DestinationOptions) Coordinates() Coordinates {
+	return Coordinates{Lat: do.Lat, Lon: do.Lon}
+}
+
+func (do DestinationOptions) addValuesTo(idx int, v *url.Values, loc *time.Location) error {
 	if v == nil {
 		return errors.New("trying to add to nothing")
 	}
 	if do.Text == "" {
 		return errors.New("option Text is not provided")
 	}
+	if err := do.Coordinates().Validate(); err != nil {
+		return err
+	}
 
 	v.Add(fmt.Sprintf("destination[%d][text]", idx), do.Text)
-	v.Add(fmt.Sprintf("destination[%d][coord]", idx), fmt.Sprintf("%.8f,%.8f", do.Lat, do.Lon))
+	v.Add(fmt.Sprintf("destination[%d][coord]", idx), fmt.Sprintf("%s,%s", do.Lat, do.Lon))
 	if !do.ExpectedTime.IsZero() {
-		v.Add(fmt.Sprintf("destination[%d][time]", idx), do.ExpectedTime.Format("02.01.2006 15:04"))
+		v.Add(fmt.Sprintf("destination[%d][time]", idx), formatDestinationTime(do.ExpectedTime, loc))
 	}
 
 	return nil
@@ -40,7 +61,9 @@ func (do DestinationOptions) addValuesTo(idx int, v *url.Values) error {
 type SchedulingOptions struct {
 	weekdays [7]bool
 	FireAt   []time.Time // st Массив времени в расписании. Передается в многомерном массиве,
-	// каждый вложенный элемент является временем для срабатывания расписания в формате hh:mm
+	// каждый вложенный элемент является временем для срабатывания расписания в формате hh:mm.
+	// Время переводится в часовой пояс сервиса (API.Location) с учетом часового пояса значения,
+	// дни недели сдвигаются, если время при переводе переходит через полночь (см. In).
 }
 
 // WeekdayOn добавляет день недели в расписание запросов на определение координат объекта.
@@ -58,10 +81,13 @@ func (s *SchedulingOptions) IsWeekdayOn(day Weekday) bool {
 	return s.weekdays[int(day)]
 }
 
-func (s *SchedulingOptions) addValuesTo(v *url.Values) error {
+func (s *SchedulingOptions) addValuesTo(v *url.Values, loc *time.Location) error {
 	if len(s.FireAt) <= 0 {
 		return errors.New("time to fire scheduling is not set (set FireAt property)")
 	}
+	if len(s.Weekdays()) == 0 {
+		return errors.New("no single weekday to fire scheduling is set")
+	}
 
 	if v == nil {
 		return errors.New("trying to add to nothing")
@@ -74,19 +100,15 @@ func (s *SchedulingOptions) addValuesTo(v *url.Values) error {
 	// sw5 string Включить расписание на пятницу
 	// sw6 string Включить расписание на субботу
 	// sw7 string Включить расписание на воскресенье
-
-	chk := false
-	for idx, val := range s.weekdays {
-		if val {
-			chk = true
-			v.Add(fmt.Sprintf("sw%d", idx+1), "1")
-		}
+	ss, err := s.In(loc)
+	if err != nil {
+		return err
 	}
-	if !chk {
-		return errors.New("no single weekday to fire scheduling is set")
+	for _, d := range ss.Weekdays() {
+		v.Add(fmt.Sprintf("sw%d", int(d)+1), "1")
 	}
 
-	for _, val := range s.FireAt {
+	for _, val := range ss.FireAt {
 		v.Add("st[]", val.Format("15:04"))
 	}
 	return nil
@@ -96,7 +118,7 @@ func (s *SchedulingOptions) addValuesTo(v *url.Values) error {
 type ObjectOptions struct {
 	Title          string               //title - Название объекта
 	Tags           []string             //tags - Список меток через запятую
-	DateOff        time.Time            //dateoff - Дата и время автоматического отключения абонента (dd.mm.yyyy hh:mm:ss)
+	DateOff        time.Time            //dateoff - Дата и время автоматического отключения абонента (dd.mm.yyyy hh:mm:ss в часовом поясе сервиса)
 	Tariff         TariffType           //tariff - Id-тарифного плана
 	PackageProlong bool                 //package_prolong - Автоматически продлевать пакет (при использовании пакетного тарифа)
 	Destinations   []DestinationOptions // destination[] - массив конечных точек маршрута.
@@ -105,7 +127,7 @@ type ObjectOptions struct {
 	CallToDriver   bool                 // autoinform integer Включить услугу автоинформатора.
 }
 
-func (o *ObjectOptions) addValuesTo(v *url.Values) error {
+func (o *ObjectOptions) addValuesTo(v *url.Values, loc *time.Location) error {
 	if v == nil {
 		return errors.New("trying to add to nothing")
 	}
@@ -113,7 +135,7 @@ func (o *ObjectOptions) addValuesTo(v *url.Values) error {
 		v.Add("title", o.Title)
 	}
 	if !o.DateOff.IsZero() {
-		v.Add("dateoff", o.DateOff.Format("02.01.2006 15:04:05"))
+		v.Add("dateoff", o.DateOff.In(serviceLocation(loc)).Format("02.01.2006 15:04:05"))
 	}
 	if o.Tariff != "" {
 		v.Add("tariff", string(o.Tariff))
@@ -135,14 +157,14 @@ func (o *ObjectOptions) addValuesTo(v *url.Values) error {
 	}
 
 	if o.Schedules != nil {
-		err := o.Schedules.addValuesTo(v)
+		err := o.Schedules.addValuesTo(v, loc)
 		if err != nil {
 			return err
 		}
 	}
 
 	for key, val := range o.Destinations {
-		err := val.addValuesTo(key, v)
+		err := val.addValuesTo(key, v, loc)
 		if err != nil {
 			return err
 		}
@@ -2,43 +2,102 @@ package movizor
 
 import (
 	"net/url"
+	"reflect"
 	"testing"
 	"time"
 )
 
-//func TestDestinationOptions_addValuesTo(t *testing.T) {
-//	type fields struct {
-//		Text         string
-//		Lon          float32
-//		Lat          float32
-//		ExpectedTime time.Time
-//	}
-//	type args struct {
-//		idx int
-//		v   *url.Values
-//	}
-//	tests := []struct {
-//		name    string
-//		fields  fields
-//		args    args
-//		wantErr bool
-//	}{
-//		// TODO: Add test cases.
-//	}
-//	for _, tt := range tests {
-//		t.Run(tt.name, func(t *testing.T) {
-//			do := DestinationOptions{
-//				Text:         tt.fields.Text,
-//				Lon:          tt.fields.Lon,
-//				Lat:          tt.fields.Lat,
-//				ExpectedTime: tt.fields.ExpectedTime,
-//			}
-//			if err := do.addValuesTo(tt.args.idx, tt.args.v); (err != nil) != tt.wantErr {
-//				t.Errorf("DestinationOptions.addValuesTo() error = %v, wantErr %v", err, tt.wantErr)
-//			}
-//		})
-//	}
-//}
+func TestDestinationOptions_addValuesTo(t *testing.T) {
+	type fields struct {
+		Text         string
+		Coordinates  Coordinates
+		ExpectedTime time.Time
+	}
+	type args struct {
+		idx int
+		v   *url.Values
+	}
+	tests := []struct {
+		name    string
+		fields  fields
+		args    args
+		want    url.Values
+		wantErr bool
+	}{
+		{
+			name: "good",
+			fields: fields{
+				Text:        "Москва",
+				Coordinates: Coordinates{Lat: 55.79863118, Lon: 37.58203912},
+			},
+			args: args{
+				idx: 0,
+				v:   &url.Values{},
+			},
+			want: url.Values{
+				"destination[0][text]":  {"Москва"},
+				"destination[0][coord]": {"55.79863118,37.58203912"},
+			},
+			wantErr: false,
+		},
+		{
+			name: "text is not set",
+			fields: fields{
+				Coordinates: Coordinates{Lat: 55.79863118, Lon: 37.58203912},
+			},
+			args: args{
+				idx: 0,
+				v:   &url.Values{},
+			},
+			want:    url.Values{},
+			wantErr: true,
+		},
+		{
+			name: "latitude out of range",
+			fields: fields{
+				Text:        "Москва",
+				Coordinates: Coordinates{Lat: 200, Lon: 37.58203912},
+			},
+			args: args{
+				idx: 0,
+				v:   &url.Values{},
+			},
+			want:    url.Values{},
+			wantErr: true,
+		},
+		{
+			name: "longitude out of range",
+			fields: fields{
+				Text:        "Москва",
+				Coordinates: Coordinates{Lat: 55.79863118, Lon: -181},
+			},
+			args: args{
+				idx: 0,
+				v:   &url.Values{},
+			},
+			want:    url.Values{},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			do := DestinationOptions{
+				Text:         tt.fields.Text,
+				Lat:          tt.fields.Coordinates.Lat,
+				Lon:          tt.fields.Coordinates.Lon,
+				ExpectedTime: tt.fields.ExpectedTime,
+			}
+			err := do.addValuesTo(tt.args.idx, tt.args.v, nil)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("DestinationOptions.addValuesTo() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(*tt.args.v, tt.want) {
+				t.Errorf("DestinationOptions.addValuesTo() = %v, want %v", *tt.args.v, tt.want)
+			}
+		})
+	}
+}
 
 func TestSchedulingOptions_WeekdayOn(t *testing.T) {
 	type fields struct {
@@ -377,7 +436,7 @@ func TestSchedulingOptions_addValuesTo(t *testing.T) {
 				weekdays: tt.fields.weekdays,
 				FireAt:   tt.fields.FireAt,
 			}
-			if err := s.addValuesTo(tt.args.v); (err != nil) != tt.wantErr {
+			if err := s.addValuesTo(tt.args.v, nil); (err != nil) != tt.wantErr {
 				t.Errorf("SchedulingOptions.addValuesTo() error = %v, wantErr %v", err, tt.wantErr)
 			}
 		})
@@ -648,3 +707,57 @@ func TestSchedulingOptions_addValuesTo(t *testing.T) {
 //		})
 //	}
 //}
+
+func TestObjectOptions_addValuesTo_location(t *testing.T) {
+	utc := time.Date(2019, 2, 20, 17, 30, 0, 0, time.UTC)
+	oo := &ObjectOptions{
+		DateOff: utc,
+		Schedules: &SchedulingOptions{
+			weekdays: [7]bool{true},
+			FireAt:   []time.Time{time.Date(0, 1, 1, 5, 0, 0, 0, time.UTC)},
+		},
+		Destinations: []DestinationOptions{
+			{Text: "Москва", Lat: 55.79863118, Lon: 37.58203912, ExpectedTime: utc},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		loc      *time.Location
+		dateOff  string
+		fireAt   string
+		destTime string
+	}{
+		{
+			name:     "default moscow",
+			loc:      nil,
+			dateOff:  "20.02.2019 20:30:00",
+			fireAt:   "08:00",
+			destTime: "20.02.2019 20:30",
+		},
+		{
+			name:     "custom location",
+			loc:      time.FixedZone("UTC+7", 7*60*60),
+			dateOff:  "21.02.2019 00:30:00",
+			fireAt:   "12:00",
+			destTime: "21.02.2019 00:30",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := url.Values{}
+			if err := oo.addValuesTo(&v, tt.loc); err != nil {
+				t.Fatalf("ObjectOptions.addValuesTo() error = %v", err)
+			}
+			if got := v.Get("dateoff"); got != tt.dateOff {
+				t.Errorf("dateoff = %s, want %s", got, tt.dateOff)
+			}
+			if got := v.Get("st[]"); got != tt.fireAt {
+				t.Errorf("st[] = %s, want %s", got, tt.fireAt)
+			}
+			if got := v.Get("destination[0][time]"); got != tt.destTime {
+				t.Errorf("destination[0][time] = %s, want %s", got, tt.destTime)
+			}
+		})
+	}
+}
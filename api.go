@@ -14,6 +14,7 @@ import (
 	"net/http"
 	"net/url"
 	"strconv"
+	"time"
 )
 
 // API - это клиент к API Мовизора. Сервиса определения гео-координат на основе GSM сервиса.
@@ -23,6 +24,17 @@ type API struct {
 	Token    string
 	Client   *http.Client
 	IsDebug  bool
+	// Location - часовой пояс, в котором сервис интерпретирует и возвращает время в формате
+	// дд.мм.гггг чч:мм (dateoff, время прибытия, расписание). nil - MoscowLocation.
+	Location *time.Location
+	// Retry - повтор запросов при сетевых ошибках и ответах 5xx. По умолчанию без повторов.
+	Retry RetryPolicy
+	// Logger - вывод запросов и ответов при IsDebug. nil - стандартный логгер пакета log.
+	Logger Logger
+	// UserAgent - заголовок User-Agent запросов. Пустой - заголовок по умолчанию net/http.
+	UserAgent string
+
+	limiter *rateLimiter
 
 	//Buffer          int
 	//shutdownChannel chan interface{}
@@ -31,6 +43,8 @@ type API struct {
 // NewMovizorAPIWithEndpoint создает экземпляр Movizor API.
 // Может быть указан нестандартный адрес сервиса МоВизора
 // на случай, если такой появится.
+// Параметры не проверяются, а HTTP клиент создается без таймаута:
+// для новых клиентов используйте New.
 func NewMovizorAPIWithEndpoint(endp string, prj string, token string) (*API, error) {
 	api := &API{
 		Endpoint: endp,
@@ -38,6 +52,7 @@ func NewMovizorAPIWithEndpoint(endp string, prj string, token string) (*API, err
 		Token:    token,
 		Client:   &http.Client{},
 		IsDebug:  false,
+		Location: MoscowLocation,
 	}
 	return api, nil
 }
@@ -56,7 +71,10 @@ func (api *API) MakeRequest(action string, params url.Values) (APIResponse, erro
 		params = url.Values{}
 	}
 	params.Add("key", api.Token)
-	uri, _ := url.Parse(endpAction)
+	uri, err := url.Parse(endpAction)
+	if err != nil {
+		return APIResponse{}, fmt.Errorf("invalid endpoint %q: %s", api.Endpoint, err)
+	}
 
 	uri.RawQuery = params.Encode()
 	endpAction = uri.String()
@@ -66,7 +84,10 @@ func (api *API) MakeRequest(action string, params url.Values) (APIResponse, erro
 		return APIResponse{}, err
 	}
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-	resp, err := api.Client.Do(req)
+	if api.UserAgent != "" {
+		req.Header.Set("User-Agent", api.UserAgent)
+	}
+	resp, err := api.do(req, IsReadOnlyAction(action))
 	if err != nil {
 		return APIResponse{}, err
 	}
@@ -81,7 +102,7 @@ func (api *API) MakeRequest(action string, params url.Values) (APIResponse, erro
 
 	if apiResp.Result == "success" {
 		if api.IsDebug {
-			log.Printf("INFO: request: %s\nresponse: %s", req.URL, bytes)
+			api.logf("INFO: request: %s\nresponse: %s", req.URL, bytes)
 		}
 		return apiResp, nil
 	}
@@ -89,12 +110,47 @@ func (api *API) MakeRequest(action string, params url.Values) (APIResponse, erro
 	err = fmt.Errorf("movizor API returns error on request: %s - %s",
 		apiResp.ErrorCode, apiResp.ErrorText)
 	if api.IsDebug {
-		log.Printf("ERROR: request: %s\nresponse: %s", req.URL, bytes)
+		api.logf("ERROR: request: %s\nresponse: %s", req.URL, bytes)
 	}
 
 	return apiResp, err
 }
 
+// do выполняет запрос с учетом ограничения частоты запросов и повторов (Retry).
+// readOnly - запрос только получает данные (см. RetryPolicy).
+// Ответ с кодом 5xx после последней попытки возвращается без ошибки.
+func (api *API) do(req *http.Request, readOnly bool) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		api.limiter.wait()
+		resp, err := api.Client.Do(req)
+		if !shouldRetry(readOnly, resp, err) || attempt >= api.Retry.Attempts {
+			return resp, err
+		}
+		if err == nil {
+			resp.Body.Close()
+		}
+		if api.IsDebug {
+			api.logf("WARN: request: %s, attempt %d failed: %v", req.URL.Path, attempt+1, errOrStatus(resp, err))
+		}
+		time.Sleep(api.Retry.delay(attempt))
+	}
+}
+
+func (api *API) logf(format string, v ...interface{}) {
+	if api.Logger != nil {
+		api.Logger.Printf(format, v...)
+		return
+	}
+	log.Printf(format, v...)
+}
+
+func errOrStatus(resp *http.Response, err error) interface{} {
+	if err != nil {
+		return err
+	}
+	return resp.Status
+}
+
 func (api *API) decodeAPIResponse(responseBody io.Reader, resp *APIResponse) (_ []byte, err error) {
 	if !api.IsDebug {
 		dec := json.NewDecoder(responseBody)
@@ -146,7 +202,7 @@ func (api *API) AddObjectToSlave(o Object, oo *ObjectOptions, slaveID uint64) (A
 	}
 
 	if oo != nil {
-		if err := oo.addValuesTo(&v); err != nil {
+		if err := oo.addValuesTo(&v, api.Location); err != nil {
 			return APIResponse{}, err
 		}
 	}
@@ -181,6 +237,7 @@ func (api *API) GetObjectInfo(o Object) (ObjectInfo, error) {
 	if err != nil {
 		return ObjectInfo{}, err
 	}
+	oi.localize(api.Location)
 
 	return oi, nil
 }
@@ -202,7 +259,7 @@ func (api *API) EditObjectWithActivate(o Object, oo *ObjectOptions, activate boo
 	}
 
 	if oo != nil {
-		err := oo.addValuesTo(&v)
+		err := oo.addValuesTo(&v, api.Location)
 		if err != nil {
 			return APIResponse{}, err
 		}
@@ -0,0 +1,513 @@
+package movizor
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultPackageDays - срок пакета TariffOneMonth в сутках для расчета суточной стоимости.
+const DefaultPackageDays = 30
+
+// ErrBudgetExceeded возвращается BalanceGuard, если вызов превысит бюджет.
+var ErrBudgetExceeded = errors.New("budget exceeded")
+
+// ForecastOptions предоставляет допущения прогноза расходов.
+type ForecastOptions struct {
+	// ManualRequestsPerDay - ожидаемое количество ручных запросов (RequestPosition) в сутки
+	// на объект, дополнительно к запросам по расписанию. На тарифах с автоматическими запросами
+	// они заменяются автоматическими до частоты тарифа (см. ForecastObjectSpend).
+	ManualRequestsPerDay float64
+	// PackageDays - срок пакета TariffOneMonth в сутках. 0 - DefaultPackageDays
+	PackageDays int
+}
+
+// ObjectSpend - прогноз суточных расходов на объект.
+type ObjectSpend struct {
+	Object         Object
+	Operator       Operator
+	Tariff         TariffType
+	RequestsPerDay float64 // Платные и бесплатные запросы местоположения в сутки
+	Subscription   float64 // Абонентская плата в сутки
+	Requests       float64 // Стоимость запросов в сутки
+	Daily          float64 // Subscription + Requests
+}
+
+// tariffRequestsPerDay возвращает количество автоматических запросов в сутки по тарифу.
+func tariffRequestsPerDay(tt TariffType) float64 {
+	if d := tt.Interval(); d > 0 {
+		return float64(24*time.Hour) / float64(d)
+	}
+	return 0
+}
+
+// scheduleRequestsPerDay возвращает среднее количество запросов в сутки по расписанию.
+func scheduleRequestsPerDay(s *SchedulingOptions) float64 {
+	if s == nil {
+		return 0
+	}
+	return float64(len(s.minutes())*len(s.Weekdays())) / 7
+}
+
+// ForecastObjectSpend прогнозирует суточные расходы на объект с тарифом tt и расписанием s
+// у оператора op по тарифам из баланса b. Абонентская плата пакета TariffOneMonth делится
+// на PackageDays. Запросы считаются по расписанию и большему из количеств автоматических
+// запросов по тарифу и ManualRequestsPerDay: местоположение, которое тариф запрашивает
+// автоматически, не нужно запрашивать вручную.
+func ForecastObjectSpend(b Balance, op Operator, tt TariffType, s *SchedulingOptions, opts ForecastOptions) (ObjectSpend, error) {
+	tt = tt.Normalize()
+	t, err := b.Tariff(op, tt)
+	if err != nil {
+		return ObjectSpend{}, err
+	}
+	days := opts.PackageDays
+	if days <= 0 {
+		days = DefaultPackageDays
+	}
+
+	os := ObjectSpend{Operator: op, Tariff: tt, Subscription: t.AbonentPayment}
+	if tt == TariffOneMonth {
+		os.Subscription = t.AbonentPayment / float64(days)
+	}
+	os.RequestsPerDay = math.Max(tariffRequestsPerDay(tt), opts.ManualRequestsPerDay) + scheduleRequestsPerDay(s)
+	os.Requests = os.RequestsPerDay * t.RequestCost
+	os.Daily = os.Subscription + os.Requests
+	return os, nil
+}
+
+// forecastTariff возвращает тариф, по которому объект будет тарифицироваться
+// со следующих суток.
+func forecastTariff(oi ObjectInfo) TariffType {
+	if oi.TariffNew != nil && *oi.TariffNew != "" {
+		return *oi.TariffNew
+	}
+	return oi.Tariff
+}
+
+// SpendForecast - прогноз расходов по всем объектам.
+type SpendForecast struct {
+	Objects     []ObjectSpend   // По убыванию суточных расходов
+	Daily       float64         // Суточные расходы всех объектов
+	Available   float64         // Balance + Credit
+	DaysLeft    float64         // Суток до исчерпания средств, +Inf при нулевых расходах
+	ExhaustedAt time.Time       // Ожидаемое время исчерпания средств, нулевое при нулевых расходах
+	Skipped     []SkippedObject // Объекты, пропущенные BalanceGuard из-за ошибок получения данных
+}
+
+// SkippedObject - объект, не вошедший в прогноз из-за ошибки получения его данных.
+type SkippedObject struct {
+	Object Object
+	Err    error
+}
+
+// NewSpendForecast суммирует прогнозы по объектам и оценивает, на сколько суток
+// хватит средств на балансе с учетом кредита.
+func NewSpendForecast(b Balance, objects []ObjectSpend, now time.Time) SpendForecast {
+	f := SpendForecast{Objects: make([]ObjectSpend, len(objects)), Available: b.Balance + b.Credit}
+	copy(f.Objects, objects)
+	sort.SliceStable(f.Objects, func(i, j int) bool { return f.Objects[i].Daily > f.Objects[j].Daily })
+	for _, os := range f.Objects {
+		f.Daily += os.Daily
+	}
+
+	switch {
+	case f.Daily <= 0:
+		f.DaysLeft = math.Inf(1)
+	case f.Available <= 0:
+		f.ExhaustedAt = now
+	default:
+		f.DaysLeft = f.Available / f.Daily
+		f.ExhaustedAt = now.Add(time.Duration(f.DaysLeft * float64(24*time.Hour)))
+	}
+	return f
+}
+
+// ForecastSpend получает баланс, объекты в статусе StatusOk, их тарифы, расписания
+// и операторов и прогнозирует расходы. Объекты в остальных статусах не тарифицируются.
+// Если данные какого-либо объекта получить не удалось, возвращается ошибка.
+func (api *API) ForecastSpend(opts ForecastOptions) (SpendForecast, error) {
+	s, err := api.forecastSpend(opts)
+	if err != nil {
+		return SpendForecast{}, err
+	}
+	if len(s.forecast.Skipped) > 0 {
+		so := s.forecast.Skipped[0]
+		return SpendForecast{}, fmt.Errorf("%s: %s", so.Object, so.Err)
+	}
+	return s.forecast, nil
+}
+
+// spendSnapshot - прогноз расходов вместе с данными, по которым он построен.
+type spendSnapshot struct {
+	balance  Balance
+	forecast SpendForecast
+	tariffs  map[string]TariffType // Текущий тариф (ObjectInfo.Tariff) по номеру объекта
+}
+
+// forecastSpend прогнозирует расходы. Объекты, данные которых получить не удалось,
+// не входят в прогноз и перечисляются в SpendForecast.Skipped.
+func (api *API) forecastSpend(opts ForecastOptions) (spendSnapshot, error) {
+	b, err := api.GetBalance()
+	if err != nil {
+		return spendSnapshot{}, err
+	}
+	objects, err := api.GetObjects()
+	if err != nil {
+		return spendSnapshot{}, err
+	}
+
+	var active []Object
+	for _, st := range objects {
+		if st.Status == StatusOk {
+			active = append(active, st.Phone)
+		}
+	}
+	spends := make([]ObjectSpend, len(active))
+	tariffs := make([]TariffType, len(active))
+	errs := parallel(len(active), DefaultSyncConcurrency, func(i int) error {
+		oi, err := api.GetObjectInfo(active[i])
+		if err != nil {
+			return err
+		}
+		op, err := api.GetOperatorInfo(active[i])
+		if err != nil {
+			return err
+		}
+		tariffs[i] = oi.Tariff
+		spends[i], err = ForecastObjectSpend(b, op.Operator, forecastTariff(oi), oi.Schedule, opts)
+		spends[i].Object = active[i]
+		return err
+	})
+
+	s := spendSnapshot{balance: b, tariffs: make(map[string]TariffType, len(active))}
+	var forecasted []ObjectSpend
+	var skipped []SkippedObject
+	for i, err := range errs {
+		if err != nil {
+			skipped = append(skipped, SkippedObject{Object: active[i], Err: err})
+			continue
+		}
+		forecasted = append(forecasted, spends[i])
+		s.tariffs[active[i].String()] = tariffs[i]
+	}
+	s.forecast = NewSpendForecast(b, forecasted, time.Now())
+	s.forecast.Skipped = skipped
+	return s, nil
+}
+
+// AlertKind представляет собой вид предупреждения о балансе.
+type AlertKind string
+
+const (
+	AlertLowBalance AlertKind = "low_balance" // Средств меньше LowBalance
+	AlertLowDays    AlertKind = "low_days"    // Средств хватит меньше чем на LowDays суток
+	AlertRefused    AlertKind = "refused"     // Вызов отклонен из-за превышения бюджета
+	AlertSkipped    AlertKind = "skipped"     // Объект не вошел в прогноз из-за ошибки получения данных
+)
+
+// BalanceAlert - предупреждение о балансе.
+type BalanceAlert struct {
+	Kind      AlertKind
+	Available float64 // Balance + Credit с учетом расходов с последнего обновления баланса
+	DaysLeft  float64
+	Object    Object // Объект отклоненного вызова для AlertRefused, пропущенный объект для AlertSkipped
+	Message   string
+}
+
+// BalanceGuardOptions предоставляет настройки BalanceGuard.
+type BalanceGuardOptions struct {
+	ForecastOptions
+	// Enforce - отклонять RequestPosition и AddObject с ErrBudgetExceeded, если после вызова
+	// средств останется меньше MinBalance или прогноз суточных расходов превысит DailyBudget.
+	// Без Enforce превышение только вызывает предупреждение AlertRefused.
+	Enforce     bool
+	MinBalance  float64 // Неснижаемый остаток средств (Balance + Credit)
+	DailyBudget float64 // Максимальный прогноз суточных расходов для AddObject. 0 - без ограничения
+	LowBalance  float64 // Порог предупреждения AlertLowBalance. 0 - без предупреждения
+	LowDays     float64 // Порог предупреждения AlertLowDays в сутках. 0 - без предупреждения
+	// RefreshInterval - как часто обновлять прогноз (баланс, объекты, тарифы). 0 - каждые 5 минут
+	RefreshInterval time.Duration
+	// OnAlert вызывается при каждом предупреждении. Может быть nil.
+	OnAlert func(BalanceAlert)
+}
+
+// BalanceGuard следит за балансом: прогнозирует расходы, предупреждает о низком балансе
+// и при Enforce отклоняет запросы местоположения и подключение объектов сверх бюджета.
+// Объекты, данные которых не удалось получить при обновлении прогноза, пропускаются
+// с предупреждением AlertSkipped.
+type BalanceGuard struct {
+	api  *API
+	opts BalanceGuardOptions
+	now  func() time.Time
+
+	mu         sync.Mutex
+	forecast   SpendForecast
+	balance    Balance
+	operators  map[string]Operator
+	tariffs    map[string]TariffType // Текущие тарифы объектов
+	refreshed  time.Time
+	generation int     // Номер обновления прогноза
+	spent      float64 // Расходы через BalanceGuard с последнего обновления
+}
+
+// NewBalanceGuard создает BalanceGuard для api.
+func NewBalanceGuard(api *API, opts BalanceGuardOptions) *BalanceGuard {
+	if opts.RefreshInterval <= 0 {
+		opts.RefreshInterval = 5 * time.Minute
+	}
+	return &BalanceGuard{api: api, opts: opts, now: time.Now,
+		operators: make(map[string]Operator), tariffs: make(map[string]TariffType)}
+}
+
+// Check обновляет прогноз расходов и возвращает его вместе с предупреждениями
+// о низком балансе. Предупреждения также передаются в OnAlert.
+func (g *BalanceGuard) Check() (SpendForecast, []BalanceAlert, error) {
+	if err := g.refresh(); err != nil {
+		return SpendForecast{}, nil, err
+	}
+	g.mu.Lock()
+	f := g.forecast
+	alerts := g.lowBalanceAlerts()
+	g.mu.Unlock()
+	g.alert(alerts...)
+	return f, alerts, nil
+}
+
+// refresh обновляет прогноз. Данные запрашиваются без блокировки g.mu, чтобы медленные
+// запросы к сервису не задерживали остальные вызовы.
+func (g *BalanceGuard) refresh() error {
+	s, err := g.api.forecastSpend(g.opts.ForecastOptions)
+	if err != nil {
+		return err
+	}
+
+	g.mu.Lock()
+	g.setForecast(s)
+	available, daysLeft := g.available(), g.forecast.DaysLeft
+	g.mu.Unlock()
+
+	for _, so := range s.forecast.Skipped {
+		g.alert(BalanceAlert{Kind: AlertSkipped, Available: available, DaysLeft: daysLeft, Object: so.Object,
+			Message: fmt.Sprintf("%s: skipped in forecast: %s", so.Object, so.Err)})
+	}
+	return nil
+}
+
+// setForecast устанавливает прогноз. Вызывается под g.mu.
+func (g *BalanceGuard) setForecast(s spendSnapshot) {
+	g.balance, g.forecast, g.tariffs = s.balance, s.forecast, s.tariffs
+	for _, os := range s.forecast.Objects {
+		g.operators[os.Object.String()] = os.Operator
+	}
+	g.refreshed = g.now()
+	g.generation++
+	g.spent = 0
+}
+
+// ensureFresh обновляет прогноз, если он устарел.
+func (g *BalanceGuard) ensureFresh() error {
+	g.mu.Lock()
+	fresh := !g.refreshed.IsZero() && g.now().Sub(g.refreshed) < g.opts.RefreshInterval
+	g.mu.Unlock()
+	if fresh {
+		return nil
+	}
+	return g.refresh()
+}
+
+func (g *BalanceGuard) available() float64 {
+	return g.forecast.Available - g.spent
+}
+
+func (g *BalanceGuard) lowBalanceAlerts() []BalanceAlert {
+	var alerts []BalanceAlert
+	available := g.available()
+	daysLeft := g.forecast.DaysLeft
+	if g.forecast.Daily > 0 {
+		daysLeft = math.Max(available, 0) / g.forecast.Daily
+	}
+	if g.opts.LowBalance > 0 && available < g.opts.LowBalance {
+		alerts = append(alerts, BalanceAlert{Kind: AlertLowBalance, Available: available, DaysLeft: daysLeft,
+			Message: fmt.Sprintf("balance %.2f is below %.2f", available, g.opts.LowBalance)})
+	}
+	if g.opts.LowDays > 0 && daysLeft < g.opts.LowDays {
+		alerts = append(alerts, BalanceAlert{Kind: AlertLowDays, Available: available, DaysLeft: daysLeft,
+			Message: fmt.Sprintf("balance %.2f is enough for %.1f days at %.2f per day", available, daysLeft, g.forecast.Daily)})
+	}
+	return alerts
+}
+
+func (g *BalanceGuard) alert(alerts ...BalanceAlert) {
+	if g.opts.OnAlert == nil {
+		return
+	}
+	for _, a := range alerts {
+		g.opts.OnAlert(a)
+	}
+}
+
+// admit проверяет вызов стоимостью cost (и с прогнозом суточных расходов daily для AddObject).
+// Возвращает предупреждение AlertRefused, если вызов превышает бюджет. Вызывается под g.mu.
+func (g *BalanceGuard) admit(o Object, cost, daily float64) (*BalanceAlert, error) {
+	var reason string
+	switch {
+	case g.available()-cost < g.opts.MinBalance:
+		reason = fmt.Sprintf("balance %.2f minus cost %.2f is below minimum %.2f", g.available(), cost, g.opts.MinBalance)
+	case daily > 0 && g.opts.DailyBudget > 0 && g.forecast.Daily+daily > g.opts.DailyBudget:
+		reason = fmt.Sprintf("daily spend %.2f plus %.2f exceeds budget %.2f", g.forecast.Daily, daily, g.opts.DailyBudget)
+	default:
+		return nil, nil
+	}
+
+	refused := &BalanceAlert{Kind: AlertRefused, Available: g.available(), DaysLeft: g.forecast.DaysLeft, Object: o,
+		Message: fmt.Sprintf("%s: %s", o, reason)}
+	if g.opts.Enforce {
+		return refused, fmt.Errorf("%s: %s: %s", o, ErrBudgetExceeded, reason)
+	}
+	return refused, nil
+}
+
+// reserve проверяет вызов стоимостью cost (и с прогнозом суточных расходов daily для AddObject)
+// и резервирует cost до ответа сервиса, чтобы ее учитывали параллельные вызовы.
+// Возвращает номер обновления прогноза для settle.
+func (g *BalanceGuard) reserve(o Object, cost, daily float64) (int, error) {
+	g.mu.Lock()
+	refused, err := g.admit(o, cost, daily)
+	if err == nil {
+		g.spent += cost
+	}
+	generation := g.generation
+	g.mu.Unlock()
+
+	if refused != nil {
+		g.alert(*refused)
+	}
+	return generation, err
+}
+
+// settle завершает вызов, стоимость cost которого зарезервировал reserve. При ошибке вызова
+// резерв снимается, при успехе вызывается update (может быть nil). Если прогноз с момента
+// reserve обновился, он уже учитывает результат вызова, и ничего не меняется.
+func (g *BalanceGuard) settle(generation int, cost float64, err error, update func()) {
+	g.mu.Lock()
+	var alerts []BalanceAlert
+	if g.generation == generation {
+		if err != nil {
+			g.spent -= cost
+		} else if update != nil {
+			update()
+		}
+	}
+	if err == nil {
+		alerts = g.lowBalanceAlerts()
+	}
+	g.mu.Unlock()
+	g.alert(alerts...)
+}
+
+// operator возвращает оператора объекта из прогноза или через GetOperatorInfo.
+func (g *BalanceGuard) operator(o Object) (Operator, error) {
+	g.mu.Lock()
+	op, ok := g.operators[o.String()]
+	g.mu.Unlock()
+	if ok {
+		return op, nil
+	}
+
+	oi, err := g.api.GetOperatorInfo(o)
+	if err != nil {
+		return "", err
+	}
+	g.mu.Lock()
+	g.operators[o.String()] = oi.Operator
+	g.mu.Unlock()
+	return oi.Operator, nil
+}
+
+// tariff возвращает текущий тариф объекта из прогноза или через GetObjectInfo,
+// если объекта нет в прогнозе.
+func (g *BalanceGuard) tariff(o Object) (TariffType, error) {
+	g.mu.Lock()
+	tt, ok := g.tariffs[o.String()]
+	g.mu.Unlock()
+	if ok {
+		return tt, nil
+	}
+
+	oi, err := g.api.GetObjectInfo(o)
+	if err != nil {
+		return "", err
+	}
+	g.mu.Lock()
+	g.tariffs[o.String()] = oi.Tariff
+	g.mu.Unlock()
+	return oi.Tariff, nil
+}
+
+// RequestPosition запрашивает местоположение объекта, если стоимость запроса
+// по его текущему тарифу не превышает бюджет.
+func (g *BalanceGuard) RequestPosition(o Object) (PositionRequest, error) {
+	if err := g.ensureFresh(); err != nil {
+		return PositionRequest{}, err
+	}
+	op, err := g.operator(o)
+	if err != nil {
+		return PositionRequest{}, err
+	}
+	tt, err := g.tariff(o)
+	if err != nil {
+		return PositionRequest{}, err
+	}
+	g.mu.Lock()
+	t, err := g.balance.Tariff(op, tt)
+	g.mu.Unlock()
+	if err != nil {
+		return PositionRequest{}, err
+	}
+
+	generation, err := g.reserve(o, t.RequestCost, 0)
+	if err != nil {
+		return PositionRequest{}, err
+	}
+	pr, err := g.api.RequestPosition(o)
+	g.settle(generation, t.RequestCost, err, nil)
+	return pr, err
+}
+
+// AddObject подключает объект, если абонентская плата за сутки не снизит остаток
+// ниже MinBalance, а прогноз суточных расходов с новым объектом не превысит DailyBudget.
+// Тариф объекта обязателен: по нему считается стоимость.
+func (g *BalanceGuard) AddObject(o Object, oo *ObjectOptions) (APIResponse, error) {
+	if oo == nil || oo.Tariff == "" {
+		return APIResponse{}, errors.New("tariff is required to check the budget")
+	}
+	if err := g.ensureFresh(); err != nil {
+		return APIResponse{}, err
+	}
+	op, err := g.operator(o)
+	if err != nil {
+		return APIResponse{}, err
+	}
+	g.mu.Lock()
+	os, err := ForecastObjectSpend(g.balance, op, oo.Tariff, oo.Schedules, g.opts.ForecastOptions)
+	g.mu.Unlock()
+	if err != nil {
+		return APIResponse{}, err
+	}
+	os.Object = o
+
+	generation, err := g.reserve(o, os.Subscription, os.Daily)
+	if err != nil {
+		return APIResponse{}, err
+	}
+	resp, err := g.api.AddObject(o, oo)
+	g.settle(generation, os.Subscription, err, func() {
+		g.forecast.Objects = append(g.forecast.Objects, os)
+		g.forecast.Daily += os.Daily
+		g.tariffs[o.String()] = os.Tariff
+	})
+	return resp, err
+}
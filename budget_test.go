@@ -0,0 +1,256 @@
+package movizor
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func loadTestBalance(t *testing.T) Balance {
+	t.Helper()
+	d, err := ioutil.ReadFile(filepath.Join(dataPath, "balance.json"))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	var b Balance
+	if err := json.Unmarshal(d, &b); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	return b
+}
+
+func TestForecastObjectSpend(t *testing.T) {
+	b := loadTestBalance(t)
+	tests := []struct {
+		name         string
+		op           Operator
+		tt           TariffType
+		schedule     string
+		opts         ForecastOptions
+		wantRequests float64
+		wantDaily    float64
+		wantErr      bool
+	}{
+		{name: "every 30", op: OperatorMTS, tt: TariffEvery30, wantRequests: 48, wantDaily: 135},
+		{name: "manual", op: OperatorMTS, tt: TariffManual, wantDaily: 4},
+		{name: "manual alias", op: OperatorMTS, tt: "manual", wantDaily: 4},
+		{name: "manual with schedule", op: OperatorMTS, tt: TariffManual, schedule: "Mon-Fri 08:00-20:00 every 4h",
+			wantRequests: 4 * 5 / 7.0, wantDaily: 4 + 3*4*5/7.0},
+		{name: "manual requests", op: OperatorMegafon, tt: TariffManual, opts: ForecastOptions{ManualRequestsPerDay: 2},
+			wantRequests: 2, wantDaily: 3 + 2*3.5},
+		{name: "package", op: OperatorMTS, tt: TariffOneMonth, wantDaily: 3},
+		{name: "package days", op: OperatorMTS, tt: TariffOneMonth, opts: ForecastOptions{PackageDays: 31}, wantDaily: 90 / 31.0},
+		{name: "no tariff", op: OperatorMTS, tt: TariffOnline, wantErr: true},
+		{name: "no operator", op: "yota", tt: TariffManual, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var s *SchedulingOptions
+			if tt.schedule != "" {
+				s = MustParseSchedule(tt.schedule, nil)
+			}
+			got, err := ForecastObjectSpend(b, tt.op, tt.tt, s, tt.opts)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ForecastObjectSpend() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if math.Abs(got.RequestsPerDay-tt.wantRequests) > 1e-9 || math.Abs(got.Daily-tt.wantDaily) > 1e-9 {
+				t.Errorf("ForecastObjectSpend() = %+v, want %g requests, %g daily", got, tt.wantRequests, tt.wantDaily)
+			}
+		})
+	}
+}
+
+func TestForecastObjectSpend_intervalRequestCost(t *testing.T) {
+	b := Balance{OperatorTariffs: map[Operator]map[TariffType]Tariff{
+		OperatorMTS: {
+			TariffManual:  {AbonentPayment: 4, RequestCost: 3},
+			TariffEvery60: {AbonentPayment: 10, RequestCost: 0.5},
+		},
+	}}
+	tests := []struct {
+		name         string
+		manual       float64
+		wantRequests float64
+	}{
+		{"no manual requests", 0, 24},
+		// Наблюдаемые запросы уже включают автоматические запросы тарифа.
+		{"covered by tariff", 24, 24},
+		{"more than tariff", 30, 30},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ForecastObjectSpend(b, OperatorMTS, TariffEvery60, nil, ForecastOptions{ManualRequestsPerDay: tt.manual})
+			if err != nil {
+				t.Fatalf("ForecastObjectSpend() error = %v", err)
+			}
+			if got.RequestsPerDay != tt.wantRequests || got.Daily != 10+tt.wantRequests*0.5 {
+				t.Errorf("ForecastObjectSpend() = %+v, want %g requests", got, tt.wantRequests)
+			}
+		})
+	}
+
+	// 24 запроса в сутки: 10 + 24*0.5 за каждые 60 минут против 4 + 24*3 вручную.
+	r, err := RecommendTariff(b, OperatorMTS, TariffEvery60, nil, RecommendOptions{ForecastOptions: ForecastOptions{ManualRequestsPerDay: 24}})
+	if err != nil {
+		t.Fatalf("RecommendTariff() error = %v", err)
+	}
+	if r.Change() || r.CurrentDaily != 22 || r.Savings != 0 {
+		t.Errorf("RecommendTariff() = %+v", r)
+	}
+}
+
+func TestNewSpendForecast(t *testing.T) {
+	now := time.Date(2019, 5, 20, 12, 0, 0, 0, MoscowLocation)
+	b := Balance{Balance: 90, Credit: 10}
+	f := NewSpendForecast(b, []ObjectSpend{{Object: "79210010201", Daily: 5}, {Object: "79210010202", Daily: 20}}, now)
+	if f.Daily != 25 || f.Available != 100 || f.DaysLeft != 4 || !f.ExhaustedAt.Equal(now.Add(96*time.Hour)) {
+		t.Errorf("NewSpendForecast() = %+v", f)
+	}
+	if f.Objects[0].Object != "79210010202" {
+		t.Errorf("NewSpendForecast() objects should be sorted by daily spend: %+v", f.Objects)
+	}
+
+	if f := NewSpendForecast(b, nil, now); !math.IsInf(f.DaysLeft, 1) || !f.ExhaustedAt.IsZero() {
+		t.Errorf("NewSpendForecast() without spend = %+v", f)
+	}
+	if f := NewSpendForecast(Balance{Balance: -5}, []ObjectSpend{{Daily: 5}}, now); f.DaysLeft != 0 || !f.ExhaustedAt.Equal(now) {
+		t.Errorf("NewSpendForecast() with negative balance = %+v", f)
+	}
+}
+
+func TestBalanceGuard(t *testing.T) {
+	var calls sync.Map
+	api := poolTestAPI(t, "main", map[string]string{
+		"balance":      "balance.json",
+		"object_list":  `[{"phone":"79630005272","status":"ok"},{"phone":"79210010203","status":"off"}]`,
+		"object_get":   "object_get1.json",
+		"get_operator": "get_operator.json",
+		"pos_request":  `{"request_id": 42}`,
+		"object_add":   `null`,
+	}, &calls)
+
+	var alerts []BalanceAlert
+	g := NewBalanceGuard(api, BalanceGuardOptions{
+		Enforce:     true,
+		MinBalance:  471,
+		DailyBudget: 320,
+		LowBalance:  500,
+		LowDays:     3,
+		OnAlert:     func(a BalanceAlert) { alerts = append(alerts, a) },
+	})
+
+	f, got, err := g.Check()
+	if err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+	// Единственный подключенный объект со следующих суток на тарифе 15 у МТС: 280 в сутки.
+	if len(f.Objects) != 1 || f.Daily != 280 || f.Available != 476.5 {
+		t.Errorf("Check() forecast = %+v", f)
+	}
+	if len(got) != 2 || got[0].Kind != AlertLowBalance || got[1].Kind != AlertLowDays || len(alerts) != 2 {
+		t.Errorf("Check() alerts = %+v", got)
+	}
+
+	// Текущий тариф manual у МТС: запрос стоит 3.
+	alerts = nil
+	pr, err := g.RequestPosition("79630005272")
+	if err != nil || pr.RequestID != 42 {
+		t.Fatalf("RequestPosition() = %+v, %v", pr, err)
+	}
+	if g.available() != 473.5 {
+		t.Errorf("available() = %g, want 473.5", g.available())
+	}
+	if _, err := g.RequestPosition("79630005272"); err == nil || !strings.Contains(err.Error(), ErrBudgetExceeded.Error()) {
+		t.Errorf("RequestPosition() error = %v, want %v", err, ErrBudgetExceeded)
+	}
+	if n, _ := calls.Load("main/pos_request"); *n.(*int) != 1 {
+		t.Errorf("pos_request calls = %d, want 1", *n.(*int))
+	}
+	// Тариф берется из прогноза без повторного object_get.
+	if n, _ := calls.Load("main/object_get"); *n.(*int) != 1 {
+		t.Errorf("object_get calls = %d, want 1", *n.(*int))
+	}
+	if last := alerts[len(alerts)-1]; last.Kind != AlertRefused || last.Object != "79630005272" {
+		t.Errorf("last alert = %+v", last)
+	}
+
+	g.opts.MinBalance = 0
+	if _, err := g.AddObject("79210010204", &ObjectOptions{Tariff: TariffEvery30}); err == nil {
+		t.Error("AddObject() over daily budget should fail")
+	}
+	if _, err := g.AddObject("79210010204", &ObjectOptions{}); err == nil {
+		t.Error("AddObject() without tariff should fail")
+	}
+	if _, err := g.AddObject("79210010204", &ObjectOptions{Tariff: TariffEvery180}); err != nil {
+		t.Errorf("AddObject() error = %v", err)
+	}
+	if g.forecast.Daily != 316 || g.available() != 437.5 {
+		t.Errorf("after AddObject() daily = %g, available = %g", g.forecast.Daily, g.available())
+	}
+
+	g.opts.Enforce = false
+	if _, err := g.AddObject("79210010205", &ObjectOptions{Tariff: TariffEvery15}); err != nil {
+		t.Errorf("AddObject() without Enforce error = %v", err)
+	}
+}
+
+func TestBalanceGuard_skipped(t *testing.T) {
+	api := poolTestAPI(t, "main", map[string]string{
+		"balance":      "balance.json",
+		"object_list":  `[{"phone":"79630005272","status":"ok"},{"phone":"79210010203","status":"ok"}]`,
+		"object_get":   "object_get1.json",
+		"get_operator": "get_operator.json",
+		"pos_request":  `{"request_id": 42}`,
+	}, nil)
+	var g *BalanceGuard
+	var locked int32
+	transport := api.Client.Transport
+	api.Client.Transport = roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		// Запросы к сервису выполняются без блокировки BalanceGuard.
+		done := make(chan struct{})
+		go func() {
+			g.mu.Lock()
+			g.mu.Unlock()
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			atomic.StoreInt32(&locked, 1)
+		}
+		if strings.HasSuffix(req.URL.Path, "/object_get") && req.URL.Query().Get("phone") == "79210010203" {
+			return nil, errors.New("connection reset")
+		}
+		return transport.RoundTrip(req)
+	})
+
+	var alerts []BalanceAlert
+	g = NewBalanceGuard(api, BalanceGuardOptions{OnAlert: func(a BalanceAlert) { alerts = append(alerts, a) }})
+	if _, err := g.RequestPosition("79630005272"); err != nil {
+		t.Fatalf("RequestPosition() error = %v", err)
+	}
+	if atomic.LoadInt32(&locked) != 0 {
+		t.Error("BalanceGuard holds the lock during requests to the service")
+	}
+	if len(g.forecast.Objects) != 1 || len(g.forecast.Skipped) != 1 || g.forecast.Skipped[0].Object != "79210010203" {
+		t.Errorf("forecast = %+v", g.forecast)
+	}
+	if len(alerts) != 1 || alerts[0].Kind != AlertSkipped || alerts[0].Object != "79210010203" {
+		t.Errorf("alerts = %+v", alerts)
+	}
+
+	if _, err := api.ForecastSpend(ForecastOptions{}); err == nil || !strings.Contains(err.Error(), "79210010203") {
+		t.Errorf("ForecastSpend() error = %v, want failure for 79210010203", err)
+	}
+}
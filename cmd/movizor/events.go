@@ -0,0 +1,254 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/grender/movizor"
+)
+
+func (c *cli) printEvents(events movizor.ObjectEvents) error {
+	return c.print(events, func(w io.Writer) {
+		row(w, "ID", "ВРЕМЯ", "ТЕЛЕФОН", "СОБЫТИЕ")
+		for _, e := range events {
+			row(w, e.EventID, formatTime(e.Timestamp, c.location()), e.Phone, e.Event)
+		}
+	})
+}
+
+func runEventsList(c *cli, args []string) error {
+	fs := c.flags()
+	after := fs.Uint64("after", 0, "выводить события после события с этим ID")
+	limit := fs.Uint64("limit", 0, "количество событий")
+	if _, err := c.parse(fs, args, 0); err != nil {
+		return err
+	}
+	events, err := c.api.GetEvents(movizor.ObjectEventsOptions{RequestLimit: *limit, AfterEventID: *after})
+	if err != nil {
+		return err
+	}
+	return c.printEvents(events)
+}
+
+// eventFilter отбирает события по типу, телефону и метке объекта.
+// Пустой фильтр пропускает все события.
+type eventFilter struct {
+	types  map[movizor.EventType]bool
+	phones map[string]bool // Нормализованные номера (Object.String())
+	tag    string
+}
+
+func newEventFilter(types, phones, tag string) (eventFilter, error) {
+	f := eventFilter{tag: strings.TrimSpace(tag)}
+	for _, t := range splitList(types) {
+		if f.types == nil {
+			f.types = make(map[movizor.EventType]bool)
+		}
+		f.types[movizor.EventType(t)] = true
+	}
+	for _, p := range splitList(phones) {
+		phone := movizor.Object(p).String()
+		if phone == "" {
+			return eventFilter{}, fmt.Errorf("invalid format of phone number: %q, should be 79XXXXXXXXX", p)
+		}
+		if f.phones == nil {
+			f.phones = make(map[string]bool)
+		}
+		f.phones[phone] = true
+	}
+	return f, nil
+}
+
+func splitList(s string) []string {
+	var out []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// match проверяет тип и телефон события.
+func (f eventFilter) match(e movizor.ObjectEvent) bool {
+	if f.types != nil && !f.types[e.Event] {
+		return false
+	}
+	return f.phones == nil || f.phones[e.Phone.String()]
+}
+
+// matchObject проверяет метку объекта. oi равен nil, если информация об объекте недоступна.
+func (f eventFilter) matchObject(oi *movizor.ObjectInfo) bool {
+	if f.tag == "" {
+		return true
+	}
+	if oi == nil {
+		return false
+	}
+	for _, t := range oi.Tags {
+		if t == f.tag {
+			return true
+		}
+	}
+	return false
+}
+
+// objectCache кэширует GetObjectInfo, чтобы не запрашивать объект на каждое событие.
+// Неудачные запросы (например, объект уже удален) тоже кэшируются.
+type objectCache struct {
+	get     func(o movizor.Object) (movizor.ObjectInfo, error)
+	ttl     time.Duration
+	now     func() time.Time
+	entries map[string]objectCacheEntry
+}
+
+type objectCacheEntry struct {
+	info    *movizor.ObjectInfo
+	expires time.Time
+}
+
+func newObjectCache(get func(o movizor.Object) (movizor.ObjectInfo, error), ttl time.Duration) *objectCache {
+	return &objectCache{get: get, ttl: ttl, now: time.Now, entries: make(map[string]objectCacheEntry)}
+}
+
+// info возвращает информацию об объекте или nil, если она недоступна.
+func (oc *objectCache) info(o movizor.Object) *movizor.ObjectInfo {
+	phone := o.String()
+	if e, ok := oc.entries[phone]; ok && oc.now().Before(e.expires) {
+		return e.info
+	}
+	var info *movizor.ObjectInfo
+	if oi, err := oc.get(o); err == nil {
+		info = &oi
+	}
+	oc.entries[phone] = objectCacheEntry{info: info, expires: oc.now().Add(oc.ttl)}
+	return info
+}
+
+// eventLine - событие с названием и метками объекта для вывода.
+type eventLine struct {
+	EventID   int64             `json:"id"`
+	Timestamp time.Time         `json:"timestamp"`
+	Phone     movizor.Object    `json:"phone"`
+	Event     movizor.EventType `json:"type"`
+	Title     string            `json:"title,omitempty"`
+	Tags      []string          `json:"tags,omitempty"`
+}
+
+// tailer выводит события, прошедшие фильтр, и запоминает ID последнего полученного события.
+type tailer struct {
+	c      *cli
+	filter eventFilter
+	cache  *objectCache
+	lastID int64
+}
+
+// lines сортирует события по ID, обновляет lastID и возвращает прошедшие фильтр события.
+func (t *tailer) lines(events movizor.ObjectEvents) []eventLine {
+	sort.Slice(events, func(i, j int) bool { return events[i].EventID < events[j].EventID })
+	var out []eventLine
+	for _, e := range events {
+		if e.EventID > t.lastID {
+			t.lastID = e.EventID
+		}
+		if !t.filter.match(e) {
+			continue
+		}
+		oi := t.cache.info(e.Phone)
+		if !t.filter.matchObject(oi) {
+			continue
+		}
+		l := eventLine{EventID: e.EventID, Timestamp: e.Timestamp.Time(), Phone: e.Phone, Event: e.Event}
+		if oi != nil {
+			l.Title, l.Tags = oi.Title, oi.Tags
+		}
+		out = append(out, l)
+	}
+	return out
+}
+
+// write выводит события построчно: в JSON по объекту на строку (для jq) или текстом.
+func (t *tailer) write(lines []eventLine) error {
+	enc := json.NewEncoder(t.c.out)
+	for _, l := range lines {
+		var err error
+		if t.c.json {
+			err = enc.Encode(l)
+		} else {
+			title := l.Title
+			if title == "" {
+				title = "-"
+			}
+			_, err = fmt.Fprintf(t.c.out, "%s  %-11s  %-15s  %s\n",
+				l.Timestamp.In(t.c.location()).Format("02.01.2006 15:04:05"), l.Phone, l.Event, title)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func runEventsTail(c *cli, args []string) error {
+	fs := c.flags()
+	n := fs.Int("n", 20, "количество последних событий, выводимых при запуске")
+	follow := fs.Bool("f", false, "следить за новыми событиями")
+	interval := fs.Duration("interval", 30*time.Second, "интервал опроса сервиса при -f")
+	types := fs.String("type", "", "типы событий через запятую, например confirm,pos_late")
+	phones := fs.String("phone", "", "телефоны объектов через запятую")
+	tag := fs.String("tag", "", "только объекты с меткой")
+	ttl := fs.Duration("cache", 10*time.Minute, "время кэширования названий и меток объектов")
+	if _, err := c.parse(fs, args, 0); err != nil {
+		return err
+	}
+	if *interval <= 0 {
+		return fmt.Errorf("invalid interval %s", *interval)
+	}
+	filter, err := newEventFilter(*types, *phones, *tag)
+	if err != nil {
+		return err
+	}
+
+	t := &tailer{c: c, filter: filter, cache: newObjectCache(c.api.GetObjectInfo, *ttl)}
+	events, err := c.api.GetEvents(movizor.ObjectEventsOptions{})
+	if err != nil {
+		return err
+	}
+	lines := t.lines(events)
+	if *n >= 0 && len(lines) > *n {
+		lines = lines[len(lines)-*n:]
+	}
+	if err := t.write(lines); err != nil || !*follow {
+		return err
+	}
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(stop)
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return nil
+		case <-ticker.C:
+		}
+
+		// Ошибки сервиса не прерывают слежение: событие будет получено при следующем опросе.
+		events, err := c.api.GetEvents(movizor.ObjectEventsOptions{AfterEventID: uint64(t.lastID)})
+		if err != nil {
+			fmt.Fprintln(c.errOut, "movizor:", err)
+			continue
+		}
+		if err := t.write(t.lines(events)); err != nil {
+			return err
+		}
+	}
+}
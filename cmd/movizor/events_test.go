@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/grender/movizor"
+)
+
+func TestNewEventFilter(t *testing.T) {
+	if _, err := newEventFilter("", "79210010203, 123", ""); err == nil {
+		t.Error("newEventFilter() with invalid phone should fail")
+	}
+
+	f, err := newEventFilter("confirm, pos_late", "+7 921 001-02-03", "")
+	if err != nil {
+		t.Fatalf("newEventFilter() error = %v", err)
+	}
+	tests := []struct {
+		e    movizor.ObjectEvent
+		want bool
+	}{
+		{movizor.ObjectEvent{Phone: "79210010203", Event: movizor.ConfirmEvent}, true},
+		{movizor.ObjectEvent{Phone: "79210010203", Event: movizor.LateEvent}, true},
+		{movizor.ObjectEvent{Phone: "79210010203", Event: movizor.RejectEvent}, false},
+		{movizor.ObjectEvent{Phone: "79210010204", Event: movizor.ConfirmEvent}, false},
+	}
+	for _, tt := range tests {
+		if got := f.match(tt.e); got != tt.want {
+			t.Errorf("match(%s %s) = %v, want %v", tt.e.Phone, tt.e.Event, got, tt.want)
+		}
+	}
+
+	if !(eventFilter{}).match(movizor.ObjectEvent{Phone: "79210010204", Event: movizor.RejectEvent}) {
+		t.Error("empty filter should match any event")
+	}
+}
+
+func TestTailer_lines(t *testing.T) {
+	calls := 0
+	get := func(o movizor.Object) (movizor.ObjectInfo, error) {
+		calls++
+		if o.String() == "79210010205" {
+			return movizor.ObjectInfo{}, errors.New("object not found")
+		}
+		oi := movizor.ObjectInfo{Title: "Машина " + o.String()[9:]}
+		if o.String() == "79210010203" {
+			oi.Tags = []string{"moscow"}
+		}
+		return oi, nil
+	}
+	f, err := newEventFilter("", "", "moscow")
+	if err != nil {
+		t.Fatalf("newEventFilter() error = %v", err)
+	}
+	tr := &tailer{c: &cli{out: &bytes.Buffer{}}, filter: f, cache: newObjectCache(get, time.Hour)}
+
+	events := movizor.ObjectEvents{
+		{EventID: 12, Phone: "79210010203", Event: movizor.LateEvent},
+		{EventID: 10, Phone: "79210010203", Event: movizor.ConfirmEvent},
+		{EventID: 11, Phone: "79210010204", Event: movizor.ConfirmEvent},
+		{EventID: 13, Phone: "79210010205", Event: movizor.OffEvent},
+	}
+	lines := tr.lines(events)
+	if tr.lastID != 13 {
+		t.Errorf("lastID = %d, want 13", tr.lastID)
+	}
+	if len(lines) != 2 || lines[0].EventID != 10 || lines[1].EventID != 12 {
+		t.Fatalf("lines() = %+v", lines)
+	}
+	if lines[0].Title != "Машина 03" {
+		t.Errorf("title = %q", lines[0].Title)
+	}
+	if calls != 3 {
+		t.Errorf("GetObjectInfo calls = %d, want 3 (cached)", calls)
+	}
+
+	tr.c.json = true
+	if err := tr.write(lines[:1]); err != nil {
+		t.Fatalf("write() error = %v", err)
+	}
+	want := `{"id":10,"timestamp":"0001-01-01T00:00:00Z","phone":"79210010203","type":"confirm","title":"Машина 03","tags":["moscow"]}` + "\n"
+	if got := tr.c.out.(*bytes.Buffer).String(); got != want {
+		t.Errorf("write() = %s, want %s", got, want)
+	}
+}
+
+func TestObjectCache_ttl(t *testing.T) {
+	now := time.Date(2019, 5, 20, 10, 0, 0, 0, time.UTC)
+	calls := 0
+	oc := newObjectCache(func(o movizor.Object) (movizor.ObjectInfo, error) {
+		calls++
+		return movizor.ObjectInfo{}, nil
+	}, time.Minute)
+	oc.now = func() time.Time { return now }
+
+	oc.info("79210010203")
+	oc.info("79210010203")
+	now = now.Add(2 * time.Minute)
+	oc.info("79210010203")
+	if calls != 2 {
+		t.Errorf("GetObjectInfo calls = %d, want 2", calls)
+	}
+}
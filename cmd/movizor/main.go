@@ -0,0 +1,273 @@
+// Command movizor - утилита командной строки для API МоВизора.
+//
+// Использование:
+//
+//	movizor [-config файл] [-json] [-debug] <команда> [подкоманда] [флаги] [аргументы]
+//
+// Команды:
+//
+//	balance                                  баланс и тарифы
+//	operator <телефон>                       оператор абонента
+//	objects list|get|add|edit|delete|reactivate|cancel-tariff
+//	pos last|list|request|get|all
+//	events list|tail                         tail -f - слежение за новыми событиями
+//	subs list|add|delete|clear|gc
+//
+// Настройки клиента (проект, ключ API, адрес сервиса, таймаут, повторы и т.д.) берутся
+// из файла конфигурации в формате JSON или YAML (флаг -config, переменная MOVIZOR_CONFIG,
+// по умолчанию ~/.movizor.json) и переменных окружения MOVIZOR_PROJECT, MOVIZOR_TOKEN,
+// MOVIZOR_ENDPOINT и других, см. movizor.Config. Переменные окружения имеют приоритет над файлом.
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/grender/movizor"
+)
+
+// cli содержит общее для всех команд состояние.
+type cli struct {
+	api    *movizor.API
+	json   bool
+	out    io.Writer
+	errOut io.Writer // Вывод ошибок, не прерывающих выполнение команды
+	usage  string    // Строка использования выполняемой команды
+}
+
+// command описывает одну команду утилиты.
+type command struct {
+	usage string
+	run   func(c *cli, args []string) error
+}
+
+var commands = map[string]map[string]command{
+	"balance":  {"": {usage: "balance", run: runBalance}},
+	"operator": {"": {usage: "operator <телефон>", run: runOperator}},
+	"objects": {
+		"list":          {usage: "objects list", run: runObjectsList},
+		"get":           {usage: "objects get <телефон>", run: runObjectsGet},
+		"add":           {usage: "objects add [флаги] <телефон>", run: runObjectsAdd},
+		"edit":          {usage: "objects edit [флаги] [-activate] <телефон>", run: runObjectsEdit},
+		"delete":        {usage: "objects delete <телефон>", run: runObjectAction("delete")},
+		"reactivate":    {usage: "objects reactivate <телефон>", run: runObjectAction("reactivate")},
+		"cancel-tariff": {usage: "objects cancel-tariff <телефон>", run: runObjectAction("cancel-tariff")},
+	},
+	"pos": {
+		"last":    {usage: "pos last <телефон>", run: runPosLast},
+		"list":    {usage: "pos list [-from время] [-to время] [-limit n] [-offset n] <телефон>", run: runPosList},
+		"request": {usage: "pos request <телефон>", run: runPosRequest},
+		"get":     {usage: "pos get <id запроса>", run: runPosGet},
+		"all":     {usage: "pos all", run: runPosAll},
+	},
+	"events": {
+		"list": {usage: "events list [-after id] [-limit n]", run: runEventsList},
+		"tail": {usage: "events tail [-n n] [-f [-interval d]] [-type т1,т2] [-phone т1,т2] [-tag метка]", run: runEventsTail},
+	},
+	"subs": {
+		"list":   {usage: "subs list", run: runSubsList},
+		"add":    {usage: "subs add -event тип (-all | -phones т1,т2) (-sms телефон | -email адрес | -telegram)", run: runSubsAdd},
+		"delete": {usage: "subs delete <id>", run: runSubsDelete},
+		"clear":  {usage: "subs clear [-phone телефон [-event тип]]", run: runSubsClear},
+		"gc":     {usage: "subs gc", run: runSubsGC},
+	},
+}
+
+func main() {
+	if err := run(os.Args[1:], os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, "movizor:", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("movizor", flag.ContinueOnError)
+	configPath := fs.String("config", "", "файл конфигурации JSON или YAML (по умолчанию $MOVIZOR_CONFIG или ~/.movizor.json)")
+	asJSON := fs.Bool("json", false, "вывод в JSON")
+	debug := fs.Bool("debug", false, "выводить запросы и ответы сервиса")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Использование: movizor [-config файл] [-json] [-debug] <команда> ...")
+		fs.PrintDefaults()
+		fmt.Fprintln(fs.Output(), "\nКоманды:")
+		for _, u := range usages() {
+			fmt.Fprintln(fs.Output(), "  "+u)
+		}
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	cmd, cmdArgs, err := findCommand(fs.Args())
+	if err != nil {
+		fs.Usage()
+		return err
+	}
+
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+	if *debug {
+		cfg.Debug = true
+	}
+	api, err := movizor.NewFromConfig(cfg)
+	if err != nil {
+		return err
+	}
+
+	return cmd.run(&cli{api: api, json: *asJSON, out: out, errOut: os.Stderr, usage: cmd.usage}, cmdArgs)
+}
+
+func findCommand(args []string) (command, []string, error) {
+	if len(args) == 0 {
+		return command{}, nil, errors.New("command is not set")
+	}
+	group, ok := commands[args[0]]
+	if !ok {
+		return command{}, nil, fmt.Errorf("unknown command %q", args[0])
+	}
+	if cmd, ok := group[""]; ok {
+		return cmd, args[1:], nil
+	}
+	if len(args) < 2 {
+		return command{}, nil, fmt.Errorf("subcommand of %s is not set", args[0])
+	}
+	cmd, ok := group[args[1]]
+	if !ok {
+		return command{}, nil, fmt.Errorf("unknown command %q", args[0]+" "+args[1])
+	}
+	return cmd, args[2:], nil
+}
+
+func usages() []string {
+	var out []string
+	for _, group := range commands {
+		for _, cmd := range group {
+			out = append(out, cmd.usage)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// loadConfig читает настройки клиента (см. movizor.LoadConfig). Если файл не указан
+// ни флагом, ни переменной MOVIZOR_CONFIG, используется ~/.movizor.json, если он есть.
+func loadConfig(path string) (movizor.Config, error) {
+	if path == "" && os.Getenv(movizor.ConfigFileEnv) == "" {
+		def := filepath.Join(os.Getenv("HOME"), ".movizor.json")
+		if _, err := os.Stat(def); err == nil {
+			path = def
+		}
+	}
+	return movizor.LoadConfig(path)
+}
+
+// print выводит v в JSON или таблицей, которую заполняет table.
+func (c *cli) print(v interface{}, table func(w io.Writer)) error {
+	if c.json {
+		enc := json.NewEncoder(c.out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	}
+
+	tw := tabwriter.NewWriter(c.out, 0, 0, 2, ' ', 0)
+	table(tw)
+	return tw.Flush()
+}
+
+// row выводит строку таблицы с колонками, разделенными табуляцией.
+func row(w io.Writer, cols ...interface{}) {
+	s := make([]string, len(cols))
+	for i, c := range cols {
+		s[i] = fmt.Sprint(c)
+	}
+	fmt.Fprintln(w, strings.Join(s, "\t"))
+}
+
+// location возвращает часовой пояс сервиса, в котором разбирается и выводится время.
+func (c *cli) location() *time.Location {
+	if c.api == nil || c.api.Location == nil {
+		return movizor.MoscowLocation
+	}
+	return c.api.Location
+}
+
+// flags создает набор флагов выполняемой команды.
+func (c *cli) flags() *flag.FlagSet {
+	return flag.NewFlagSet(c.usage, flag.ContinueOnError)
+}
+
+// parse разбирает флаги команды и проверяет количество позиционных аргументов.
+func (c *cli) parse(fs *flag.FlagSet, args []string, n int) ([]string, error) {
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Использование: movizor "+c.usage)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if fs.NArg() != n {
+		fs.Usage()
+		return nil, fmt.Errorf("expected %d argument(s), got %d", n, fs.NArg())
+	}
+	return fs.Args(), nil
+}
+
+func runBalance(c *cli, args []string) error {
+	if _, err := c.parse(c.flags(), args, 0); err != nil {
+		return err
+	}
+	b, err := c.api.GetBalance()
+	if err != nil {
+		return err
+	}
+	return c.print(b, func(w io.Writer) {
+		row(w, "Баланс", b.Balance)
+		row(w, "Кредит", b.Credit)
+		row(w, "Договор", b.ContractType)
+		row(w)
+		row(w, "ОПЕРАТОР", "ТАРИФ", "НАЗВАНИЕ", "АБОН. ПЛАТА", "ЗАПРОС")
+		var ops []string
+		for op := range b.OperatorTariffs {
+			ops = append(ops, string(op))
+		}
+		sort.Strings(ops)
+		for _, op := range ops {
+			tariffs := b.OperatorTariffs[movizor.Operator(op)]
+			var types []string
+			for t := range tariffs {
+				types = append(types, string(t))
+			}
+			sort.Strings(types)
+			for _, t := range types {
+				tr := tariffs[movizor.TariffType(t)]
+				row(w, op, t, tr.TariffTitle, tr.AbonentPayment, tr.RequestCost)
+			}
+		}
+	})
+}
+
+func runOperator(c *cli, args []string) error {
+	pos, err := c.parse(c.flags(), args, 1)
+	if err != nil {
+		return err
+	}
+	oi, err := c.api.GetOperatorInfo(movizor.Object(pos[0]))
+	if err != nil {
+		return err
+	}
+	return c.print(oi, func(w io.Writer) {
+		row(w, "Оператор", oi.Operator)
+		row(w, "Название", oi.Title)
+		row(w, "Регион", oi.Region)
+	})
+}
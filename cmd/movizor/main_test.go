@@ -0,0 +1,82 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/grender/movizor"
+)
+
+func TestFindCommand(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		usage    string
+		wantArgs int
+		wantErr  bool
+	}{
+		{name: "top level", args: []string{"balance"}, usage: "balance"},
+		{name: "top level with args", args: []string{"operator", "79210010203"}, usage: "operator <телефон>", wantArgs: 1},
+		{name: "subcommand", args: []string{"objects", "get", "79210010203"}, usage: "objects get <телефон>", wantArgs: 1},
+		{name: "empty", args: nil, wantErr: true},
+		{name: "unknown", args: []string{"foo"}, wantErr: true},
+		{name: "no subcommand", args: []string{"pos"}, wantErr: true},
+		{name: "unknown subcommand", args: []string{"pos", "foo"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd, args, err := findCommand(tt.args)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("findCommand() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if cmd.usage != tt.usage || len(args) != tt.wantArgs {
+				t.Errorf("findCommand() = %q %v, want %q with %d args", cmd.usage, args, tt.usage, tt.wantArgs)
+			}
+		})
+	}
+}
+
+func TestParseDestination(t *testing.T) {
+	tests := []struct {
+		name     string
+		s        string
+		wantText string
+		wantTime bool
+		wantErr  bool
+	}{
+		{name: "without time", s: "Москва; 55.75; 37.61", wantText: "Москва"},
+		{name: "with time", s: "Тверь;56.85;35.9;20.05.2019 10:00", wantText: "Тверь", wantTime: true},
+		{name: "too few parts", s: "Москва;55.75", wantErr: true},
+		{name: "bad latitude", s: "Москва;x;37.61", wantErr: true},
+		{name: "latitude out of range", s: "Москва;95;37.61", wantErr: true},
+		{name: "bad time", s: "Москва;55.75;37.61;завтра", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			do, err := parseDestination(tt.s, movizor.MoscowLocation)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseDestination() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if do.Text != tt.wantText || do.ExpectedTime.IsZero() == tt.wantTime {
+				t.Errorf("parseDestination() = %+v", do)
+			}
+		})
+	}
+}
+
+func TestParseTime_location(t *testing.T) {
+	loc := time.FixedZone("UTC+5", 5*60*60)
+	got, err := parseTime("20.05.2019 10:00", loc)
+	if err != nil || !got.Equal(time.Date(2019, 5, 20, 5, 0, 0, 0, time.UTC)) {
+		t.Errorf("parseTime() = %v, %v", got, err)
+	}
+	if s := formatTime(movizor.Time(got), loc); s != "20.05.2019 10:00:00" {
+		t.Errorf("formatTime() = %s", s)
+	}
+}
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/grender/movizor"
+)
+
+// timeLayouts - форматы времени, принимаемые в аргументах команд.
+var timeLayouts = []string{
+	"02.01.2006 15:04:05",
+	"02.01.2006 15:04",
+	"02.01.2006",
+	time.RFC3339,
+}
+
+// parseTime разбирает время в часовом поясе сервиса loc.
+func parseTime(s string, loc *time.Location) (time.Time, error) {
+	for _, layout := range timeLayouts {
+		if t, err := time.ParseInLocation(layout, s, loc); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q, should be in format dd.mm.yyyy hh:mm[:ss]", s)
+}
+
+func formatTime(t movizor.Time, loc *time.Location) string {
+	if t.Time().Unix() <= 0 {
+		return "-"
+	}
+	return t.Time().In(loc).Format("02.01.2006 15:04:05")
+}
+
+// stringList - флаг, который можно указать несколько раз.
+type stringList []string
+
+func (l *stringList) String() string     { return strings.Join(*l, ", ") }
+func (l *stringList) Set(v string) error { *l = append(*l, v); return nil }
+
+// objectFlags - флаги опций объекта для objects add и objects edit.
+type objectFlags struct {
+	title      string
+	tags       string
+	tariff     string
+	dateOff    string
+	schedule   string
+	prolong    bool
+	autoinform bool
+	metadata   stringList
+	dests      stringList
+}
+
+func (of *objectFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&of.title, "title", "", "название объекта")
+	fs.StringVar(&of.tags, "tags", "", "метки через запятую")
+	fs.StringVar(&of.tariff, "tariff", "", "тариф (0, 1, 3, 15, 30, 60, 180)")
+	fs.StringVar(&of.dateOff, "dateoff", "", "время автоматического отключения (dd.mm.yyyy hh:mm:ss)")
+	fs.StringVar(&of.schedule, "schedule", "", `расписание запросов, например "Mon-Fri 08:00-20:00 every 1h"`)
+	fs.BoolVar(&of.prolong, "prolong", false, "автоматически продлевать пакет")
+	fs.BoolVar(&of.autoinform, "autoinform", false, "включить автоинформатор")
+	fs.Var(&of.metadata, "meta", "метаинформация ключ=значение, можно указать несколько раз")
+	fs.Var(&of.dests, "dest", `точка назначения "адрес;широта;долгота[;время]", можно указать несколько раз`)
+}
+
+func (of *objectFlags) options(loc *time.Location) (*movizor.ObjectOptions, error) {
+	oo := &movizor.ObjectOptions{
+		Title:          of.title,
+		Tariff:         movizor.TariffType(of.tariff),
+		PackageProlong: of.prolong,
+		CallToDriver:   of.autoinform,
+	}
+	if of.tags != "" {
+		for _, t := range strings.Split(of.tags, ",") {
+			oo.Tags = append(oo.Tags, strings.TrimSpace(t))
+		}
+	}
+	if of.dateOff != "" {
+		t, err := parseTime(of.dateOff, loc)
+		if err != nil {
+			return nil, err
+		}
+		oo.DateOff = t
+	}
+	if of.schedule != "" {
+		s, err := movizor.ParseSchedule(of.schedule, loc)
+		if err != nil {
+			return nil, err
+		}
+		oo.Schedules = s
+	}
+	for _, m := range of.metadata {
+		kv := strings.SplitN(m, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("invalid metadata %q, should be key=value", m)
+		}
+		if oo.Metadata == nil {
+			oo.Metadata = make(map[string]string)
+		}
+		oo.Metadata[kv[0]] = kv[1]
+	}
+	for _, d := range of.dests {
+		do, err := parseDestination(d, loc)
+		if err != nil {
+			return nil, err
+		}
+		oo.Destinations = append(oo.Destinations, do)
+	}
+	return oo, nil
+}
+
+func parseDestination(s string, loc *time.Location) (movizor.DestinationOptions, error) {
+	parts := strings.Split(s, ";")
+	if len(parts) < 3 || len(parts) > 4 {
+		return movizor.DestinationOptions{}, fmt.Errorf("invalid destination %q, should be \"text;lat;lon[;time]\"", s)
+	}
+	lat, err := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+	if err != nil {
+		return movizor.DestinationOptions{}, fmt.Errorf("invalid latitude %q", parts[1])
+	}
+	lon, err := strconv.ParseFloat(strings.TrimSpace(parts[2]), 64)
+	if err != nil {
+		return movizor.DestinationOptions{}, fmt.Errorf("invalid longitude %q", parts[2])
+	}
+	c, err := movizor.NewCoordinates(lat, lon)
+	if err != nil {
+		return movizor.DestinationOptions{}, err
+	}
+
+	do := movizor.DestinationOptions{Text: strings.TrimSpace(parts[0]), Lat: c.Lat, Lon: c.Lon}
+	if len(parts) == 4 {
+		if do.ExpectedTime, err = parseTime(strings.TrimSpace(parts[3]), loc); err != nil {
+			return movizor.DestinationOptions{}, err
+		}
+	}
+	return do, nil
+}
+
+func (c *cli) printResponse(resp movizor.APIResponse) error {
+	return c.print(resp, func(w io.Writer) {
+		row(w, resp.Result, resp.MessageType)
+	})
+}
+
+func runObjectsList(c *cli, args []string) error {
+	if _, err := c.parse(c.flags(), args, 0); err != nil {
+		return err
+	}
+	list, err := c.api.GetObjects()
+	if err != nil {
+		return err
+	}
+	sort.Sort(list)
+	return c.print(list, func(w io.Writer) {
+		row(w, "ТЕЛЕФОН", "СТАТУС")
+		for _, o := range list {
+			row(w, o.Phone, o.Status)
+		}
+	})
+}
+
+func runObjectsGet(c *cli, args []string) error {
+	pos, err := c.parse(c.flags(), args, 1)
+	if err != nil {
+		return err
+	}
+	oi, err := c.api.GetObjectInfo(movizor.Object(pos[0]))
+	if err != nil {
+		return err
+	}
+	return c.print(oi, func(w io.Writer) {
+		row(w, "Телефон", oi.Phone)
+		row(w, "Статус", oi.Status)
+		row(w, "Подтвержден", oi.Confirmed)
+		row(w, "Название", oi.Title)
+		tariff := string(oi.Tariff)
+		if oi.TariffNew != nil && *oi.TariffNew != "" {
+			tariff += " -> " + string(*oi.TariffNew)
+		}
+		row(w, "Тариф", tariff)
+		row(w, "Метки", strings.Join(oi.Tags, ","))
+		row(w, "Расписание", oi.Schedule.String())
+		row(w, "Последний запрос", formatTime(oi.LastTimestamp, c.location()))
+		if oi.CurrentLat != nil && oi.CurrentLon != nil {
+			row(w, "Местоположение", fmt.Sprintf("%s,%s %s", oi.CurrentLat, oi.CurrentLon, oi.Place))
+		}
+		row(w, "Отключение", formatTime(oi.TimestampOff, c.location()))
+		row(w, "Добавлен", formatTime(oi.TimestampAdd, c.location()))
+		for i, d := range oi.Destination {
+			t := "-"
+			if !d.Time.IsZero() {
+				t = d.Time.In(c.location()).Format("02.01.2006 15:04")
+			}
+			row(w, fmt.Sprintf("Точка %d", i+1), fmt.Sprintf("%s (%s,%s) %s %s", d.Text, d.Lat, d.Lon, t, d.Status))
+		}
+		var keys []string
+		for k := range oi.Metadata {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			row(w, k, oi.Metadata[k])
+		}
+	})
+}
+
+func runObjectsAdd(c *cli, args []string) error {
+	fs := c.flags()
+	var of objectFlags
+	of.register(fs)
+	slave := fs.Uint64("slave", 0, "ID подчиненного кабинета")
+	pos, err := c.parse(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	oo, err := of.options(c.location())
+	if err != nil {
+		return err
+	}
+	resp, err := c.api.AddObjectToSlave(movizor.Object(pos[0]), oo, *slave)
+	if err != nil {
+		return err
+	}
+	return c.printResponse(resp)
+}
+
+func runObjectsEdit(c *cli, args []string) error {
+	fs := c.flags()
+	var of objectFlags
+	of.register(fs)
+	activate := fs.Bool("activate", false, "применить изменения немедленно, а не со следующих суток")
+	pos, err := c.parse(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	oo, err := of.options(c.location())
+	if err != nil {
+		return err
+	}
+	resp, err := c.api.EditObjectWithActivate(movizor.Object(pos[0]), oo, *activate)
+	if err != nil {
+		return err
+	}
+	return c.printResponse(resp)
+}
+
+// runObjectAction возвращает команду, вызывающую метод API с одним номером телефона.
+func runObjectAction(name string) func(c *cli, args []string) error {
+	return func(c *cli, args []string) error {
+		pos, err := c.parse(c.flags(), args, 1)
+		if err != nil {
+			return err
+		}
+		o := movizor.Object(pos[0])
+
+		var resp movizor.APIResponse
+		switch name {
+		case "delete":
+			resp, err = c.api.DeleteObject(o)
+		case "reactivate":
+			resp, err = c.api.ReactivateObject(o)
+		case "cancel-tariff":
+			resp, err = c.api.CancelTariffChangeObject(o)
+		}
+		if err != nil {
+			return err
+		}
+		return c.printResponse(resp)
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/grender/movizor"
+)
+
+func formatInt(i *movizor.Int) string {
+	if i == nil {
+		return "-"
+	}
+	return i.String()
+}
+
+func formatETAStatus(s *movizor.ETAStatus) string {
+	if s == nil {
+		return "-"
+	}
+	return string(*s)
+}
+
+// positionRow выводит строку таблицы местоположений, перед ней - колонки prefix.
+func (c *cli) positionRow(w io.Writer, p movizor.Position, prefix ...interface{}) {
+	row(w, append(prefix, formatTime(p.Timestamp, c.location()), p.Lat, p.Lon, formatInt(p.Deviation), p.Place,
+		formatInt(p.Distance), formatInt(p.ETA), formatETAStatus(p.ETAStatus))...)
+}
+
+var positionHeader = []interface{}{"ВРЕМЯ", "ШИРОТА", "ДОЛГОТА", "ПОГРЕШНОСТЬ", "МЕСТО", "ОСТАТОК КМ", "ETA", "ПРОГНОЗ"}
+
+func runPosLast(c *cli, args []string) error {
+	pos, err := c.parse(c.flags(), args, 1)
+	if err != nil {
+		return err
+	}
+	p, err := c.api.GetObjectLastPosition(movizor.Object(pos[0]))
+	if err != nil {
+		return err
+	}
+	return c.print(p, func(w io.Writer) {
+		row(w, positionHeader...)
+		c.positionRow(w, p)
+	})
+}
+
+func runPosList(c *cli, args []string) error {
+	fs := c.flags()
+	from := fs.String("from", "", "начало периода (dd.mm.yyyy hh:mm[:ss])")
+	to := fs.String("to", "", "конец периода (dd.mm.yyyy hh:mm[:ss])")
+	limit := fs.Uint64("limit", 0, "количество записей")
+	offset := fs.Uint64("offset", 0, "смещение")
+	pos, err := c.parse(fs, args, 1)
+	if err != nil {
+		return err
+	}
+
+	rpo := &movizor.RequestPositionsOptions{RequestLimit: *limit, Offset: *offset}
+	if *from != "" {
+		if rpo.TimeFrom, err = parseTime(*from, c.location()); err != nil {
+			return err
+		}
+	}
+	if *to != "" {
+		if rpo.TimeTo, err = parseTime(*to, c.location()); err != nil {
+			return err
+		}
+	}
+
+	ps, err := c.api.GetObjectPositions(movizor.Object(pos[0]), rpo)
+	if err != nil {
+		return err
+	}
+	ps = ps.Chronological()
+	return c.print(ps, func(w io.Writer) {
+		row(w, positionHeader...)
+		for _, p := range ps {
+			c.positionRow(w, p)
+		}
+	})
+}
+
+func runPosRequest(c *cli, args []string) error {
+	pos, err := c.parse(c.flags(), args, 1)
+	if err != nil {
+		return err
+	}
+	pr, err := c.api.RequestPosition(movizor.Object(pos[0]))
+	if err != nil {
+		return err
+	}
+	return c.print(pr, func(w io.Writer) {
+		row(w, "ID запроса", pr.RequestID)
+	})
+}
+
+func runPosGet(c *cli, args []string) error {
+	pos, err := c.parse(c.flags(), args, 1)
+	if err != nil {
+		return err
+	}
+	id, err := strconv.ParseInt(pos[0], 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid request id %q", pos[0])
+	}
+	p, err := c.api.GetRequestedPosition(movizor.PositionRequest{RequestID: id})
+	if err != nil {
+		return err
+	}
+	return c.print(p, func(w io.Writer) {
+		row(w, positionHeader...)
+		c.positionRow(w, p)
+	})
+}
+
+func runPosAll(c *cli, args []string) error {
+	if _, err := c.parse(c.flags(), args, 0); err != nil {
+		return err
+	}
+	ops, err := c.api.GetObjectsPositions()
+	if err != nil {
+		return err
+	}
+	return c.print(ops, func(w io.Writer) {
+		row(w, append([]interface{}{"ТЕЛЕФОН"}, positionHeader...)...)
+		for _, op := range ops {
+			c.positionRow(w, op.Position, op.Phone)
+		}
+	})
+}
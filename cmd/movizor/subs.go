@@ -0,0 +1,128 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/grender/movizor"
+)
+
+func runSubsList(c *cli, args []string) error {
+	if _, err := c.parse(c.flags(), args, 0); err != nil {
+		return err
+	}
+	subs, err := c.api.GetEventSubscriptions()
+	if err != nil {
+		return err
+	}
+	return c.print(subs, func(w io.Writer) {
+		row(w, "ID", "СОБЫТИЕ", "ОБЪЕКТЫ", "УВЕДОМЛЕНИЕ")
+		for _, s := range subs {
+			objects := "все"
+			if !s.IsAllObjectsSubscribed {
+				phones := make([]string, len(s.ObjectsSubscribed))
+				for i, o := range s.ObjectsSubscribed {
+					phones[i] = string(o)
+				}
+				objects = strings.Join(phones, ",")
+			}
+			notify := "telegram"
+			switch {
+			case s.Phone != "":
+				notify = "sms " + string(s.Phone)
+			case s.EMail != "":
+				notify = "email " + s.EMail
+			case !s.IsTelegram:
+				notify = "-"
+			}
+			row(w, s.SubscriptionID, s.Event, objects, notify)
+		}
+	})
+}
+
+func runSubsAdd(c *cli, args []string) error {
+	fs := c.flags()
+	event := fs.String("event", "", "тип события, например confirm или pos_late")
+	all := fs.Bool("all", false, "подписка на все объекты, в том числе добавляемые в будущем")
+	phones := fs.String("phones", "", "телефоны объектов через запятую")
+	sms := fs.String("sms", "", "отправлять уведомления по СМС на телефон")
+	email := fs.String("email", "", "отправлять уведомления на почтовый адрес")
+	telegram := fs.Bool("telegram", false, "отправлять уведомления в Телеграм из профиля аккаунта")
+	if _, err := c.parse(fs, args, 0); err != nil {
+		return err
+	}
+
+	seo := movizor.SubscribeEventOptions{AllObjects: *all, Event: movizor.EventType(*event)}
+	if *phones != "" {
+		for _, p := range strings.Split(*phones, ",") {
+			seo.Objects = append(seo.Objects, movizor.Object(strings.TrimSpace(p)))
+		}
+	}
+	var err error
+	switch {
+	case *sms != "":
+		err = seo.SetSMSNotification(movizor.Object(*sms))
+	case *email != "":
+		err = seo.SetEMailNotification(*email)
+	case *telegram:
+		seo.SetTelegramNotification()
+	default:
+		err = errors.New("notification is not set: use -sms, -email or -telegram")
+	}
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.api.SubscribeEvent(seo)
+	if err != nil {
+		return err
+	}
+	return c.printResponse(resp)
+}
+
+func runSubsDelete(c *cli, args []string) error {
+	pos, err := c.parse(c.flags(), args, 1)
+	if err != nil {
+		return err
+	}
+	id, err := strconv.ParseInt(pos[0], 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid subscription id %q", pos[0])
+	}
+	resp, err := c.api.DeleteEventsSubscription(id)
+	if err != nil {
+		return err
+	}
+	return c.printResponse(resp)
+}
+
+func runSubsClear(c *cli, args []string) error {
+	fs := c.flags()
+	phone := fs.String("phone", "", "удалить только подписки с явным указанием телефона")
+	event := fs.String("event", "", "удалить только подписки на этот тип события (вместе с -phone)")
+	if _, err := c.parse(fs, args, 0); err != nil {
+		return err
+	}
+	switch {
+	case *phone != "":
+		var et *movizor.EventType
+		if *event != "" {
+			e := movizor.EventType(*event)
+			et = &e
+		}
+		return c.api.ClearObjectEventSubscriptions(movizor.Object(*phone), et)
+	case *event != "":
+		return errors.New("-event requires -phone")
+	}
+	return c.api.ClearAllEventSubscriptions()
+}
+
+func runSubsGC(c *cli, args []string) error {
+	if _, err := c.parse(c.flags(), args, 0); err != nil {
+		return err
+	}
+	return c.api.ClearUnusedSubscriptions()
+}
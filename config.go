@@ -0,0 +1,333 @@
+package movizor
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ConfigFileEnv - переменная окружения с путем к файлу конфигурации для LoadConfig.
+const ConfigFileEnv = "MOVIZOR_CONFIG"
+
+// Config предоставляет настройки клиента API для NewFromConfig.
+//
+// В файле конфигурации и переменных окружения используются ключи (в окружении -
+// с префиксом MOVIZOR_ в верхнем регистре, например MOVIZOR_RETRY_ATTEMPTS):
+//
+//	project          - Project
+//	token            - Token
+//	endpoint         - Endpoint
+//	timeout          - Timeout, например 30s
+//	retry_attempts   - Retry.Attempts
+//	retry_delay      - Retry.Delay
+//	retry_max_delay  - Retry.MaxDelay
+//	rate_limit       - RateLimit
+//	debug            - Debug (1, true, yes)
+//	time_zone        - TimeZone, например Europe/Moscow
+//
+// Длительности указываются в формате time.ParseDuration или числом секунд.
+type Config struct {
+	Project   string
+	Token     string
+	Endpoint  string        // Адрес сервиса. Пустой - DefaultAPIMovizorEndpoint
+	Timeout   time.Duration // Таймаут одной попытки HTTP запроса. 0 - DefaultTimeout
+	Retry     RetryPolicy   // Повтор запросов при сетевых ошибках и ответах 5xx
+	RateLimit float64       // Не более RateLimit запросов в секунду. 0 - без ограничения
+	Debug     bool          // Выводить запросы и ответы в лог
+	TimeZone  string        // Часовой пояс API.Location (имя из базы IANA). Пустой - MoscowLocation
+}
+
+// configKeys - ключи конфигурации в порядке документации.
+var configKeys = []string{"project", "token", "endpoint", "timeout", "retry_attempts", "retry_delay",
+	"retry_max_delay", "rate_limit", "debug", "time_zone"}
+
+func (c *Config) set(key, value string) (err error) {
+	switch key {
+	case "project":
+		c.Project = value
+	case "token":
+		c.Token = value
+	case "endpoint":
+		c.Endpoint = value
+	case "timeout":
+		c.Timeout, err = parseConfigDuration(value)
+	case "retry_attempts":
+		c.Retry.Attempts, err = strconv.Atoi(value)
+	case "retry_delay":
+		c.Retry.Delay, err = parseConfigDuration(value)
+	case "retry_max_delay":
+		c.Retry.MaxDelay, err = parseConfigDuration(value)
+	case "rate_limit":
+		c.RateLimit, err = strconv.ParseFloat(value, 64)
+	case "debug":
+		c.Debug, err = parseImportBool(value)
+	case "time_zone":
+		c.TimeZone = value
+	default:
+		return fmt.Errorf("unknown config key %q", key)
+	}
+	if err != nil {
+		return fmt.Errorf("invalid %s %q", key, value)
+	}
+	return nil
+}
+
+func parseConfigDuration(s string) (time.Duration, error) {
+	if sec, err := strconv.ParseFloat(s, 64); err == nil {
+		return time.Duration(sec * float64(time.Second)), nil
+	}
+	return time.ParseDuration(s)
+}
+
+// ConfigFromEnv читает настройки из переменных окружения MOVIZOR_*. Незаданные
+// переменные оставляют нулевые значения.
+func ConfigFromEnv() (Config, error) {
+	var c Config
+	if err := c.applyEnv(); err != nil {
+		return Config{}, err
+	}
+	return c, nil
+}
+
+func (c *Config) applyEnv() error {
+	for _, key := range configKeys {
+		env := "MOVIZOR_" + strings.ToUpper(key)
+		if v, ok := os.LookupEnv(env); ok && v != "" {
+			if err := c.set(key, v); err != nil {
+				return fmt.Errorf("%s: %s", env, err)
+			}
+		}
+	}
+	return nil
+}
+
+// ParseConfig разбирает файл конфигурации в формате JSON (объект верхнего уровня)
+// или в подмножестве YAML: строки "ключ: значение", комментарии после #,
+// значения в одинарных или двойных кавычках. Вложенные структуры не поддерживаются.
+func ParseConfig(data []byte) (Config, error) {
+	var c Config
+	if err := c.parse(data); err != nil {
+		return Config{}, err
+	}
+	return c, nil
+}
+
+func (c *Config) parse(data []byte) error {
+	data = bytes.TrimPrefix(data, []byte("\ufeff"))
+	var values map[string]string
+	var err error
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		values, err = parseJSONConfig(trimmed)
+	} else {
+		values, err = parseYAMLConfig(data)
+	}
+	if err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if err := c.set(k, values[k]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func parseJSONConfig(data []byte) (map[string]string, error) {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	values := make(map[string]string, len(raw))
+	for k, v := range raw {
+		var s string
+		if err := json.Unmarshal(v, &s); err == nil {
+			values[k] = s
+			continue
+		}
+		t := string(bytes.TrimSpace(v))
+		if t == "null" {
+			continue
+		}
+		if t[0] == '{' || t[0] == '[' {
+			return nil, fmt.Errorf("config key %q should be a string, number or boolean", k)
+		}
+		values[k] = t
+	}
+	return values, nil
+}
+
+func parseYAMLConfig(data []byte) (map[string]string, error) {
+	values := make(map[string]string)
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; sc.Scan(); line++ {
+		s := strings.TrimSpace(sc.Text())
+		if s == "" || s[0] == '#' || s == "---" {
+			continue
+		}
+		sep := strings.Index(s, ":")
+		if sep <= 0 {
+			return nil, fmt.Errorf("config line %d: expected \"key: value\"", line)
+		}
+		key := strings.TrimSpace(s[:sep])
+		v, err := parseYAMLValue(strings.TrimSpace(s[sep+1:]))
+		if err != nil {
+			return nil, fmt.Errorf("config line %d: %s", line, err)
+		}
+		values[key] = v
+	}
+	return values, sc.Err()
+}
+
+func parseYAMLValue(s string) (string, error) {
+	switch {
+	case strings.HasPrefix(s, `"`):
+		end := strings.LastIndex(s, `"`)
+		if end == 0 {
+			return "", errors.New("unterminated quoted value")
+		}
+		if rest := strings.TrimSpace(s[end+1:]); rest != "" && rest[0] != '#' {
+			return "", fmt.Errorf("unexpected %q after quoted value", rest)
+		}
+		return strconv.Unquote(s[:end+1])
+	case strings.HasPrefix(s, "'"):
+		end := strings.LastIndex(s, "'")
+		if end == 0 {
+			return "", errors.New("unterminated quoted value")
+		}
+		if rest := strings.TrimSpace(s[end+1:]); rest != "" && rest[0] != '#' {
+			return "", fmt.Errorf("unexpected %q after quoted value", rest)
+		}
+		return strings.Replace(s[1:end], "''", "'", -1), nil
+	}
+	if i := strings.Index(s, " #"); i >= 0 {
+		s = strings.TrimSpace(s[:i])
+	}
+	if s == "~" || s == "null" {
+		return "", nil
+	}
+	return s, nil
+}
+
+// LoadConfigFile читает файл конфигурации (см. ParseConfig).
+func LoadConfigFile(path string) (Config, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return Config{}, err
+	}
+	c, err := ParseConfig(data)
+	if err != nil {
+		return Config{}, fmt.Errorf("%s: %s", filepath.Base(path), err)
+	}
+	return c, nil
+}
+
+// LoadConfig читает файл конфигурации path (если path пустой - из переменной окружения
+// MOVIZOR_CONFIG, если задана) и переопределяет его значения переменными окружения MOVIZOR_*.
+// Значения не проверяются, проверку выполняет NewFromConfig.
+func LoadConfig(path string) (Config, error) {
+	if path == "" {
+		path = os.Getenv(ConfigFileEnv)
+	}
+
+	var c Config
+	if path != "" {
+		var err error
+		if c, err = LoadConfigFile(path); err != nil {
+			return Config{}, err
+		}
+	}
+	if err := c.applyEnv(); err != nil {
+		return Config{}, err
+	}
+	return c, nil
+}
+
+// Validate проверяет настройки: наличие проекта и ключа, адрес сервиса, неотрицательность
+// таймаутов, повторов и ограничения запросов, часовой пояс.
+func (c Config) Validate() error {
+	if c.Project == "" {
+		return errors.New("config: project is not set")
+	}
+	if c.Token == "" {
+		return errors.New("config: token is not set")
+	}
+	if c.Endpoint != "" {
+		if err := validateEndpoint(c.Endpoint); err != nil {
+			return fmt.Errorf("config: %s", err)
+		}
+	}
+	if c.Timeout < 0 {
+		return fmt.Errorf("config: negative timeout %s", c.Timeout)
+	}
+	if err := c.Retry.validate(); err != nil {
+		return fmt.Errorf("config: %s", err)
+	}
+	if c.RateLimit < 0 {
+		return fmt.Errorf("config: negative rate limit %g", c.RateLimit)
+	}
+	if _, err := c.Location(); err != nil {
+		return fmt.Errorf("config: %s", err)
+	}
+	return nil
+}
+
+// Location возвращает часовой пояс TimeZone, MoscowLocation для пустого значения.
+func (c Config) Location() (*time.Location, error) {
+	if c.TimeZone == "" || c.TimeZone == "Europe/Moscow" {
+		return MoscowLocation, nil
+	}
+	return time.LoadLocation(c.TimeZone)
+}
+
+func validateEndpoint(endp string) error {
+	u, err := url.Parse(endp)
+	if err != nil {
+		return fmt.Errorf("invalid endpoint %q: %s", endp, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		return fmt.Errorf("invalid endpoint %q, should be http(s)://host[/path]", endp)
+	}
+	return nil
+}
+
+// NewFromConfig проверяет настройки (см. Config.Validate) и создает экземпляр Movizor API
+// через New.
+func NewFromConfig(c Config) (*API, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	loc, err := c.Location()
+	if err != nil {
+		return nil, err
+	}
+
+	opts := []Option{
+		WithRetry(c.Retry),
+		WithRateLimit(c.RateLimit),
+		WithDebug(c.Debug),
+		WithLocation(loc),
+	}
+	if c.Endpoint != "" {
+		opts = append(opts, WithEndpoint(c.Endpoint))
+	}
+	if c.Timeout > 0 {
+		opts = append(opts, WithTimeout(c.Timeout))
+	}
+	return New(c.Project, c.Token, opts...)
+}
@@ -0,0 +1,175 @@
+package movizor
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func setEnv(t *testing.T, env map[string]string) func() {
+	t.Helper()
+	old := make(map[string]*string, len(env))
+	for k, v := range env {
+		if prev, ok := os.LookupEnv(k); ok {
+			old[k] = &prev
+		} else {
+			old[k] = nil
+		}
+		if err := os.Setenv(k, v); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+	return func() {
+		for k, v := range old {
+			if v == nil {
+				_ = os.Unsetenv(k)
+			} else {
+				_ = os.Setenv(k, *v)
+			}
+		}
+	}
+}
+
+func TestLoadConfigFile(t *testing.T) {
+	tests := []struct {
+		file string
+		want Config
+	}{
+		{
+			file: "config.yaml",
+			want: Config{
+				Project:   "demo",
+				Token:     "secret # not a comment",
+				Endpoint:  "https://movizor.example/api",
+				Timeout:   15 * time.Second,
+				Retry:     RetryPolicy{Attempts: 3, Delay: 250 * time.Millisecond},
+				RateLimit: 2,
+				Debug:     true,
+				TimeZone:  "UTC",
+			},
+		},
+		{
+			file: "config.json",
+			want: Config{
+				Project:   "demo",
+				Token:     "secret",
+				Timeout:   15 * time.Second,
+				Retry:     RetryPolicy{Attempts: 3, MaxDelay: 10 * time.Second},
+				RateLimit: 2.5,
+				Debug:     true,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			got, err := LoadConfigFile(filepath.Join(dataPath, tt.file))
+			if err != nil {
+				t.Fatalf("LoadConfigFile() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("LoadConfigFile() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseConfig_errors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{name: "unknown key", data: "project: demo\nproxy: localhost\n"},
+		{name: "no separator", data: "project demo\n"},
+		{name: "bad duration", data: "timeout: soon\n"},
+		{name: "bad number", data: `{"retry_attempts": "three"}`},
+		{name: "nested json", data: `{"retry": {"attempts": 3}}`},
+		{name: "unterminated quote", data: "token: \"secret\n"},
+		{name: "bad json", data: `{"project": }`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseConfig([]byte(tt.data)); err == nil {
+				t.Errorf("ParseConfig(%q) should fail", tt.data)
+			}
+		})
+	}
+}
+
+func TestLoadConfig_precedence(t *testing.T) {
+	defer setEnv(t, map[string]string{
+		"MOVIZOR_CONFIG":         filepath.Join(dataPath, "config.yaml"),
+		"MOVIZOR_TOKEN":          "from-env",
+		"MOVIZOR_DEBUG":          "0",
+		"MOVIZOR_RETRY_ATTEMPTS": "1",
+	})()
+
+	c, err := LoadConfig("")
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+	if c.Project != "demo" || c.Token != "from-env" || c.Debug || c.Retry.Attempts != 1 || c.Timeout != 15*time.Second {
+		t.Errorf("LoadConfig() = %+v", c)
+	}
+
+	c, err = LoadConfig(filepath.Join(dataPath, "config.json"))
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+	if c.Endpoint != "" || c.Token != "from-env" {
+		t.Errorf("LoadConfig() with explicit path = %+v", c)
+	}
+
+	defer setEnv(t, map[string]string{"MOVIZOR_TIMEOUT": "later"})()
+	if _, err := LoadConfig(""); err == nil || !strings.Contains(err.Error(), "MOVIZOR_TIMEOUT") {
+		t.Errorf("LoadConfig() error = %v, want MOVIZOR_TIMEOUT error", err)
+	}
+}
+
+func TestConfig_Validate(t *testing.T) {
+	valid := Config{Project: "demo", Token: "secret"}
+	tests := []struct {
+		name    string
+		modify  func(c *Config)
+		wantErr bool
+	}{
+		{name: "minimal", modify: func(c *Config) {}},
+		{name: "endpoint", modify: func(c *Config) { c.Endpoint = "http://localhost:8080/api" }},
+		{name: "no project", modify: func(c *Config) { c.Project = "" }, wantErr: true},
+		{name: "no token", modify: func(c *Config) { c.Token = "" }, wantErr: true},
+		{name: "endpoint without scheme", modify: func(c *Config) { c.Endpoint = "movizor.ru/api" }, wantErr: true},
+		{name: "endpoint bad scheme", modify: func(c *Config) { c.Endpoint = "ftp://movizor.ru/api" }, wantErr: true},
+		{name: "endpoint unparsable", modify: func(c *Config) { c.Endpoint = "https://movizor.ru:port/api" }, wantErr: true},
+		{name: "negative timeout", modify: func(c *Config) { c.Timeout = -time.Second }, wantErr: true},
+		{name: "negative retries", modify: func(c *Config) { c.Retry.Attempts = -1 }, wantErr: true},
+		{name: "negative rate limit", modify: func(c *Config) { c.RateLimit = -1 }, wantErr: true},
+		{name: "unknown time zone", modify: func(c *Config) { c.TimeZone = "Mars/Olympus" }, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := valid
+			tt.modify(&c)
+			if err := c.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewFromConfig(t *testing.T) {
+	if _, err := NewFromConfig(Config{Project: "demo"}); err == nil {
+		t.Error("NewFromConfig() without token should fail")
+	}
+
+	api, err := NewFromConfig(Config{Project: "demo", Token: "secret", Endpoint: "http://localhost/api/",
+		Timeout: time.Minute, Retry: RetryPolicy{Attempts: 2}, RateLimit: 4, Debug: true})
+	if err != nil {
+		t.Fatalf("NewFromConfig() error = %v", err)
+	}
+	if api.Endpoint != "http://localhost/api" || api.Client.Timeout != time.Minute || api.Retry.Attempts != 2 ||
+		!api.IsDebug || api.Location != MoscowLocation || api.limiter == nil || api.limiter.interval != 250*time.Millisecond {
+		t.Errorf("NewFromConfig() = %+v", api)
+	}
+}
@@ -1,5 +1,7 @@
 package movizor
 
+import "time"
+
 const (
 	// DefaultAPIMovizorEndpoint is default API Movizor endpoint
 	DefaultAPIMovizorEndpoint = "https://movizor.ru/api"
@@ -39,6 +41,40 @@ const (
 	TariffEvery180 TariffType = "180" // Каждые 3 часа
 )
 
+// IsKnown возвращает true, если тариф является одним из известных типов тарифов.
+func (t TariffType) IsKnown() bool {
+	switch t {
+	case TariffManual, TariffOnline, TariffOneMonth, TariffEvery15, TariffEvery30, TariffEvery60, TariffEvery180:
+		return true
+	}
+	return false
+}
+
+// Normalize возвращает тариф в форме, в которой он указывается в AddObject и EditObject:
+// "manual", который возвращает object_get, соответствует TariffManual.
+func (t TariffType) Normalize() TariffType {
+	if t == "manual" {
+		return TariffManual
+	}
+	return t
+}
+
+// Interval возвращает интервал автоматических запросов местоположения по тарифу,
+// 0 для тарифов без автоматических запросов (вручную, онлайн, пакет).
+func (t TariffType) Interval() time.Duration {
+	switch t {
+	case TariffEvery15:
+		return 15 * time.Minute
+	case TariffEvery30:
+		return 30 * time.Minute
+	case TariffEvery60:
+		return time.Hour
+	case TariffEvery180:
+		return 3 * time.Hour
+	}
+	return 0
+}
+
 // Status представляет собой возможный статус состояния объекта в системе МоВизор.
 type Status string
 
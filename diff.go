@@ -0,0 +1,302 @@
+package movizor
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// ChangeKind представляет собой вид изменения поля объекта.
+type ChangeKind string
+
+const (
+	FieldChanged ChangeKind = "changed" // Значение изменено
+	FieldAdded   ChangeKind = "added"   // Элемент добавлен (метка, ключ метаинформации, точка назначения)
+	FieldRemoved ChangeKind = "removed" // Элемент удален
+	FieldMoved   ChangeKind = "moved"   // Точка назначения перемещена в маршруте
+)
+
+// FieldChange описывает изменение одного поля объекта.
+type FieldChange struct {
+	Field string     // Поле в терминах API: title, tariff, dateoff, package_prolong, autoinform, tags, metadata, destination, schedule
+	Key   string     // Метка, ключ метаинформации или адрес точки назначения
+	Kind  ChangeKind // Вид изменения
+	From  string     // Текущее значение, пустое для FieldAdded
+	To    string     // Новое значение, пустое для FieldRemoved
+	// FromIndex и ToIndex - позиции точки назначения в текущем и новом маршруте, -1 если нет.
+	FromIndex, ToIndex int
+	// RequiresActivation - без активации (EditObjectWithActivate) изменение вступит в силу
+	// только со следующих суток.
+	RequiresActivation bool
+}
+
+// String возвращает изменение в виде строки, например `tariff "30" -> "60"`.
+func (c FieldChange) String() string {
+	name := c.Field
+	if c.Key != "" {
+		name += "[" + c.Key + "]"
+	}
+
+	var s string
+	switch c.Kind {
+	case FieldAdded:
+		s = fmt.Sprintf("%s added %q", name, c.To)
+	case FieldRemoved:
+		s = fmt.Sprintf("%s removed %q", name, c.From)
+	case FieldMoved:
+		s = fmt.Sprintf("%s moved #%d -> #%d", name, c.FromIndex+1, c.ToIndex+1)
+	default:
+		s = fmt.Sprintf("%s %q -> %q", name, c.From, c.To)
+	}
+	if c.RequiresActivation {
+		s += " (activation)"
+	}
+	return s
+}
+
+// ObjectDiff - изменения, которые произведет EditObject с желаемыми опциями.
+type ObjectDiff struct {
+	Changes []FieldChange
+}
+
+// IsEmpty возвращает true, если изменений нет.
+func (d ObjectDiff) IsEmpty() bool {
+	return len(d.Changes) == 0
+}
+
+// RequiresActivation возвращает true, если хотя бы одно изменение без активации
+// вступит в силу только со следующих суток.
+func (d ObjectDiff) RequiresActivation() bool {
+	for _, c := range d.Changes {
+		if c.RequiresActivation {
+			return true
+		}
+	}
+	return false
+}
+
+// Strings возвращает изменения в виде строк.
+func (d ObjectDiff) Strings() []string {
+	out := make([]string, 0, len(d.Changes))
+	for _, c := range d.Changes {
+		out = append(out, c.String())
+	}
+	return out
+}
+
+// String возвращает изменения построчно.
+func (d ObjectDiff) String() string {
+	var buf bytes.Buffer
+	for _, s := range d.Strings() {
+		buf.WriteString(s)
+		buf.WriteByte('\n')
+	}
+	return buf.String()
+}
+
+// DiffObject сравнивает текущее состояние объекта oi с желаемыми опциями oo.
+// Как и в EditObject, незаданные (нулевые) поля oo не сравниваются: пустые Title, Tariff
+// и DateOff, false у PackageProlong и CallToDriver, nil у Tags, Metadata, Destinations
+// и Schedules. Пустой, но не nil, список означает удаление всех элементов.
+// Тариф сравнивается с тарифом, действующим со следующих суток (TariffNew, если задан).
+// Время прибытия в точки назначения выводится в часовом поясе сервиса loc.
+// Расписания сравниваются после перевода в часовой пояс сервиса loc (nil - MoscowLocation),
+// в котором они передаются в сервис (см. SchedulingOptions.In).
+// Смена тарифа и расписания запросов без активации вступает в силу со следующих суток.
+func DiffObject(oi ObjectInfo, oo ObjectOptions, loc *time.Location) ObjectDiff {
+	current := oi.Options()
+	var d ObjectDiff
+	changed := func(field, from, to string, activation bool) {
+		d.Changes = append(d.Changes, FieldChange{Field: field, Kind: FieldChanged, From: from, To: to,
+			FromIndex: -1, ToIndex: -1, RequiresActivation: activation})
+	}
+
+	if oo.Title != "" && oo.Title != oi.Title {
+		changed("title", oi.Title, oo.Title, false)
+	}
+	if tt := oo.Tariff.Normalize(); tt != "" && tt != current.Tariff {
+		changed("tariff", string(current.Tariff), string(tt), tt != oi.Tariff.Normalize())
+	}
+	if !oo.DateOff.IsZero() && !oo.DateOff.Truncate(time.Second).Equal(current.DateOff) {
+		changed("dateoff", formatExportTimeValue(current.DateOff), formatExportTimeValue(oo.DateOff), false)
+	}
+	if oo.PackageProlong && !oi.PackageProlong {
+		changed("package_prolong", "0", "1", false)
+	}
+	if oo.CallToDriver && !oi.CallToDriver {
+		changed("autoinform", "0", "1", false)
+	}
+	if oo.Tags != nil {
+		d.Changes = append(d.Changes, diffSets("tags", oi.Tags, oo.Tags)...)
+	}
+	if oo.Metadata != nil {
+		d.Changes = append(d.Changes, diffMetadata(oi.Metadata, oo.Metadata)...)
+	}
+	if oo.Destinations != nil {
+		d.Changes = append(d.Changes, diffDestinations(current.Destinations, oo.Destinations, loc)...)
+	}
+	if oo.Schedules != nil {
+		from, to := scheduleString(current.Schedules, loc), scheduleString(oo.Schedules, loc)
+		if from != to {
+			changed("schedule", from, to, true)
+		}
+	}
+	return d
+}
+
+// scheduleString возвращает расписание s в часовом поясе сервиса loc. Если перевести
+// расписание нельзя (см. SchedulingOptions.In), оно выводится в собственном часовом поясе.
+func scheduleString(s *SchedulingOptions, loc *time.Location) string {
+	if s == nil {
+		return ""
+	}
+	ss, err := s.In(loc)
+	if err != nil {
+		return s.String()
+	}
+	return ss.String()
+}
+
+func diffSets(field string, from, to []string) []FieldChange {
+	in := func(list []string, s string) bool {
+		for _, v := range list {
+			if v == s {
+				return true
+			}
+		}
+		return false
+	}
+
+	var changes []FieldChange
+	for _, s := range from {
+		if !in(to, s) {
+			changes = append(changes, FieldChange{Field: field, Kind: FieldRemoved, From: s, FromIndex: -1, ToIndex: -1})
+		}
+	}
+	for _, s := range to {
+		if !in(from, s) {
+			changes = append(changes, FieldChange{Field: field, Kind: FieldAdded, To: s, FromIndex: -1, ToIndex: -1})
+		}
+	}
+	return changes
+}
+
+func diffMetadata(from, to map[string]string) []FieldChange {
+	keys := make([]string, 0, len(from)+len(to))
+	for k := range from {
+		keys = append(keys, k)
+	}
+	for k := range to {
+		if _, ok := from[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	var changes []FieldChange
+	for _, k := range keys {
+		fv, inFrom := from[k]
+		tv, inTo := to[k]
+		c := FieldChange{Field: "metadata", Key: k, From: fv, To: tv, FromIndex: -1, ToIndex: -1}
+		switch {
+		case !inTo:
+			c.Kind = FieldRemoved
+		case !inFrom:
+			c.Kind = FieldAdded
+		case fv != tv:
+			c.Kind = FieldChanged
+		default:
+			continue
+		}
+		changes = append(changes, c)
+	}
+	return changes
+}
+
+// destinationKey идентифицирует точку назначения по адресу и координатам
+// с точностью, с которой они передаются в сервис.
+func destinationKey(d DestinationOptions) string {
+	return d.Text + "|" + d.Lat.String() + "," + d.Lon.String()
+}
+
+// describeDestination описывает точку назначения координатами и временем прибытия
+// в часовом поясе сервиса loc.
+func describeDestination(d DestinationOptions, loc *time.Location) string {
+	s := d.Lat.String() + "," + d.Lon.String()
+	if !d.ExpectedTime.IsZero() {
+		s += " " + formatDestinationTime(d.ExpectedTime, loc)
+	}
+	return s
+}
+
+// diffDestinations сопоставляет точки маршрута по адресу и координатам. Точки, входящие
+// в наибольшую общую подпоследовательность маршрутов, считаются оставшимися на месте,
+// остальные сопоставленные - перемещенными. Время прибытия выводится в часовом поясе loc.
+func diffDestinations(from, to []DestinationOptions, loc *time.Location) []FieldChange {
+	// Сопоставление точек: match[i] - индекс в to для from[i] или -1.
+	match := make([]int, len(from))
+	used := make([]bool, len(to))
+	for i, f := range from {
+		match[i] = -1
+		for j, t := range to {
+			if !used[j] && destinationKey(f) == destinationKey(t) {
+				match[i], used[j] = j, true
+				break
+			}
+		}
+	}
+
+	// Наибольшая возрастающая подпоследовательность сопоставленных индексов
+	// соответствует наибольшей общей подпоследовательности маршрутов.
+	stay := make([]bool, len(from))
+	var seq []int
+	for i, j := range match {
+		if j >= 0 {
+			seq = append(seq, i)
+		}
+	}
+	lis := make([]int, len(seq))
+	prev := make([]int, len(seq))
+	best := -1
+	for a := range seq {
+		lis[a], prev[a] = 1, -1
+		for b := 0; b < a; b++ {
+			if match[seq[b]] < match[seq[a]] && lis[b]+1 > lis[a] {
+				lis[a], prev[a] = lis[b]+1, b
+			}
+		}
+		if best < 0 || lis[a] > lis[best] {
+			best = a
+		}
+	}
+	for a := best; a >= 0; a = prev[a] {
+		stay[seq[a]] = true
+	}
+
+	var changes []FieldChange
+	for i, f := range from {
+		j := match[i]
+		if j < 0 {
+			changes = append(changes, FieldChange{Field: "destination", Key: f.Text, Kind: FieldRemoved,
+				From: describeDestination(f, loc), FromIndex: i, ToIndex: -1})
+			continue
+		}
+		if !stay[i] {
+			changes = append(changes, FieldChange{Field: "destination", Key: f.Text, Kind: FieldMoved,
+				From: strconv.Itoa(i + 1), To: strconv.Itoa(j + 1), FromIndex: i, ToIndex: j})
+		}
+		if !f.ExpectedTime.Truncate(time.Minute).Equal(to[j].ExpectedTime.Truncate(time.Minute)) {
+			changes = append(changes, FieldChange{Field: "destination", Key: f.Text, Kind: FieldChanged,
+				From: describeDestination(f, loc), To: describeDestination(to[j], loc), FromIndex: i, ToIndex: j})
+		}
+	}
+	for j, t := range to {
+		if !used[j] {
+			changes = append(changes, FieldChange{Field: "destination", Key: t.Text, Kind: FieldAdded,
+				To: describeDestination(t, loc), FromIndex: -1, ToIndex: j})
+		}
+	}
+	return changes
+}
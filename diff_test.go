@@ -0,0 +1,147 @@
+package movizor
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDiffObject(t *testing.T) {
+	moscow := DestinationOptions{Text: "Москва", Lat: 55.75, Lon: 37.61}
+	tver := DestinationOptions{Text: "Тверь", Lat: 56.86, Lon: 35.9}
+	spb := DestinationOptions{Text: "Санкт-Петербург", Lat: 59.94, Lon: 30.31}
+	next := TariffEvery60
+	oi := ObjectInfo{
+		Title:     "Иванов",
+		Tariff:    TariffEvery30,
+		TariffNew: &next,
+		Tags:      []string{"b", "a"},
+		Metadata:  map[string]string{"Склад": "Восточный", "Заказ": "1"},
+		Destination: []Destination{
+			{Text: moscow.Text, Coordinates: moscow.Coordinates()},
+			{Text: tver.Text, Coordinates: tver.Coordinates()},
+			{Text: spb.Text, Coordinates: spb.Coordinates()},
+		},
+	}
+	late := spb
+	late.ExpectedTime = time.Date(2019, 2, 20, 12, 0, 0, 0, MoscowLocation)
+
+	tests := []struct {
+		name           string
+		oo             ObjectOptions
+		want           []string
+		wantActivation bool
+	}{
+		{
+			name: "nothing set",
+			oo:   ObjectOptions{},
+		},
+		{
+			name: "same",
+			oo: ObjectOptions{
+				Title:        "Иванов",
+				Tariff:       TariffEvery60,
+				Tags:         []string{"a", "b"},
+				Metadata:     map[string]string{"Заказ": "1", "Склад": "Восточный"},
+				Destinations: []DestinationOptions{moscow, tver, spb},
+			},
+		},
+		{
+			name: "title and tariff",
+			oo:   ObjectOptions{Title: "Петров", Tariff: TariffOnline},
+			want: []string{
+				`title "Иванов" -> "Петров"`,
+				`tariff "60" -> "1" (activation)`,
+			},
+			wantActivation: true,
+		},
+		{
+			name: "cancel scheduled tariff",
+			oo:   ObjectOptions{Tariff: TariffEvery30},
+			want: []string{`tariff "60" -> "30"`},
+		},
+		{
+			name: "tags and metadata",
+			oo: ObjectOptions{
+				Tags:     []string{"a", "c"},
+				Metadata: map[string]string{"Склад": "Западный", "Водитель": "Иван"},
+			},
+			want: []string{
+				`tags removed "b"`,
+				`tags added "c"`,
+				`metadata[Водитель] added "Иван"`,
+				`metadata[Заказ] removed "1"`,
+				`metadata[Склад] "Восточный" -> "Западный"`,
+			},
+		},
+		{
+			name: "destinations",
+			oo:   ObjectOptions{Destinations: []DestinationOptions{tver, moscow, late}},
+			want: []string{
+				`destination[Тверь] moved #2 -> #1`,
+				`destination[Санкт-Петербург] "59.94000000,30.31000000" -> "59.94000000,30.31000000 20.02.2019 12:00"`,
+			},
+		},
+		{
+			name: "destination added and removed",
+			oo:   ObjectOptions{Destinations: []DestinationOptions{moscow, spb, {Text: "Клин", Lat: 56.33, Lon: 36.73}}},
+			want: []string{
+				`destination[Тверь] removed "56.86000000,35.90000000"`,
+				`destination[Клин] added "56.33000000,36.73000000"`,
+			},
+		},
+		{
+			name:           "schedule",
+			oo:             ObjectOptions{Schedules: MustParseSchedule("Mon-Fri 09:00", nil)},
+			want:           []string{`schedule "" -> "Mon-Fri 09:00" (activation)`},
+			wantActivation: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := DiffObject(oi, tt.oo, nil)
+			got := d.Strings()
+			if len(got) == 0 && len(tt.want) == 0 {
+				got = nil
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("DiffObject() = %q, want %q", got, tt.want)
+			}
+			if d.RequiresActivation() != tt.wantActivation {
+				t.Errorf("ObjectDiff.RequiresActivation() = %v, want %v", d.RequiresActivation(), tt.wantActivation)
+			}
+		})
+	}
+}
+
+func TestDiffObject_normalized(t *testing.T) {
+	utc3 := time.FixedZone("UTC+3", 3*60*60)
+	oi := ObjectInfo{Tariff: "manual", Schedule: MustParseSchedule("Mon-Fri 01:00", nil)}
+
+	tests := []struct {
+		name string
+		oo   ObjectOptions
+		loc  *time.Location
+		want []string
+	}{
+		{"manual tariff", ObjectOptions{Tariff: TariffManual}, nil, nil},
+		// Mon-Fri 01:00 MSK - это Sun-Thu 22:00 UTC.
+		{"same schedule in other zone", ObjectOptions{Schedules: MustParseSchedule("Sun-Thu 22:00", time.UTC)}, nil, nil},
+		{"schedule in service zone", ObjectOptions{Schedules: MustParseSchedule("Mon-Fri 01:00", time.UTC)}, utc3,
+			[]string{`schedule "Mon-Fri 01:00" -> "Mon-Fri 04:00" (activation)`}},
+		{"destination time in service zone", ObjectOptions{Destinations: []DestinationOptions{{Text: "Клин", Lat: 56.33, Lon: 36.73,
+			ExpectedTime: time.Date(2019, 2, 20, 12, 0, 0, 0, MoscowLocation)}}}, time.UTC,
+			[]string{`destination[Клин] added "56.33000000,36.73000000 20.02.2019 09:00"`}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := DiffObject(oi, tt.oo, tt.loc).Strings()
+			if len(got) == 0 && len(tt.want) == 0 {
+				got = nil
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("DiffObject() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
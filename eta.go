@@ -0,0 +1,187 @@
+package movizor
+
+import (
+	"errors"
+	"time"
+)
+
+const (
+	// DefaultRoadFactor - отношение длины пути по дорогам к расстоянию по прямой по умолчанию.
+	DefaultRoadFactor = 1.3
+	// DefaultSpeedWindow - интервал для расчета средней скорости по умолчанию.
+	DefaultSpeedWindow = 2 * time.Hour
+	// DefaultETASpeed - скорость (км/ч), используемая, если наблюдаемую скорость определить нельзя.
+	DefaultETASpeed = 50.0
+	// DefaultMinETASpeed - наблюдаемая скорость (км/ч) ниже этой считается стоянкой.
+	DefaultMinETASpeed = 5.0
+	// DefaultArrivalRadius - расстояние (м) до точки назначения, при котором она считается достигнутой.
+	DefaultArrivalRadius = 1000.0
+)
+
+// ETAOptions предоставляет настройки локального расчета ETA.
+type ETAOptions struct {
+	RoadFactor    float64       // Коэффициент извилистости дорог. 0 - DefaultRoadFactor, 1 - расстояние по прямой
+	SpeedWindow   time.Duration // Интервал до последней точки для расчета средней скорости. 0 - DefaultSpeedWindow
+	DefaultSpeed  float64       // Скорость (км/ч) на случай стоянки или недостатка точек. 0 - DefaultETASpeed
+	MinSpeed      float64       // Скорость (км/ч), ниже которой объект считается стоящим. 0 - DefaultMinETASpeed
+	ArrivalRadius float64       // Радиус (м) достижения точки назначения. 0 - DefaultArrivalRadius
+	Now           time.Time     // Момент расчета. Нулевое значение - время последней точки
+}
+
+func (o ETAOptions) withDefaults() ETAOptions {
+	if o.RoadFactor <= 0 {
+		o.RoadFactor = DefaultRoadFactor
+	}
+	if o.SpeedWindow <= 0 {
+		o.SpeedWindow = DefaultSpeedWindow
+	}
+	if o.DefaultSpeed <= 0 {
+		o.DefaultSpeed = DefaultETASpeed
+	}
+	if o.MinSpeed <= 0 {
+		o.MinSpeed = DefaultMinETASpeed
+	}
+	if o.ArrivalRadius <= 0 {
+		o.ArrivalRadius = DefaultArrivalRadius
+	}
+	return o
+}
+
+// DestinationETA содержит прогноз прибытия в одну точку назначения.
+type DestinationETA struct {
+	Destination Destination   // Точка назначения
+	Distance    float64       // Оставшееся расстояние (м) с учетом коэффициента дорог и предыдущих точек
+	ETA         time.Time     // Прогноз времени прибытия, нулевое значение для достигнутых точек
+	Remaining   time.Duration // Прогноз оставшегося времени в пути
+	Status      ETAStatus     // OkETAStatus, LateETAStatus или FinishedETAStatus
+	Lateness    time.Duration // Прогноз опоздания относительно Destination.Time, 0 если успевает
+}
+
+// ETAEstimate содержит результат локального расчета ETA по объекту.
+type ETAEstimate struct {
+	Position     Position         // Последнее известное местоположение
+	Speed        float64          // Скорость (км/ч), использованная для расчета
+	Observed     bool             // Скорость определена по точкам, а не взята по умолчанию
+	Destinations []DestinationETA // Прогноз по всем точкам назначения в порядке следования
+}
+
+// Next возвращает прогноз по ближайшей недостигнутой точке назначения.
+func (e ETAEstimate) Next() (DestinationETA, bool) {
+	for _, d := range e.Destinations {
+		if d.Status != FinishedETAStatus {
+			return d, true
+		}
+	}
+	return DestinationETA{}, false
+}
+
+// Status возвращает общий статус ETA: FinishedETAStatus, если все точки достигнуты,
+// LateETAStatus, если объект не успевает хотя бы в одну точку, иначе OkETAStatus.
+func (e ETAEstimate) Status() ETAStatus {
+	status := FinishedETAStatus
+	for _, d := range e.Destinations {
+		switch d.Status {
+		case LateETAStatus:
+			return LateETAStatus
+		case OkETAStatus:
+			status = OkETAStatus
+		}
+	}
+	return status
+}
+
+// EstimateETA рассчитывает ETA по точкам назначения объекта на основе его последних
+// местоположений. Оставшееся расстояние считается по прямой между последовательными
+// точками назначения с учетом RoadFactor, скорость - средняя по треку за SpeedWindow.
+// Точки назначения со статусом FinishedETAStatus, а также точки, предшествующие точке,
+// в радиусе ArrivalRadius от последнего местоположения, считаются достигнутыми.
+func EstimateETA(dests []Destination, ps Positions, opts ETAOptions) (ETAEstimate, error) {
+	if len(ps) == 0 {
+		return ETAEstimate{}, errors.New("no positions to estimate ETA")
+	}
+	opts = opts.withDefaults()
+	ps = ps.Chronological()
+	last := ps[len(ps)-1]
+
+	e := ETAEstimate{Position: last, Speed: opts.DefaultSpeed}
+	from := last.Timestamp.Time().Add(-opts.SpeedWindow)
+	window := Positions{}
+	for _, p := range ps {
+		if !p.Timestamp.Time().Before(from) {
+			window = append(window, p)
+		}
+	}
+	if len(window) > 1 {
+		if dur := window[len(window)-1].Timestamp.Time().Sub(window[0].Timestamp.Time()); dur > 0 {
+			if speed := trackDistance(window) / 1000 / dur.Hours(); speed >= opts.MinSpeed {
+				e.Speed = speed
+				e.Observed = true
+			}
+		}
+	}
+
+	now := opts.Now
+	if now.IsZero() {
+		now = last.Timestamp.Time()
+	}
+
+	// Все точки до последней достигнутой также считаются достигнутыми.
+	reached := -1
+	for i, d := range dests {
+		if d.Status == FinishedETAStatus || Distance(last.Coordinates, d.Coordinates) <= opts.ArrivalRadius {
+			reached = i
+		}
+	}
+
+	point := last.Coordinates
+	var distance float64
+	for i, d := range dests {
+		de := DestinationETA{Destination: d}
+		if i <= reached {
+			de.Status = FinishedETAStatus
+			e.Destinations = append(e.Destinations, de)
+			continue
+		}
+
+		distance += Distance(point, d.Coordinates) * opts.RoadFactor
+		point = d.Coordinates
+		de.Distance = distance
+		de.Remaining = time.Duration(distance / 1000 / e.Speed * float64(time.Hour))
+		de.ETA = now.Add(de.Remaining)
+		de.Status = OkETAStatus
+		if !d.Time.IsZero() && de.ETA.After(d.Time) {
+			de.Status = LateETAStatus
+			de.Lateness = de.ETA.Sub(d.Time)
+		}
+		e.Destinations = append(e.Destinations, de)
+	}
+
+	return e, nil
+}
+
+// EstimateObjectETA рассчитывает ETA объекта локально, используя точки назначения из
+// GetObjectInfo и местоположения из GetObjectPositions за последние SpeedWindow.
+// Используется, когда МоВизор не возвращает distance_forecast_time и distance_forecast_status.
+func (api *API) EstimateObjectETA(o Object, opts ETAOptions) (ETAEstimate, error) {
+	oi, err := api.GetObjectInfo(o)
+	if err != nil {
+		return ETAEstimate{}, err
+	}
+
+	opts = opts.withDefaults()
+	ps, err := api.GetObjectPositions(o, &RequestPositionsOptions{
+		TimeFrom: time.Now().Add(-opts.SpeedWindow),
+	})
+	if err != nil {
+		return ETAEstimate{}, err
+	}
+	if len(ps) == 0 {
+		p, err := api.GetObjectLastPosition(o)
+		if err != nil {
+			return ETAEstimate{}, err
+		}
+		ps = Positions{p}
+	}
+
+	return EstimateETA(oi.Destination, ps, opts)
+}
@@ -0,0 +1,113 @@
+package movizor
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"math"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestEstimateETA(t *testing.T) {
+	d, err := ioutil.ReadFile(filepath.Join(dataPath, "object_get3.json"))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	var oi ObjectInfo
+	if err := json.Unmarshal(d, &oi); err != nil {
+		t.Fatalf("object_get3.json unmarshal error = %v", err)
+	}
+
+	// Движение на восток ~40 км/ч к последней точке назначения.
+	last := int64(1550574854)
+	ps := Positions{
+		testPosition(55.169521, 82.872594, last, 300),
+		testPosition(55.169521, 82.872594-0.3125, last-1800, 300),
+	}
+
+	tests := []struct {
+		name       string
+		opts       ETAOptions
+		wantStatus ETAStatus
+		observed   bool
+	}{
+		{
+			name:       "in time",
+			opts:       ETAOptions{},
+			wantStatus: OkETAStatus,
+			observed:   true,
+		},
+		{
+			name:       "late",
+			opts:       ETAOptions{Now: time.Date(2019, 2, 20, 11, 50, 0, 0, MoscowLocation)},
+			wantStatus: LateETAStatus,
+			observed:   true,
+		},
+		{
+			name:       "too slow uses default speed",
+			opts:       ETAOptions{MinSpeed: 100},
+			wantStatus: OkETAStatus,
+			observed:   false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, err := EstimateETA(oi.Destination, ps, tt.opts)
+			if err != nil {
+				t.Fatalf("EstimateETA() error = %v", err)
+			}
+			if len(e.Destinations) != 3 {
+				t.Fatalf("EstimateETA() destinations = %d, want 3", len(e.Destinations))
+			}
+			if e.Destinations[0].Status != FinishedETAStatus || e.Destinations[1].Status != FinishedETAStatus {
+				t.Errorf("EstimateETA() finished destinations = %+v", e.Destinations[:2])
+			}
+			if e.Observed != tt.observed {
+				t.Errorf("EstimateETA() observed = %v, want %v", e.Observed, tt.observed)
+			}
+			if e.Status() != tt.wantStatus {
+				t.Errorf("ETAEstimate.Status() = %v, want %v", e.Status(), tt.wantStatus)
+			}
+
+			next, ok := e.Next()
+			if !ok {
+				t.Fatal("ETAEstimate.Next() should return the last destination")
+			}
+			wantDist := Distance(ps[0].Coordinates, next.Destination.Coordinates) * DefaultRoadFactor
+			if math.Abs(next.Distance-wantDist) > 1 {
+				t.Errorf("DestinationETA.Distance = %v, want %v", next.Distance, wantDist)
+			}
+			wantRemaining := time.Duration(wantDist / 1000 / e.Speed * float64(time.Hour))
+			if next.Remaining != wantRemaining {
+				t.Errorf("DestinationETA.Remaining = %v, want %v", next.Remaining, wantRemaining)
+			}
+			if (next.Lateness > 0) != (tt.wantStatus == LateETAStatus) {
+				t.Errorf("DestinationETA.Lateness = %v", next.Lateness)
+			}
+		})
+	}
+}
+
+func TestEstimateETA_Arrived(t *testing.T) {
+	dests := []Destination{
+		{Text: "A", Coordinates: Coordinates{Lat: 55.0, Lon: 37.0}, Status: NewETAStatus},
+		{Text: "B", Coordinates: Coordinates{Lat: 55.5, Lon: 37.0}, Status: NewETAStatus},
+	}
+	ps := Positions{testPosition(55.501, 37.0, 1000, 100)}
+
+	e, err := EstimateETA(dests, ps, ETAOptions{})
+	if err != nil {
+		t.Fatalf("EstimateETA() error = %v", err)
+	}
+	if e.Status() != FinishedETAStatus {
+		t.Errorf("ETAEstimate.Status() = %v, want finished", e.Status())
+	}
+	if _, ok := e.Next(); ok {
+		t.Error("ETAEstimate.Next() should return false when all destinations are reached")
+	}
+
+	if _, err := EstimateETA(dests, nil, ETAOptions{}); err == nil {
+		t.Error("EstimateETA() expected error without positions")
+	}
+}
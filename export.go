@@ -0,0 +1,99 @@
+package movizor
+
+import (
+	"sort"
+	"time"
+)
+
+// Track представляет собой трек объекта для экспорта в GPX, KML и GeoJSON.
+type Track struct {
+	Phone        Object        // Номер телефона абонента
+	Title        string        // Название трека, например название объекта
+	Positions    Positions     // Местоположения в любом порядке, при экспорте упорядочиваются по времени
+	Destinations []Destination // Точки назначения, экспортируются как путевые точки
+}
+
+// NewTrack создает трек объекта по информации об объекте и списку местоположений,
+// полученному методом GetObjectPositions.
+func NewTrack(oi ObjectInfo, ps Positions) Track {
+	return Track{
+		Phone:        oi.Phone,
+		Title:        oi.Title,
+		Positions:    ps,
+		Destinations: oi.Destination,
+	}
+}
+
+// TracksFromObjectPositions группирует список местоположений объектов по номеру
+// телефона и возвращает по треку на каждый объект, упорядоченные по номеру.
+func TracksFromObjectPositions(ops ObjectPositions) []Track {
+	idx := make(map[string]int)
+	var tracks []Track
+	for _, op := range ops {
+		key := op.Phone.String()
+		i, ok := idx[key]
+		if !ok {
+			i = len(tracks)
+			idx[key] = i
+			tracks = append(tracks, Track{Phone: op.Phone, Title: key})
+		}
+		tracks[i].Positions = append(tracks[i].Positions, op.Position)
+	}
+
+	sort.Slice(tracks, func(i, j int) bool { return tracks[i].Phone.String() < tracks[j].Phone.String() })
+	return tracks
+}
+
+func (t Track) name() string {
+	if t.Title != "" {
+		return t.Title
+	}
+	return t.Phone.String()
+}
+
+// positionAttribute представляет собой атрибут местоположения для экспорта.
+type positionAttribute struct {
+	name  string
+	value string
+}
+
+// attributes возвращает непустые атрибуты местоположения в фиксированном порядке.
+func (p Position) attributes() []positionAttribute {
+	var attrs []positionAttribute
+	add := func(name, value string) {
+		if value != "" {
+			attrs = append(attrs, positionAttribute{name: name, value: value})
+		}
+	}
+
+	add("timestamp", formatExportTime(p.Timestamp))
+	add("timestamp_request", formatExportTime(p.TimestampRequest))
+	add("place", p.Place)
+	if p.Deviation != nil {
+		add("radius", p.Deviation.String())
+	}
+	if p.Distance != nil {
+		add("distance", p.Distance.String())
+	}
+	if p.ETA != nil {
+		add("eta", p.ETA.String())
+	}
+	if p.ETAStatus != nil {
+		add("eta_status", string(*p.ETAStatus))
+	}
+	return attrs
+}
+
+func formatExportTime(t Time) string {
+	if t.Time().Unix() == 0 {
+		return ""
+	}
+	return formatExportTimeValue(t.Time())
+}
+
+func formatExportTimeValue(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,314 @@
+package movizor
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CSVOptions предоставляет опции для выгрузки в CSV (RFC 4180).
+type CSVOptions struct {
+	Comma        rune           // Разделитель полей, по умолчанию ','. Для русского Excel используйте ';'
+	BOM          bool           // Записать UTF-8 BOM в начало файла, чтобы Excel распознал кодировку
+	Russian      bool           // Заголовки колонок и логические значения на русском
+	DecimalComma bool           // Дробная часть чисел отделяется запятой
+	Columns      []string       // Ключи и порядок колонок для выгрузки, пусто - все колонки
+	TimeFormat   string         // Формат времени, по умолчанию "02.01.2006 15:04:05"
+	Location     *time.Location // Часовой пояс для времени, по умолчанию time.Local, при импорте - MoscowLocation
+}
+
+// csvColumn описывает одну колонку выгрузки.
+type csvColumn struct {
+	key   string           // Ключ колонки, совпадает с именем поля в API
+	title string           // Заголовок на русском
+	value func(int) string // Значение колонки для строки с индексом
+}
+
+const (
+	csvMetadataPrefix    = "metadata:"
+	csvDestinationPrefix = "destination"
+)
+
+// WriteObjectsWithStatusCSV выгружает список объектов со статусами в CSV.
+// Колонки: phone, status.
+func WriteObjectsWithStatusCSV(w io.Writer, os ObjectsWithStatus, opts CSVOptions) error {
+	columns := []csvColumn{
+		{key: "phone", title: "Номер телефона абонента", value: func(i int) string { return os[i].Phone.String() }},
+		{key: "status", title: "Статус добавления для отслеживания", value: func(i int) string { return string(os[i].Status) }},
+	}
+	return opts.write(w, columns, len(os))
+}
+
+// WriteObjectInfosCSV выгружает список информации по объектам в CSV.
+// Колонки: phone, status, confirmed, title, tariff, tariff_new, last_timestamp, at_request,
+// current_lat, current_lon, distance, distance_forecast_time, distance_forecast_status, place,
+// on_parking, offline_time, pos_error, timestamp_off, timestamp_add.
+// Метаинформация выгружается отдельными колонками "metadata:<ключ>" (все сразу - ключ "metadata"),
+// точки назначения - колонками "destination<N>_text", "destination<N>_lat", "destination<N>_lon",
+// "destination<N>_time", "destination<N>_status" (все сразу - ключ "destination").
+func WriteObjectInfosCSV(w io.Writer, ois []ObjectInfo, opts CSVOptions) error {
+	f := opts.formatter()
+	columns := []csvColumn{
+		{key: "phone", title: "Номер абонента", value: func(i int) string { return ois[i].Phone.String() }},
+		{key: "status", title: "Статус", value: func(i int) string { return string(ois[i].Status) }},
+		{key: "confirmed", title: "Получено подтверждение от абонента", value: func(i int) string { return f.bool(ois[i].Confirmed) }},
+		{key: "title", title: "Имя абонента (название объекта)", value: func(i int) string { return ois[i].Title }},
+		{key: "tariff", title: "Текущий тарифный план", value: func(i int) string { return string(ois[i].Tariff) }},
+		{key: "tariff_new", title: "Новый тарифный план со следующего дня", value: func(i int) string {
+			if ois[i].TariffNew == nil {
+				return ""
+			}
+			return string(*ois[i].TariffNew)
+		}},
+		{key: "last_timestamp", title: "Время последнего запроса на определение местоположения", value: func(i int) string { return f.time(ois[i].LastTimestamp) }},
+		{key: "at_request", title: "Производится определение местоположения в данный момент", value: func(i int) string { return f.bool(ois[i].AtRequest) }},
+		{key: "current_lat", title: "Широта последнего местоположения", value: func(i int) string { return f.coordinate(ois[i].CurrentLat) }},
+		{key: "current_lon", title: "Долгота последнего местоположения", value: func(i int) string { return f.coordinate(ois[i].CurrentLon) }},
+	}
+	columns = append(columns, coordinatesAttributesCSVColumns(f, func(i int) CoordinatesAttributes { return ois[i].CoordinatesAttributes })...)
+	columns = append(columns, []csvColumn{
+		{key: "on_parking", title: "Находится ли объект на парковке", value: func(i int) string {
+			if ois[i].OnParking == nil {
+				return ""
+			}
+			return f.bool(*ois[i].OnParking)
+		}},
+		{key: "offline_time", title: "Время последнего известного местоположения", value: func(i int) string { return f.time(ois[i].OfflineTime) }},
+		{key: "pos_error", title: "Последнее местоположение не удалось определить", value: func(i int) string { return f.bool(ois[i].PosError) }},
+		{key: "timestamp_off", title: "Время автоматического отключения от мониторинга", value: func(i int) string { return f.time(ois[i].TimestampOff) }},
+		{key: "timestamp_add", title: "Время добавления объекта в Мовизор", value: func(i int) string { return f.time(ois[i].TimestampAdd) }},
+	}...)
+
+	keys := make(map[string]bool)
+	maxDest := 0
+	for _, oi := range ois {
+		for k := range oi.Metadata {
+			keys[k] = true
+		}
+		if len(oi.Destination) > maxDest {
+			maxDest = len(oi.Destination)
+		}
+	}
+	metaKeys := make([]string, 0, len(keys))
+	for k := range keys {
+		metaKeys = append(metaKeys, k)
+	}
+	sort.Strings(metaKeys)
+	for _, k := range metaKeys {
+		k := k
+		columns = append(columns, csvColumn{
+			key:   csvMetadataPrefix + k,
+			title: k,
+			value: func(i int) string { return ois[i].Metadata[k] },
+		})
+	}
+
+	for n := 0; n < maxDest; n++ {
+		n := n
+		dest := func(i int) (Destination, bool) {
+			if n >= len(ois[i].Destination) {
+				return Destination{}, false
+			}
+			return ois[i].Destination[n], true
+		}
+		prefix := fmt.Sprintf("%s%d_", csvDestinationPrefix, n+1)
+		title := fmt.Sprintf("Точка назначения %d: ", n+1)
+		columns = append(columns, []csvColumn{
+			{key: prefix + "text", title: title + "адрес", value: func(i int) string {
+				d, _ := dest(i)
+				return d.Text
+			}},
+			{key: prefix + "lat", title: title + "широта", value: func(i int) string {
+				if d, ok := dest(i); ok {
+					return f.coordinate(&d.Lat)
+				}
+				return ""
+			}},
+			{key: prefix + "lon", title: title + "долгота", value: func(i int) string {
+				if d, ok := dest(i); ok {
+					return f.coordinate(&d.Lon)
+				}
+				return ""
+			}},
+			{key: prefix + "time", title: title + "время прибытия", value: func(i int) string {
+				d, _ := dest(i)
+				return f.timeValue(d.Time)
+			}},
+			{key: prefix + "status", title: title + "статус", value: func(i int) string {
+				d, _ := dest(i)
+				return string(d.Status)
+			}},
+		}...)
+	}
+
+	return opts.write(w, columns, len(ois))
+}
+
+// WritePositionsCSV выгружает список местоположений в CSV в порядке следования в списке.
+// Колонки: lat, lon, timestamp, timestamp_request, radius, distance, distance_forecast_time,
+// distance_forecast_status, place.
+func WritePositionsCSV(w io.Writer, ps Positions, opts CSVOptions) error {
+	f := opts.formatter()
+	columns := []csvColumn{
+		{key: "lat", title: "Широта", value: func(i int) string { return f.coordinate(&ps[i].Lat) }},
+		{key: "lon", title: "Долгота", value: func(i int) string { return f.coordinate(&ps[i].Lon) }},
+		{key: "timestamp", title: "Время получения координат для этой точки", value: func(i int) string { return f.time(ps[i].Timestamp) }},
+		{key: "timestamp_request", title: "Время создания запроса на получение координат", value: func(i int) string { return f.time(ps[i].TimestampRequest) }},
+		{key: "radius", title: "Радиус погрешности (м)", value: func(i int) string { return f.int(ps[i].Deviation) }},
+	}
+	columns = append(columns, coordinatesAttributesCSVColumns(f, func(i int) CoordinatesAttributes { return ps[i].CoordinatesAttributes })...)
+	return opts.write(w, columns, len(ps))
+}
+
+func coordinatesAttributesCSVColumns(f csvFormatter, ca func(int) CoordinatesAttributes) []csvColumn {
+	return []csvColumn{
+		{key: "distance", title: "Остаток в км до конечной точки", value: func(i int) string { return f.int(ca(i).Distance) }},
+		{key: "distance_forecast_time", title: "Прогноз оставшегося времени до конечной точки", value: func(i int) string { return f.int(ca(i).ETA) }},
+		{key: "distance_forecast_status", title: "Прогноз успеваемости до конечной точки", value: func(i int) string {
+			if s := ca(i).ETAStatus; s != nil {
+				return string(*s)
+			}
+			return ""
+		}},
+		{key: "place", title: "Населенный пункт местоположения", value: func(i int) string { return ca(i).Place }},
+	}
+}
+
+// selectColumns отбирает колонки по ключам из opts.Columns. Ключи "metadata" и
+// "destination" выбирают все соответствующие динамические колонки.
+func (opts CSVOptions) selectColumns(columns []csvColumn) ([]csvColumn, error) {
+	if len(opts.Columns) == 0 {
+		return columns, nil
+	}
+
+	var selected []csvColumn
+	for _, key := range opts.Columns {
+		found := false
+		for _, c := range columns {
+			switch {
+			case c.key == key,
+				key == "metadata" && strings.HasPrefix(c.key, csvMetadataPrefix),
+				key == csvDestinationPrefix && strings.HasPrefix(c.key, csvDestinationPrefix):
+				selected = append(selected, c)
+				found = true
+			}
+		}
+		// Отсутствие динамических колонок допустимо: метаинформации или точек назначения может не быть.
+		if !found && key != "metadata" && key != csvDestinationPrefix &&
+			!strings.HasPrefix(key, csvMetadataPrefix) && !strings.HasPrefix(key, csvDestinationPrefix) {
+			return nil, fmt.Errorf("unknown csv column %q", key)
+		}
+	}
+	return selected, nil
+}
+
+func (opts CSVOptions) write(w io.Writer, columns []csvColumn, rows int) error {
+	columns, err := opts.selectColumns(columns)
+	if err != nil {
+		return err
+	}
+
+	if opts.BOM {
+		if _, err := io.WriteString(w, "\ufeff"); err != nil {
+			return err
+		}
+	}
+
+	cw := csv.NewWriter(w)
+	cw.UseCRLF = true
+	if opts.Comma != 0 {
+		cw.Comma = opts.Comma
+	}
+
+	record := make([]string, len(columns))
+	for i, c := range columns {
+		record[i] = c.key
+		if opts.Russian {
+			record[i] = c.title
+		}
+	}
+	if err := cw.Write(record); err != nil {
+		return err
+	}
+
+	for row := 0; row < rows; row++ {
+		for i, c := range columns {
+			record[i] = c.value(row)
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// csvFormatter форматирует значения ячеек в соответствии с CSVOptions.
+type csvFormatter struct {
+	opts CSVOptions
+}
+
+func (opts CSVOptions) formatter() csvFormatter {
+	if opts.TimeFormat == "" {
+		opts.TimeFormat = "02.01.2006 15:04:05"
+	}
+	if opts.Location == nil {
+		opts.Location = time.Local
+	}
+	return csvFormatter{opts: opts}
+}
+
+func (f csvFormatter) time(t Time) string {
+	if t.Time().Unix() == 0 {
+		return ""
+	}
+	return f.timeValue(t.Time())
+}
+
+func (f csvFormatter) timeValue(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.In(f.opts.Location).Format(f.opts.TimeFormat)
+}
+
+func (f csvFormatter) bool(b bool) string {
+	switch {
+	case f.opts.Russian && b:
+		return "да"
+	case f.opts.Russian:
+		return "нет"
+	}
+	return strconv.FormatBool(b)
+}
+
+func (f csvFormatter) coordinate(c *Coordinate) string {
+	if c == nil {
+		return ""
+	}
+	s := c.String()
+	if f.opts.DecimalComma {
+		s = strings.Replace(s, ".", ",", 1)
+	}
+	return s
+}
+
+func (f csvFormatter) money(v float64) string {
+	s := strconv.FormatFloat(v, 'f', 2, 64)
+	if f.opts.DecimalComma {
+		s = strings.Replace(s, ".", ",", 1)
+	}
+	return s
+}
+
+func (f csvFormatter) int(i *Int) string {
+	if i == nil {
+		return ""
+	}
+	return i.String()
+}
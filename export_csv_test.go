@@ -0,0 +1,142 @@
+package movizor
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteObjectsWithStatusCSV(t *testing.T) {
+	os := ObjectsWithStatus{
+		{Phone: "+7 (912) 345-67-87", Status: StatusOk},
+		{Phone: "79150003360", Status: StatusNew},
+	}
+
+	tests := []struct {
+		name    string
+		opts    CSVOptions
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "default",
+			opts: CSVOptions{},
+			want: "phone,status\r\n79123456787,ok\r\n79150003360,new\r\n",
+		},
+		{
+			name: "russian excel",
+			opts: CSVOptions{Comma: ';', BOM: true, Russian: true},
+			want: "\ufeffНомер телефона абонента;Статус добавления для отслеживания\r\n79123456787;ok\r\n79150003360;new\r\n",
+		},
+		{
+			name: "columns",
+			opts: CSVOptions{Columns: []string{"status"}},
+			want: "status\r\nok\r\nnew\r\n",
+		},
+		{
+			name:    "unknown column",
+			opts:    CSVOptions{Columns: []string{"title"}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			err := WriteObjectsWithStatusCSV(&buf, os, tt.opts)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("WriteObjectsWithStatusCSV() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr && buf.String() != tt.want {
+				t.Errorf("WriteObjectsWithStatusCSV() = %q, want %q", buf.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteObjectInfosCSV(t *testing.T) {
+	var ois []ObjectInfo
+	for _, file := range []string{"object_get1.json", "object_get2.json", "object_get3.json"} {
+		d, err := ioutil.ReadFile(filepath.Join(dataPath, file))
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		var oi ObjectInfo
+		if err := json.Unmarshal(d, &oi); err != nil {
+			t.Fatalf("%s unmarshal error = %v", file, err)
+		}
+		ois = append(ois, oi)
+	}
+
+	var buf bytes.Buffer
+	opts := CSVOptions{
+		Comma:        ';',
+		Russian:      true,
+		DecimalComma: true,
+		Columns:      []string{"phone", "on_parking", "metadata:Водитель", "destination"},
+		Location:     time.UTC,
+	}
+	if err := WriteObjectInfosCSV(&buf, ois, opts); err != nil {
+		t.Fatalf("WriteObjectInfosCSV() error = %v", err)
+	}
+
+	r := csv.NewReader(&buf)
+	r.Comma = ';'
+	records, err := r.ReadAll()
+	if err != nil {
+		t.Fatalf("WriteObjectInfosCSV() produced invalid csv: %v", err)
+	}
+	if len(records) != 4 {
+		t.Fatalf("WriteObjectInfosCSV() rows = %d, want 4", len(records))
+	}
+	// phone, on_parking, Водитель и по 5 колонок на каждую из 3 точек назначения
+	if len(records[0]) != 3+3*5 {
+		t.Fatalf("WriteObjectInfosCSV() columns = %d, want %d: %v", len(records[0]), 3+3*5, records[0])
+	}
+	if records[0][2] != "Водитель" || records[0][3] != "Точка назначения 1: адрес" {
+		t.Errorf("WriteObjectInfosCSV() header = %v", records[0])
+	}
+	if want := []string{"79630005272", "нет", ""}; !reflect.DeepEqual(records[1][:3], want) {
+		t.Errorf("WriteObjectInfosCSV() row 1 = %v, want %v", records[1][:3], want)
+	}
+	if records[1][4] != "55,89877900" || records[1][8] != "" {
+		t.Errorf("WriteObjectInfosCSV() row 1 destinations = %v", records[1][3:])
+	}
+	if records[2][1] != "" || records[2][2] != "Николай  Демидов" {
+		t.Errorf("WriteObjectInfosCSV() row 2 = %v", records[2])
+	}
+}
+
+func TestWritePositionsCSV(t *testing.T) {
+	d, err := ioutil.ReadFile(filepath.Join(dataPath, "pos_list.json"))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	var ps Positions
+	if err := json.Unmarshal(d, &ps); err != nil {
+		t.Fatalf("pos_list.json unmarshal error = %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := WritePositionsCSV(&buf, ps, CSVOptions{Location: time.UTC}); err != nil {
+		t.Fatalf("WritePositionsCSV() error = %v", err)
+	}
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\r\n"), "\r\n")
+	if len(lines) != len(ps)+1 {
+		t.Fatalf("WritePositionsCSV() lines = %d, want %d", len(lines), len(ps)+1)
+	}
+	want := "lat,lon,timestamp,timestamp_request,radius,distance,distance_forecast_time,distance_forecast_status,place"
+	if lines[0] != want {
+		t.Errorf("WritePositionsCSV() header = %s, want %s", lines[0], want)
+	}
+	want = "55.79835500,37.57949100,22.01.2019 13:54:35,22.01.2019 13:54:35,790,,,,Москва"
+	if lines[1] != want {
+		t.Errorf("WritePositionsCSV() row = %s, want %s", lines[1], want)
+	}
+}
@@ -0,0 +1,124 @@
+package movizor
+
+import (
+	"encoding/xml"
+	"io"
+)
+
+const (
+	gpxNamespace      = "http://www.topografix.com/GPX/1/1"
+	gpxMovizorNSURL   = "https://movizor.ru/xmlschemas/gpx/1"
+	gpxMovizorNSAlias = "movizor"
+)
+
+type gpxDocument struct {
+	XMLName   xml.Name      `xml:"gpx"`
+	Namespace string        `xml:"xmlns,attr"`
+	MovizorNS string        `xml:"xmlns:movizor,attr"`
+	Version   string        `xml:"version,attr"`
+	Creator   string        `xml:"creator,attr"`
+	Waypoints []gpxWaypoint `xml:"wpt"`
+	Tracks    []gpxTrack    `xml:"trk"`
+}
+
+type gpxWaypoint struct {
+	Lat        string         `xml:"lat,attr"`
+	Lon        string         `xml:"lon,attr"`
+	Time       string         `xml:"time,omitempty"`
+	Name       string         `xml:"name,omitempty"`
+	Desc       string         `xml:"desc,omitempty"`
+	Type       string         `xml:"type,omitempty"`
+	Extensions *gpxExtensions `xml:"extensions,omitempty"`
+}
+
+type gpxTrack struct {
+	Name     string       `xml:"name,omitempty"`
+	Src      string       `xml:"src,omitempty"`
+	Segments []gpxSegment `xml:"trkseg"`
+}
+
+type gpxSegment struct {
+	Points []gpxWaypoint `xml:"trkpt"`
+}
+
+type gpxExtensions struct {
+	Items []gpxExtension
+}
+
+type gpxExtension struct {
+	XMLName xml.Name
+	Value   string `xml:",chardata"`
+}
+
+func newGPXExtensions(attrs []positionAttribute) *gpxExtensions {
+	ext := &gpxExtensions{}
+	for _, a := range attrs {
+		if a.value == "" {
+			continue
+		}
+		ext.Items = append(ext.Items, gpxExtension{
+			XMLName: xml.Name{Local: gpxMovizorNSAlias + ":" + a.name},
+			Value:   a.value,
+		})
+	}
+	if len(ext.Items) == 0 {
+		return nil
+	}
+	return ext
+}
+
+// WriteGPX записывает треки в формате GPX 1.1. Каждое местоположение становится
+// точкой трека, место (Place) записывается в desc, а радиус погрешности, ETA и
+// прочие атрибуты - в extensions с пространством имен movizor.
+// Точки назначения записываются как путевые точки (wpt).
+func WriteGPX(w io.Writer, tracks ...Track) error {
+	doc := gpxDocument{
+		Namespace: gpxNamespace,
+		MovizorNS: gpxMovizorNSURL,
+		Version:   "1.1",
+		Creator:   "github.com/grender/movizor",
+	}
+
+	for _, t := range tracks {
+		for _, d := range t.Destinations {
+			doc.Waypoints = append(doc.Waypoints, gpxWaypoint{
+				Lat:  d.Lat.String(),
+				Lon:  d.Lon.String(),
+				Time: formatExportTimeValue(d.Time),
+				Name: d.Text,
+				Desc: t.name(),
+				Type: "destination",
+				Extensions: newGPXExtensions([]positionAttribute{
+					{name: "status", value: string(d.Status)},
+				}),
+			})
+		}
+
+		seg := gpxSegment{}
+		for _, p := range t.Positions.Chronological() {
+			seg.Points = append(seg.Points, gpxWaypoint{
+				Lat:        p.Lat.String(),
+				Lon:        p.Lon.String(),
+				Time:       formatExportTime(p.Timestamp),
+				Desc:       p.Place,
+				Extensions: newGPXExtensions(p.attributes()),
+			})
+		}
+		doc.Tracks = append(doc.Tracks, gpxTrack{
+			Name:     t.name(),
+			Src:      t.Phone.String(),
+			Segments: []gpxSegment{seg},
+		})
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
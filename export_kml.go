@@ -0,0 +1,127 @@
+package movizor
+
+import (
+	"encoding/xml"
+	"io"
+	"strings"
+)
+
+const kmlNamespace = "http://www.opengis.net/kml/2.2"
+
+type kmlDocument struct {
+	XMLName   xml.Name     `xml:"kml"`
+	Namespace string       `xml:"xmlns,attr"`
+	Document  kmlContainer `xml:"Document"`
+}
+
+type kmlContainer struct {
+	Name       string         `xml:"name,omitempty"`
+	Folders    []kmlContainer `xml:"Folder,omitempty"`
+	Placemarks []kmlPlacemark `xml:"Placemark,omitempty"`
+}
+
+type kmlPlacemark struct {
+	Name         string           `xml:"name,omitempty"`
+	Description  string           `xml:"description,omitempty"`
+	TimeStamp    *kmlTimeStamp    `xml:"TimeStamp,omitempty"`
+	ExtendedData *kmlExtendedData `xml:"ExtendedData,omitempty"`
+	Point        *kmlGeometry     `xml:"Point,omitempty"`
+	LineString   *kmlGeometry     `xml:"LineString,omitempty"`
+}
+
+type kmlTimeStamp struct {
+	When string `xml:"when"`
+}
+
+type kmlGeometry struct {
+	Coordinates string `xml:"coordinates"`
+}
+
+type kmlExtendedData struct {
+	Data []kmlData `xml:"Data"`
+}
+
+type kmlData struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value"`
+}
+
+func newKMLExtendedData(attrs []positionAttribute) *kmlExtendedData {
+	ed := &kmlExtendedData{}
+	for _, a := range attrs {
+		if a.value != "" {
+			ed.Data = append(ed.Data, kmlData{Name: a.name, Value: a.value})
+		}
+	}
+	if len(ed.Data) == 0 {
+		return nil
+	}
+	return ed
+}
+
+func kmlCoordinates(c Coordinates) string {
+	return c.Lon.String() + "," + c.Lat.String()
+}
+
+// WriteKML записывает треки в формате KML 2.2. Для каждого трека создается папка
+// с линией маршрута (LineString), отметками местоположений (Placemark) с временем,
+// местом и атрибутами в ExtendedData, а также отметками точек назначения.
+func WriteKML(w io.Writer, tracks ...Track) error {
+	doc := kmlDocument{
+		Namespace: kmlNamespace,
+		Document:  kmlContainer{Name: "movizor"},
+	}
+
+	for _, t := range tracks {
+		folder := kmlContainer{Name: t.name()}
+
+		ps := t.Positions.Chronological()
+		if len(ps) > 1 {
+			coords := make([]string, 0, len(ps))
+			for _, p := range ps {
+				coords = append(coords, kmlCoordinates(p.Coordinates))
+			}
+			folder.Placemarks = append(folder.Placemarks, kmlPlacemark{
+				Name:       t.name(),
+				LineString: &kmlGeometry{Coordinates: strings.Join(coords, " ")},
+			})
+		}
+
+		for _, p := range ps {
+			pm := kmlPlacemark{
+				Description:  p.Place,
+				ExtendedData: newKMLExtendedData(p.attributes()),
+				Point:        &kmlGeometry{Coordinates: kmlCoordinates(p.Coordinates)},
+			}
+			if ts := formatExportTime(p.Timestamp); ts != "" {
+				pm.TimeStamp = &kmlTimeStamp{When: ts}
+			}
+			folder.Placemarks = append(folder.Placemarks, pm)
+		}
+
+		for _, d := range t.Destinations {
+			folder.Placemarks = append(folder.Placemarks, kmlPlacemark{
+				Name: d.Text,
+				ExtendedData: newKMLExtendedData([]positionAttribute{
+					{name: "type", value: "destination"},
+					{name: "time", value: formatExportTimeValue(d.Time)},
+					{name: "status", value: string(d.Status)},
+				}),
+				Point: &kmlGeometry{Coordinates: kmlCoordinates(d.Coordinates)},
+			})
+		}
+
+		doc.Document.Folders = append(doc.Document.Folders, folder)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
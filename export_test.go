@@ -0,0 +1,153 @@
+package movizor
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func testTrack(t *testing.T) Track {
+	var oi ObjectInfo
+	var ps Positions
+	for file, v := range map[string]interface{}{"object_get3.json": &oi, "pos_list.json": &ps} {
+		d, err := ioutil.ReadFile(filepath.Join(dataPath, file))
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if err := json.Unmarshal(d, v); err != nil {
+			t.Fatalf("%s unmarshal error = %v", file, err)
+		}
+	}
+	return NewTrack(oi, ps)
+}
+
+func TestWriteGPX(t *testing.T) {
+	tr := testTrack(t)
+	var buf bytes.Buffer
+	if err := WriteGPX(&buf, tr); err != nil {
+		t.Fatalf("WriteGPX() error = %v", err)
+	}
+
+	var doc struct {
+		Version   string `xml:"version,attr"`
+		Waypoints []struct {
+			Name string `xml:"name"`
+		} `xml:"wpt"`
+		Points []struct {
+			Lat  string `xml:"lat,attr"`
+			Time string `xml:"time"`
+			Desc string `xml:"desc"`
+		} `xml:"trk>trkseg>trkpt"`
+	}
+	if err := xml.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("WriteGPX() produced invalid xml: %v", err)
+	}
+	if doc.Version != "1.1" {
+		t.Errorf("WriteGPX() version = %s, want 1.1", doc.Version)
+	}
+	if len(doc.Waypoints) != len(tr.Destinations) {
+		t.Errorf("WriteGPX() waypoints = %d, want %d", len(doc.Waypoints), len(tr.Destinations))
+	}
+	if len(doc.Points) != len(tr.Positions) {
+		t.Fatalf("WriteGPX() track points = %d, want %d", len(doc.Points), len(tr.Positions))
+	}
+	if doc.Points[0].Time > doc.Points[len(doc.Points)-1].Time {
+		t.Error("WriteGPX() track points are not in chronological order")
+	}
+	first := tr.Positions.Chronological()[0]
+	if doc.Points[0].Desc != first.Place || doc.Points[0].Lat != first.Lat.String() {
+		t.Errorf("WriteGPX() first point = %+v", doc.Points[0])
+	}
+	if !strings.Contains(buf.String(), "<movizor:radius>790</movizor:radius>") {
+		t.Error("WriteGPX() radius extension is not written")
+	}
+}
+
+func TestWriteKML(t *testing.T) {
+	tr := testTrack(t)
+	var buf bytes.Buffer
+	if err := WriteKML(&buf, tr); err != nil {
+		t.Fatalf("WriteKML() error = %v", err)
+	}
+
+	var doc struct {
+		Placemarks []struct {
+			Name       string `xml:"name"`
+			LineString *struct {
+				Coordinates string `xml:"coordinates"`
+			} `xml:"LineString"`
+		} `xml:"Document>Folder>Placemark"`
+	}
+	if err := xml.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("WriteKML() produced invalid xml: %v", err)
+	}
+	want := 1 + len(tr.Positions) + len(tr.Destinations)
+	if len(doc.Placemarks) != want {
+		t.Fatalf("WriteKML() placemarks = %d, want %d", len(doc.Placemarks), want)
+	}
+	if doc.Placemarks[0].LineString == nil {
+		t.Fatal("WriteKML() first placemark should be a LineString")
+	}
+	if n := len(strings.Fields(doc.Placemarks[0].LineString.Coordinates)); n != len(tr.Positions) {
+		t.Errorf("WriteKML() LineString points = %d, want %d", n, len(tr.Positions))
+	}
+}
+
+func TestWriteGeoJSON(t *testing.T) {
+	tr := testTrack(t)
+	var buf bytes.Buffer
+	if err := WriteGeoJSON(&buf, tr); err != nil {
+		t.Fatalf("WriteGeoJSON() error = %v", err)
+	}
+
+	var fc struct {
+		Type     string `json:"type"`
+		Features []struct {
+			Geometry struct {
+				Type        string          `json:"type"`
+				Coordinates json.RawMessage `json:"coordinates"`
+			} `json:"geometry"`
+			Properties map[string]interface{} `json:"properties"`
+		} `json:"features"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &fc); err != nil {
+		t.Fatalf("WriteGeoJSON() produced invalid json: %v", err)
+	}
+	kinds := map[string]int{}
+	for _, f := range fc.Features {
+		kinds[f.Properties["kind"].(string)]++
+	}
+	if kinds["track"] != 1 || kinds["position"] != len(tr.Positions) || kinds["destination"] != len(tr.Destinations) {
+		t.Errorf("WriteGeoJSON() feature kinds = %v", kinds)
+	}
+	p := fc.Features[len(fc.Features)-len(tr.Destinations)-1]
+	var coords []float64
+	if err := json.Unmarshal(p.Geometry.Coordinates, &coords); err != nil {
+		t.Fatalf("WriteGeoJSON() point coordinates: %v", err)
+	}
+	if p.Geometry.Type != "Point" || coords[0] != 37.579491 || coords[1] != 55.798355 {
+		t.Errorf("WriteGeoJSON() point geometry = %s %v", p.Geometry.Type, coords)
+	}
+	if p.Properties["radius"] != 790.0 || p.Properties["place"] != "Москва" {
+		t.Errorf("WriteGeoJSON() point properties = %v", p.Properties)
+	}
+}
+
+func TestTracksFromObjectPositions(t *testing.T) {
+	ops := ObjectPositions{
+		{Phone: "79630005273", Position: testPosition(55.1, 37.1, 100, 0)},
+		{Phone: "79630005272", Position: testPosition(55.0, 37.0, 100, 0)},
+		{Phone: "+7 963 000-52-73", Position: testPosition(55.2, 37.2, 200, 0)},
+	}
+	tracks := TracksFromObjectPositions(ops)
+	if len(tracks) != 2 {
+		t.Fatalf("TracksFromObjectPositions() len = %d, want 2", len(tracks))
+	}
+	if tracks[0].Phone.String() != "79630005272" || len(tracks[1].Positions) != 2 {
+		t.Errorf("TracksFromObjectPositions() = %+v", tracks)
+	}
+}
@@ -0,0 +1,339 @@
+package movizor
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultSyncConcurrency - количество одновременных запросов к сервису при синхронизации по умолчанию.
+const DefaultSyncConcurrency = 4
+
+// DesiredObject описывает желаемое состояние объекта мониторинга.
+// Незаданные (нулевые) поля Options не сравниваются с текущим состоянием, так как
+// EditObject не передает их в сервис и не может их сбросить.
+type DesiredObject struct {
+	Object  Object
+	Options ObjectOptions
+}
+
+// SyncActionType представляет собой тип действия синхронизации.
+type SyncActionType string
+
+const (
+	SyncAdd          SyncActionType = "add"           // Подключение объекта (AddObject)
+	SyncEdit         SyncActionType = "edit"          // Изменение опций со следующих суток (EditObject)
+	SyncEditActivate SyncActionType = "edit_activate" // Изменение опций с немедленной активацией (EditObjectWithActivate)
+	SyncDelete       SyncActionType = "delete"        // Удаление объекта (DeleteObject)
+	SyncReactivate   SyncActionType = "reactivate"    // Повторное подключение объекта (ReactivateObject)
+)
+
+// SyncAction - одно запланированное действие синхронизации.
+type SyncAction struct {
+	Type    SyncActionType
+	Object  Object
+	Options *ObjectOptions // Опции для SyncAdd, SyncEdit и SyncEditActivate
+	Changes []string       // Описание изменений для отчета
+}
+
+// String возвращает строку отчета о действии, например "~ 79210010203 edit: title "A" -> "B"".
+func (a SyncAction) String() string {
+	sign := map[SyncActionType]string{
+		SyncAdd:          "+",
+		SyncEdit:         "~",
+		SyncEditActivate: "~",
+		SyncDelete:       "-",
+		SyncReactivate:   "^",
+	}[a.Type]
+
+	s := fmt.Sprintf("%s %s %s", sign, a.Object, a.Type)
+	if len(a.Changes) > 0 {
+		s += ": " + strings.Join(a.Changes, "; ")
+	}
+	return s
+}
+
+// SyncPlan - план синхронизации: действия в порядке выполнения.
+type SyncPlan struct {
+	Actions []SyncAction
+}
+
+// IsEmpty возвращает true, если текущее состояние совпадает с желаемым.
+func (p SyncPlan) IsEmpty() bool {
+	return len(p.Actions) == 0
+}
+
+// WriteTo выводит план в виде построчного отчета об изменениях.
+func (p SyncPlan) WriteTo(w io.Writer) (int64, error) {
+	var n int64
+	for _, a := range p.Actions {
+		m, err := fmt.Fprintln(w, a)
+		n += int64(m)
+		if err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// String возвращает план в виде построчного отчета об изменениях.
+func (p SyncPlan) String() string {
+	var buf bytes.Buffer
+	_, _ = p.WriteTo(&buf)
+	return buf.String()
+}
+
+// SyncResult - результат выполнения действия синхронизации.
+type SyncResult struct {
+	Action   SyncAction
+	Response APIResponse
+	Err      error
+}
+
+// FleetSyncOptions предоставляет настройки синхронизации.
+type FleetSyncOptions struct {
+	Delete      bool // Удалять объекты, отсутствующие в желаемом состоянии
+	Activate    bool // Применять изменения, которые иначе вступят в силу со следующих суток, немедленно
+	Reactivate  bool // Повторно подключать объекты после автоматического отключения (StatusOff)
+	DryRun      bool // Только планировать, не выполняя изменения
+	Concurrency int  // Количество одновременных запросов. 0 - DefaultSyncConcurrency
+	// Location - часовой пояс сервиса, в котором сравниваются расписания запросов (см. DiffObject).
+	// nil - API.Location в FleetSync.Plan, MoscowLocation в PlanFleetSync.
+	Location *time.Location
+}
+
+// FleetSync приводит список объектов мониторинга в сервисе к желаемому состоянию,
+// например к списку водителей из собственной базы данных.
+type FleetSync struct {
+	api  *API
+	opts FleetSyncOptions
+}
+
+// NewFleetSync создает синхронизацию объектов мониторинга для api.
+func NewFleetSync(api *API, opts FleetSyncOptions) *FleetSync {
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = DefaultSyncConcurrency
+	}
+	return &FleetSync{api: api, opts: opts}
+}
+
+// Plan получает текущее состояние через GetObjects и GetObjectInfo и планирует действия
+// для приведения его к желаемому.
+func (fs *FleetSync) Plan(desired []DesiredObject) (SyncPlan, error) {
+	current, err := fs.api.GetObjects()
+	if err != nil {
+		return SyncPlan{}, err
+	}
+
+	index, err := desiredIndex(desired)
+	if err != nil {
+		return SyncPlan{}, err
+	}
+
+	var known []Object
+	for _, st := range current {
+		if _, ok := index[st.Phone.String()]; ok {
+			known = append(known, st.Phone)
+		}
+	}
+
+	infos := make(map[string]ObjectInfo, len(known))
+	var mu sync.Mutex
+	errs := parallel(len(known), fs.opts.Concurrency, func(i int) error {
+		oi, err := fs.api.GetObjectInfo(known[i])
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		infos[known[i].String()] = oi
+		mu.Unlock()
+		return nil
+	})
+	for _, err := range errs {
+		if err != nil {
+			return SyncPlan{}, err
+		}
+	}
+
+	opts := fs.opts
+	if opts.Location == nil {
+		opts.Location = fs.api.Location
+	}
+	return PlanFleetSync(current, infos, desired, opts)
+}
+
+// Apply выполняет действия плана, не более Concurrency одновременно. Результаты
+// возвращаются в порядке действий плана. В режиме DryRun действия не выполняются.
+func (fs *FleetSync) Apply(plan SyncPlan) []SyncResult {
+	results := make([]SyncResult, len(plan.Actions))
+	for i, a := range plan.Actions {
+		results[i].Action = a
+	}
+	if fs.opts.DryRun {
+		return results
+	}
+
+	// Действия одного типа выполняются параллельно, типы - по порядку плана,
+	// чтобы повторное подключение объекта завершилось до изменения его опций.
+	for from := 0; from < len(plan.Actions); {
+		to := from
+		for to < len(plan.Actions) && syncPhase(plan.Actions[to].Type) == syncPhase(plan.Actions[from].Type) {
+			to++
+		}
+		actions := plan.Actions[from:to]
+		errs := parallel(len(actions), fs.opts.Concurrency, func(i int) error {
+			resp, err := fs.apply(actions[i])
+			results[from+i].Response = resp
+			return err
+		})
+		for i, err := range errs {
+			results[from+i].Err = err
+		}
+		from = to
+	}
+	return results
+}
+
+func syncPhase(t SyncActionType) SyncActionType {
+	if t == SyncEditActivate {
+		return SyncEdit
+	}
+	return t
+}
+
+// Sync планирует и выполняет синхронизацию. План выводится в w, если он не nil.
+func (fs *FleetSync) Sync(desired []DesiredObject, w io.Writer) (SyncPlan, []SyncResult, error) {
+	plan, err := fs.Plan(desired)
+	if err != nil {
+		return SyncPlan{}, nil, err
+	}
+	if w != nil {
+		if _, err := plan.WriteTo(w); err != nil {
+			return plan, nil, err
+		}
+	}
+	return plan, fs.Apply(plan), nil
+}
+
+func (fs *FleetSync) apply(a SyncAction) (APIResponse, error) {
+	switch a.Type {
+	case SyncAdd:
+		return fs.api.AddObject(a.Object, a.Options)
+	case SyncEdit:
+		return fs.api.EditObjectWithActivate(a.Object, a.Options, false)
+	case SyncEditActivate:
+		return fs.api.EditObjectWithActivate(a.Object, a.Options, true)
+	case SyncDelete:
+		return fs.api.DeleteObject(a.Object)
+	case SyncReactivate:
+		return fs.api.ReactivateObject(a.Object)
+	}
+	return APIResponse{}, fmt.Errorf("unknown sync action %s", a.Type)
+}
+
+// parallel вызывает f для индексов [0, n), не более concurrency одновременно,
+// и возвращает ошибки по индексам.
+func parallel(n, concurrency int, f func(i int) error) []error {
+	errs := make([]error, n)
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			errs[i] = f(i)
+		}(i)
+	}
+	wg.Wait()
+	return errs
+}
+
+// PlanFleetSync планирует синхронизацию по уже полученному состоянию: current - результат
+// GetObjects, infos - результаты GetObjectInfo по нормализованному номеру (Object.String())
+// для объектов, присутствующих и в current, и в desired.
+// Действия упорядочены: удаления, повторные подключения, изменения, добавления.
+func PlanFleetSync(current ObjectsWithStatus, infos map[string]ObjectInfo, desired []DesiredObject, opts FleetSyncOptions) (SyncPlan, error) {
+	index, err := desiredIndex(desired)
+	if err != nil {
+		return SyncPlan{}, err
+	}
+
+	var plan SyncPlan
+	seen := make(map[string]bool, len(current))
+	var deletes, reactivates, edits []SyncAction
+	for _, st := range current {
+		phone := st.Phone.String()
+		seen[phone] = true
+		d, ok := index[phone]
+		if !ok {
+			if opts.Delete {
+				deletes = append(deletes, SyncAction{Type: SyncDelete, Object: st.Phone})
+			}
+			continue
+		}
+
+		if st.Status == StatusOff && opts.Reactivate {
+			reactivates = append(reactivates, SyncAction{Type: SyncReactivate, Object: st.Phone,
+				Changes: []string{fmt.Sprintf("status %s", st.Status)}})
+		}
+
+		oi, ok := infos[phone]
+		if !ok {
+			return SyncPlan{}, fmt.Errorf("no object info for %s", phone)
+		}
+		diff := DiffObject(oi, d.Options, opts.Location)
+		if diff.IsEmpty() {
+			continue
+		}
+		a := SyncAction{Type: SyncEdit, Object: st.Phone, Options: optionsPtr(d.Options), Changes: diff.Strings()}
+		if diff.RequiresActivation() && opts.Activate {
+			a.Type = SyncEditActivate
+		}
+		edits = append(edits, a)
+	}
+
+	var adds []SyncAction
+	for _, d := range desired {
+		if seen[d.Object.String()] {
+			continue
+		}
+		a := SyncAction{Type: SyncAdd, Object: d.Object, Options: optionsPtr(d.Options)}
+		if d.Options.Title != "" {
+			a.Changes = []string{fmt.Sprintf("title %q", d.Options.Title)}
+		}
+		adds = append(adds, a)
+	}
+
+	for _, actions := range [][]SyncAction{deletes, reactivates, edits, adds} {
+		sort.SliceStable(actions, func(i, j int) bool { return actions[i].Object.String() < actions[j].Object.String() })
+		plan.Actions = append(plan.Actions, actions...)
+	}
+	return plan, nil
+}
+
+func optionsPtr(oo ObjectOptions) *ObjectOptions {
+	return &oo
+}
+
+func desiredIndex(desired []DesiredObject) (map[string]DesiredObject, error) {
+	index := make(map[string]DesiredObject, len(desired))
+	for _, d := range desired {
+		phone := d.Object.String()
+		if phone == "" {
+			return nil, fmt.Errorf("invalid format of phone number: %s", string(d.Object))
+		}
+		if _, ok := index[phone]; ok {
+			return nil, fmt.Errorf("duplicate object %s", phone)
+		}
+		index[phone] = d
+	}
+	return index, nil
+}
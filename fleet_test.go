@@ -0,0 +1,107 @@
+package movizor
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPlanFleetSync(t *testing.T) {
+	current := ObjectsWithStatus{
+		{Phone: "79210010201", Status: StatusOk},
+		{Phone: "79210010202", Status: StatusOff},
+		{Phone: "79210010203", Status: StatusOk},
+	}
+	infos := map[string]ObjectInfo{
+		"79210010201": {Phone: "79210010201", Title: "Иванов", Tariff: TariffEvery30},
+		"79210010202": {Phone: "79210010202", Title: "Петров", Tariff: TariffEvery30},
+	}
+	desired := []DesiredObject{
+		{Object: "+7 (921) 001-02-01", Options: ObjectOptions{Title: "Иванов", Tariff: TariffEvery60}},
+		{Object: "79210010202", Options: ObjectOptions{Title: "Петров"}},
+		{Object: "8-921-001-02-04", Options: ObjectOptions{Title: "Сидоров", Tariff: TariffEvery30}},
+	}
+
+	tests := []struct {
+		name string
+		opts FleetSyncOptions
+		want []string
+	}{
+		{
+			name: "add and edit",
+			opts: FleetSyncOptions{},
+			want: []string{
+				`~ 79210010201 edit: tariff "30" -> "60" (activation)`,
+				`+ 79210010204 add: title "Сидоров"`,
+			},
+		},
+		{
+			name: "delete, reactivate and activate",
+			opts: FleetSyncOptions{Delete: true, Reactivate: true, Activate: true},
+			want: []string{
+				`- 79210010203 delete`,
+				`^ 79210010202 reactivate: status off`,
+				`~ 79210010201 edit_activate: tariff "30" -> "60" (activation)`,
+				`+ 79210010204 add: title "Сидоров"`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan, err := PlanFleetSync(current, infos, desired, tt.opts)
+			if err != nil {
+				t.Fatalf("PlanFleetSync() error = %v", err)
+			}
+			got := strings.Split(strings.TrimSpace(plan.String()), "\n")
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("PlanFleetSync() =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
+
+func TestPlanFleetSync_errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		current ObjectsWithStatus
+		desired []DesiredObject
+	}{
+		{
+			name:    "duplicate",
+			desired: []DesiredObject{{Object: "79210010201"}, {Object: "+79210010201"}},
+		},
+		{
+			name:    "invalid phone",
+			desired: []DesiredObject{{Object: "123"}},
+		},
+		{
+			name:    "missing info",
+			current: ObjectsWithStatus{{Phone: "79210010201", Status: StatusOk}},
+			desired: []DesiredObject{{Object: "79210010201"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := PlanFleetSync(tt.current, nil, tt.desired, FleetSyncOptions{}); err == nil {
+				t.Error("PlanFleetSync() error = nil, want error")
+			}
+		})
+	}
+}
+
+func TestFleetSync_Plan_manualTariff(t *testing.T) {
+	api := poolTestAPI(t, "main", map[string]string{
+		"object_list": `[{"phone":"79630005272","status":"ok"}]`,
+		"object_get":  `{"phone":"79630005272","status":"ok","title":"Иванов","tariff":"manual","tariff_new":null}`,
+	}, nil)
+
+	// object_get возвращает "manual" для тарифа TariffManual.
+	plan, err := NewFleetSync(api, FleetSyncOptions{}).Plan([]DesiredObject{
+		{Object: "79630005272", Options: ObjectOptions{Title: "Иванов", Tariff: TariffManual}},
+	})
+	if err != nil {
+		t.Fatalf("FleetSync.Plan() error = %v", err)
+	}
+	if len(plan.Actions) != 0 {
+		t.Errorf("FleetSync.Plan() =\n%s\nwant no actions", plan)
+	}
+}
@@ -0,0 +1,74 @@
+package movizor
+
+import "math"
+
+// earthRadius - средний радиус Земли в метрах.
+const earthRadius = 6371008.8
+
+func degToRad(d float64) float64 {
+	return d * math.Pi / 180
+}
+
+// Distance возвращает расстояние в метрах между двумя гео-координатами
+// по дуге большого круга (формула гаверсинусов).
+func Distance(a, b Coordinates) float64 {
+	lat1, lat2 := degToRad(a.Lat.Float64()), degToRad(b.Lat.Float64())
+	dLat := lat2 - lat1
+	dLon := degToRad(b.Lon.Float64() - a.Lon.Float64())
+
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadius * math.Asin(math.Min(1, math.Sqrt(h)))
+}
+
+// DistanceTo возвращает расстояние в метрах до указанной точки.
+func (c Coordinates) DistanceTo(o Coordinates) float64 {
+	return Distance(c, o)
+}
+
+// planePoint - точка в локальной плоской проекции (метры).
+type planePoint struct {
+	x, y float64
+}
+
+// localProjection - равнопромежуточная проекция вокруг опорной точки.
+// Погрешность приемлема на расстояниях в десятки километров, чего достаточно
+// для геозон и упрощения треков.
+type localProjection struct {
+	lat0, lon0 float64
+	kx         float64
+}
+
+func newLocalProjection(origin Coordinates) localProjection {
+	return localProjection{
+		lat0: origin.Lat.Float64(),
+		lon0: origin.Lon.Float64(),
+		kx:   math.Cos(degToRad(origin.Lat.Float64())),
+	}
+}
+
+func (p localProjection) project(c Coordinates) planePoint {
+	return planePoint{
+		x: degToRad(c.Lon.Float64()-p.lon0) * p.kx * earthRadius,
+		y: degToRad(c.Lat.Float64()-p.lat0) * earthRadius,
+	}
+}
+
+// segmentDistance возвращает расстояние от точки p до отрезка ab.
+func segmentDistance(p, a, b planePoint) float64 {
+	dx, dy := b.x-a.x, b.y-a.y
+	if dx == 0 && dy == 0 {
+		return math.Hypot(p.x-a.x, p.y-a.y)
+	}
+
+	t := ((p.x-a.x)*dx + (p.y-a.y)*dy) / (dx*dx + dy*dy)
+	t = math.Max(0, math.Min(1, t))
+	return math.Hypot(p.x-(a.x+t*dx), p.y-(a.y+t*dy))
+}
+
+func (p localProjection) unproject(pp planePoint) Coordinates {
+	return Coordinates{
+		Lat: Coordinate(p.lat0 + pp.y/earthRadius*180/math.Pi),
+		Lon: Coordinate(p.lon0 + pp.x/(p.kx*earthRadius)*180/math.Pi),
+	}
+}
@@ -0,0 +1,352 @@
+package movizor
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// GeofenceShape представляет собой форму геозоны.
+type GeofenceShape string
+
+const (
+	CircleGeofence  GeofenceShape = "circle"  // Круг с центром и радиусом
+	PolygonGeofence GeofenceShape = "polygon" // Многоугольник, возможно с отверстиями
+)
+
+// Geofence представляет собой собственную геозону, не связанную с
+// точками назначения и маршрутами МоВизора.
+type Geofence struct {
+	ID     string          // Уникальный идентификатор геозоны
+	Title  string          // Название геозоны
+	Tags   []string        // Метки геозоны
+	Shape  GeofenceShape   // Форма геозоны
+	Center Coordinates     // Центр круга (для CircleGeofence)
+	Radius float64         // Радиус круга в метрах (для CircleGeofence)
+	Rings  [][]Coordinates // Внешний контур и отверстия многоугольника (для PolygonGeofence)
+}
+
+// NewCircleGeofence создает круглую геозону с радиусом в метрах.
+func NewCircleGeofence(id string, center Coordinates, radius float64) (Geofence, error) {
+	g := Geofence{
+		ID:     id,
+		Shape:  CircleGeofence,
+		Center: center,
+		Radius: radius,
+	}
+	if err := g.Validate(); err != nil {
+		return Geofence{}, err
+	}
+	return g, nil
+}
+
+// NewPolygonGeofence создает геозону в виде многоугольника. Первым передается
+// внешний контур, остальные контуры считаются отверстиями.
+func NewPolygonGeofence(id string, rings ...[]Coordinates) (Geofence, error) {
+	g := Geofence{
+		ID:    id,
+		Shape: PolygonGeofence,
+		Rings: rings,
+	}
+	if err := g.Validate(); err != nil {
+		return Geofence{}, err
+	}
+	return g, nil
+}
+
+// Validate проверяет корректность описания геозоны.
+func (g Geofence) Validate() error {
+	if g.ID == "" {
+		return errors.New("geofence ID is not set")
+	}
+
+	switch g.Shape {
+	case CircleGeofence:
+		if err := g.Center.Validate(); err != nil {
+			return fmt.Errorf("geofence %s: %s", g.ID, err)
+		}
+		if g.Radius <= 0 {
+			return fmt.Errorf("geofence %s: radius should be positive", g.ID)
+		}
+	case PolygonGeofence:
+		if len(g.Rings) == 0 {
+			return fmt.Errorf("geofence %s: polygon has no rings", g.ID)
+		}
+		for _, ring := range g.Rings {
+			if len(ring) < 3 {
+				return fmt.Errorf("geofence %s: polygon ring should have at least 3 points", g.ID)
+			}
+			for _, c := range ring {
+				if err := c.Validate(); err != nil {
+					return fmt.Errorf("geofence %s: %s", g.ID, err)
+				}
+			}
+		}
+	default:
+		return fmt.Errorf("geofence %s: unknown shape %q", g.ID, g.Shape)
+	}
+
+	return nil
+}
+
+// HasTag проверяет, отмечена ли геозона указанной меткой.
+func (g Geofence) HasTag(tag string) bool {
+	for _, t := range g.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// SignedDistance возвращает расстояние в метрах от точки до границы геозоны.
+// Для точек внутри геозоны значение отрицательное.
+func (g Geofence) SignedDistance(c Coordinates) float64 {
+	if g.Shape == CircleGeofence {
+		return Distance(g.Center, c) - g.Radius
+	}
+
+	proj := newLocalProjection(c)
+	p := proj.project(c)
+	dist := math.Inf(1)
+	inside := false
+	for idx, ring := range g.Rings {
+		pts := make([]planePoint, len(ring))
+		for i, rc := range ring {
+			pts[i] = proj.project(rc)
+		}
+
+		for i := range pts {
+			dist = math.Min(dist, segmentDistance(p, pts[i], pts[(i+1)%len(pts)]))
+		}
+
+		in := ringContains(pts, p)
+		if idx == 0 {
+			inside = in
+		} else if in {
+			inside = false
+		}
+	}
+
+	if inside {
+		return -dist
+	}
+	return dist
+}
+
+// Contains проверяет, находится ли точка внутри геозоны без учета погрешности.
+func (g Geofence) Contains(c Coordinates) bool {
+	return g.SignedDistance(c) <= 0
+}
+
+// ringContains проверяет вхождение точки в контур методом трассировки луча.
+func ringContains(ring []planePoint, p planePoint) bool {
+	in := false
+	for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+		a, b := ring[i], ring[j]
+		if (a.y > p.y) != (b.y > p.y) &&
+			p.x < (b.x-a.x)*(p.y-a.y)/(b.y-a.y)+a.x {
+			in = !in
+		}
+	}
+	return in
+}
+
+// GeofenceEventType представляет собой тип события геозоны.
+type GeofenceEventType string
+
+const (
+	GeofenceEnterEvent GeofenceEventType = "enter" // Объект вошел в геозону
+	GeofenceExitEvent  GeofenceEventType = "exit"  // Объект покинул геозону
+	GeofenceDwellEvent GeofenceEventType = "dwell" // Объект находится в геозоне дольше DwellTime
+)
+
+// GeofenceEvent содержит информацию о событии геозоны по объекту.
+type GeofenceEvent struct {
+	Type      GeofenceEventType // Тип события
+	Phone     Object            // Номер телефона абонента
+	Geofence  Geofence          // Геозона, по которой произошло событие
+	Position  Position          // Местоположение, по которому зафиксировано событие
+	Timestamp time.Time         // Время события (время получения координат)
+	Duration  time.Duration     // Время нахождения в геозоне (для exit и dwell)
+}
+
+// GeofenceMonitorOptions предоставляет настройки для GeofenceMonitor.
+type GeofenceMonitorOptions struct {
+	// Hysteresis - дополнительный отступ от границы в метрах. Для входа объект должен
+	// оказаться глубже границы на это расстояние, для выхода - дальше границы.
+	// Позволяет избежать частой смены состояния, когда объект находится у границы.
+	Hysteresis float64
+	// IgnoreDeviation отключает учет радиуса погрешности (Position.Deviation).
+	// По умолчанию вход засчитывается, только если весь круг погрешности внутри
+	// геозоны, а выход - если весь круг погрешности снаружи.
+	IgnoreDeviation bool
+	// MaxDeviation - позиции с радиусом погрешности больше этого значения (м)
+	// не учитываются. 0 - без ограничения.
+	MaxDeviation float64
+	// DwellTime - время нахождения в геозоне, после которого генерируется
+	// событие GeofenceDwellEvent. 0 - событие не генерируется.
+	DwellTime time.Duration
+}
+
+type geofenceState struct {
+	inside  bool
+	since   time.Time
+	dwelled bool
+}
+
+type geofenceObjectState struct {
+	last   time.Time
+	fences map[string]*geofenceState
+}
+
+// GeofenceMonitor отслеживает вход, выход и нахождение объектов в геозонах по
+// получаемым местоположениям. Безопасен для использования из нескольких горутин.
+type GeofenceMonitor struct {
+	opts    GeofenceMonitorOptions
+	fences  []Geofence
+	mu      sync.Mutex
+	objects map[string]*geofenceObjectState
+}
+
+// NewGeofenceMonitor создает экземпляр GeofenceMonitor для указанного списка геозон.
+func NewGeofenceMonitor(fences []Geofence, opts GeofenceMonitorOptions) (*GeofenceMonitor, error) {
+	if opts.Hysteresis < 0 {
+		return nil, errors.New("hysteresis should not be negative")
+	}
+	if opts.MaxDeviation < 0 {
+		return nil, errors.New("max deviation should not be negative")
+	}
+
+	ids := make(map[string]bool, len(fences))
+	for _, g := range fences {
+		if err := g.Validate(); err != nil {
+			return nil, err
+		}
+		if ids[g.ID] {
+			return nil, fmt.Errorf("duplicate geofence ID %s", g.ID)
+		}
+		ids[g.ID] = true
+	}
+
+	return &GeofenceMonitor{
+		opts:    opts,
+		fences:  append([]Geofence(nil), fences...),
+		objects: make(map[string]*geofenceObjectState),
+	}, nil
+}
+
+// Geofences возвращает список отслеживаемых геозон.
+func (m *GeofenceMonitor) Geofences() []Geofence {
+	return append([]Geofence(nil), m.fences...)
+}
+
+// Update обрабатывает новое местоположение объекта и возвращает возникшие события.
+// Местоположения старше уже обработанных для этого объекта игнорируются.
+func (m *GeofenceMonitor) Update(op ObjectPosition) []GeofenceEvent {
+	key := op.Phone.String()
+	if key == "" {
+		return nil
+	}
+
+	if m.opts.MaxDeviation > 0 && op.Deviation != nil && float64(*op.Deviation) > m.opts.MaxDeviation {
+		return nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ts := op.Timestamp.Time()
+	st, ok := m.objects[key]
+	if !ok {
+		st = &geofenceObjectState{fences: make(map[string]*geofenceState)}
+		m.objects[key] = st
+	}
+	if ts.Before(st.last) {
+		return nil
+	}
+	st.last = ts
+
+	var uncertainty float64
+	if !m.opts.IgnoreDeviation && op.Deviation != nil {
+		uncertainty = math.Max(0, float64(*op.Deviation))
+	}
+
+	var events []GeofenceEvent
+	newEvent := func(t GeofenceEventType, g Geofence, d time.Duration) {
+		events = append(events, GeofenceEvent{
+			Type:      t,
+			Phone:     op.Phone,
+			Geofence:  g,
+			Position:  op.Position,
+			Timestamp: ts,
+			Duration:  d,
+		})
+	}
+
+	for _, g := range m.fences {
+		fs, ok := st.fences[g.ID]
+		if !ok {
+			fs = &geofenceState{}
+			st.fences[g.ID] = fs
+		}
+
+		d := g.SignedDistance(op.Coordinates)
+		switch {
+		case !fs.inside && d+uncertainty <= -m.opts.Hysteresis:
+			fs.inside = true
+			fs.since = ts
+			fs.dwelled = false
+			newEvent(GeofenceEnterEvent, g, 0)
+		case fs.inside && d-uncertainty >= m.opts.Hysteresis:
+			fs.inside = false
+			newEvent(GeofenceExitEvent, g, ts.Sub(fs.since))
+		}
+
+		if fs.inside && !fs.dwelled && m.opts.DwellTime > 0 && ts.Sub(fs.since) >= m.opts.DwellTime {
+			fs.dwelled = true
+			newEvent(GeofenceDwellEvent, g, ts.Sub(fs.since))
+		}
+	}
+
+	return events
+}
+
+// UpdateAll обрабатывает список местоположений объектов, например полученный
+// методом GetObjectsPositions.
+func (m *GeofenceMonitor) UpdateAll(ops ObjectPositions) []GeofenceEvent {
+	var events []GeofenceEvent
+	for _, op := range ops {
+		events = append(events, m.Update(op)...)
+	}
+	return events
+}
+
+// Inside возвращает список ID геозон, в которых сейчас находится объект.
+func (m *GeofenceMonitor) Inside(o Object) []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	st, ok := m.objects[o.String()]
+	if !ok {
+		return nil
+	}
+
+	var ids []string
+	for _, g := range m.fences {
+		if fs, ok := st.fences[g.ID]; ok && fs.inside {
+			ids = append(ids, g.ID)
+		}
+	}
+	return ids
+}
+
+// Reset сбрасывает состояние объекта, например после его удаления из мониторинга.
+func (m *GeofenceMonitor) Reset(o Object) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.objects, o.String())
+}
@@ -0,0 +1,154 @@
+package movizor
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func testPosition(lat, lon float64, ts int64, radius int) Position {
+	dev := Int(radius)
+	return Position{
+		Coordinates: Coordinates{Lat: Coordinate(lat), Lon: Coordinate(lon)},
+		Timestamp:   Time(time.Unix(ts, 0)),
+		Deviation:   &dev,
+	}
+}
+
+func TestDistance(t *testing.T) {
+	moscow := Coordinates{Lat: 55.755826, Lon: 37.617300}
+	spb := Coordinates{Lat: 59.938630, Lon: 30.314130}
+	got := Distance(moscow, spb)
+	if got < 633000 || got > 635000 {
+		t.Errorf("Distance() = %v, want about 634 km", got)
+	}
+	if d := moscow.DistanceTo(moscow); d != 0 {
+		t.Errorf("Coordinates.DistanceTo() = %v, want 0", d)
+	}
+}
+
+func TestGeofence_SignedDistance(t *testing.T) {
+	circle, err := NewCircleGeofence("c", Coordinates{Lat: 55.0, Lon: 37.0}, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	square, err := NewPolygonGeofence("p",
+		[]Coordinates{{Lat: 55.0, Lon: 37.0}, {Lat: 55.0, Lon: 37.1}, {Lat: 55.1, Lon: 37.1}, {Lat: 55.1, Lon: 37.0}},
+		[]Coordinates{{Lat: 55.04, Lon: 37.04}, {Lat: 55.04, Lon: 37.06}, {Lat: 55.06, Lon: 37.06}, {Lat: 55.06, Lon: 37.04}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		g      Geofence
+		c      Coordinates
+		inside bool
+	}{
+		{name: "circle center", g: circle, c: Coordinates{Lat: 55.0, Lon: 37.0}, inside: true},
+		{name: "circle outside", g: circle, c: Coordinates{Lat: 55.02, Lon: 37.0}, inside: false},
+		{name: "polygon inside", g: square, c: Coordinates{Lat: 55.02, Lon: 37.02}, inside: true},
+		{name: "polygon hole", g: square, c: Coordinates{Lat: 55.05, Lon: 37.05}, inside: false},
+		{name: "polygon outside", g: square, c: Coordinates{Lat: 54.9, Lon: 37.05}, inside: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.g.Contains(tt.c); got != tt.inside {
+				t.Errorf("Geofence.Contains() = %v, want %v", got, tt.inside)
+			}
+		})
+	}
+}
+
+func TestNewGeofenceMonitor(t *testing.T) {
+	g, _ := NewCircleGeofence("c", Coordinates{Lat: 55.0, Lon: 37.0}, 1000)
+	if _, err := NewGeofenceMonitor([]Geofence{g, g}, GeofenceMonitorOptions{}); err == nil {
+		t.Error("NewGeofenceMonitor() expected error on duplicate IDs")
+	}
+	if _, err := NewGeofenceMonitor([]Geofence{{ID: "bad", Shape: CircleGeofence}}, GeofenceMonitorOptions{}); err == nil {
+		t.Error("NewGeofenceMonitor() expected error on invalid geofence")
+	}
+}
+
+func TestGeofenceMonitor_Update(t *testing.T) {
+	g, _ := NewCircleGeofence("c", Coordinates{Lat: 55.0, Lon: 37.0}, 2000)
+	m, err := NewGeofenceMonitor([]Geofence{g}, GeofenceMonitorOptions{
+		Hysteresis: 100,
+		DwellTime:  10 * time.Minute,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	phone := Object("79123456787")
+	steps := []struct {
+		name string
+		pos  Position
+		want []GeofenceEventType
+	}{
+		{name: "far away", pos: testPosition(55.1, 37.0, 1000, 300)},
+		{name: "inside but radius crosses border", pos: testPosition(55.0135, 37.0, 1060, 800)},
+		{name: "inside", pos: testPosition(55.001, 37.0, 1120, 300), want: []GeofenceEventType{GeofenceEnterEvent}},
+		{name: "near border", pos: testPosition(55.019, 37.0, 1300, 100)},
+		{name: "older position", pos: testPosition(55.1, 37.0, 1200, 100)},
+		{name: "dwell", pos: testPosition(55.0, 37.0, 1800, 100), want: []GeofenceEventType{GeofenceDwellEvent}},
+		{name: "still inside", pos: testPosition(55.0, 37.0, 2400, 100)},
+		{name: "left", pos: testPosition(55.05, 37.0, 3000, 500), want: []GeofenceEventType{GeofenceExitEvent}},
+	}
+	for _, st := range steps {
+		events := m.Update(ObjectPosition{Phone: phone, Position: st.pos})
+		var got []GeofenceEventType
+		for _, e := range events {
+			got = append(got, e.Type)
+			if e.Phone != phone || e.Geofence.ID != "c" {
+				t.Errorf("%s: unexpected event %+v", st.name, e)
+			}
+		}
+		if !reflect.DeepEqual(got, st.want) {
+			t.Errorf("%s: GeofenceMonitor.Update() = %v, want %v", st.name, got, st.want)
+		}
+	}
+
+	if ids := m.Inside(phone); len(ids) != 0 {
+		t.Errorf("GeofenceMonitor.Inside() = %v, want empty", ids)
+	}
+}
+
+func TestParseGeofencesGeoJSON(t *testing.T) {
+	f, err := os.Open(filepath.Join(dataPath, "geofences.geojson"))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer f.Close()
+
+	fences, err := LoadGeofencesGeoJSON(f)
+	if err != nil {
+		t.Fatalf("LoadGeofencesGeoJSON() error = %v", err)
+	}
+	if len(fences) != 2 {
+		t.Fatalf("LoadGeofencesGeoJSON() len = %d, want 2", len(fences))
+	}
+
+	if fences[0].ID != "warehouse" || fences[0].Shape != CircleGeofence || fences[0].Radius != 1500 {
+		t.Errorf("circle geofence is parsed wrong: %+v", fences[0])
+	}
+	if !fences[0].HasTag("склад") {
+		t.Errorf("circle geofence tags are parsed wrong: %v", fences[0].Tags)
+	}
+	if fences[1].ID != "2" || fences[1].Title != "Химки" || len(fences[1].Rings[0]) != 4 {
+		t.Errorf("polygon geofence is parsed wrong: %+v", fences[1])
+	}
+	if !reflect.DeepEqual(fences[1].Tags, []string{"город", "область"}) {
+		t.Errorf("polygon geofence tags are parsed wrong: %v", fences[1].Tags)
+	}
+	if !fences[1].Contains(Coordinates{Lat: 55.876590, Lon: 37.426606}) {
+		t.Error("polygon geofence should contain object_get1 position")
+	}
+
+	if _, err := ParseGeofencesGeoJSON([]byte(`{"type": "Point", "coordinates": [37, 55]}`)); err == nil {
+		t.Error("ParseGeofencesGeoJSON() expected error on bare geometry")
+	}
+}
@@ -0,0 +1,269 @@
+package movizor
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+)
+
+// geoJSONFeatureCollection описывает FeatureCollection формата GeoJSON (RFC 7946).
+type geoJSONFeatureCollection struct {
+	Type     string           `json:"type"`
+	Features []geoJSONFeature `json:"features"`
+}
+
+type geoJSONFeature struct {
+	Type       string                 `json:"type"`
+	ID         interface{}            `json:"id,omitempty"`
+	Geometry   geoJSONGeometry        `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+type geoJSONGeometry struct {
+	Type        string          `json:"type"`
+	Coordinates json.RawMessage `json:"coordinates"`
+}
+
+// coordinatesFromGeoJSON преобразует пару [lon, lat] в Coordinates.
+func coordinatesFromGeoJSON(p []float64) (Coordinates, error) {
+	if len(p) < 2 {
+		return Coordinates{}, errors.New("geojson position should have at least 2 elements")
+	}
+	return NewCoordinates(p[1], p[0])
+}
+
+// ParseGeofencesGeoJSON разбирает геозоны из GeoJSON. Допускается FeatureCollection
+// или отдельный Feature. Геометрия Polygon преобразуется в PolygonGeofence, геометрия
+// Point со свойством "radius" (м) - в CircleGeofence.
+// ID геозоны берется из id объекта или из свойства "id", название - из свойств
+// "title" или "name", метки - из свойства "tags" (массив или строка через запятую).
+func ParseGeofencesGeoJSON(data []byte) ([]Geofence, error) {
+	var probe struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(data, &probe); err != nil {
+		return nil, err
+	}
+
+	var features []geoJSONFeature
+	switch probe.Type {
+	case "FeatureCollection":
+		var fc geoJSONFeatureCollection
+		if err := json.Unmarshal(data, &fc); err != nil {
+			return nil, err
+		}
+		features = fc.Features
+	case "Feature":
+		var f geoJSONFeature
+		if err := json.Unmarshal(data, &f); err != nil {
+			return nil, err
+		}
+		features = []geoJSONFeature{f}
+	default:
+		return nil, fmt.Errorf("unsupported geojson type %q", probe.Type)
+	}
+
+	fences := make([]Geofence, 0, len(features))
+	for idx, f := range features {
+		g, err := f.geofence()
+		if err != nil {
+			return nil, fmt.Errorf("feature #%d: %s", idx, err)
+		}
+		if g.ID == "" {
+			g.ID = strconv.Itoa(idx)
+		}
+		if err := g.Validate(); err != nil {
+			return nil, fmt.Errorf("feature #%d: %s", idx, err)
+		}
+		fences = append(fences, g)
+	}
+
+	return fences, nil
+}
+
+// LoadGeofencesGeoJSON читает и разбирает геозоны из GeoJSON.
+// Подробнее в ParseGeofencesGeoJSON.
+func LoadGeofencesGeoJSON(r io.Reader) ([]Geofence, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return ParseGeofencesGeoJSON(data)
+}
+
+func (f geoJSONFeature) geofence() (Geofence, error) {
+	g := Geofence{}
+	if f.ID != nil {
+		g.ID = fmt.Sprint(f.ID)
+	} else if id, ok := f.Properties["id"]; ok && id != nil {
+		g.ID = fmt.Sprint(id)
+	}
+	if title, ok := f.Properties["title"].(string); ok {
+		g.Title = title
+	} else if name, ok := f.Properties["name"].(string); ok {
+		g.Title = name
+	}
+
+	switch tags := f.Properties["tags"].(type) {
+	case string:
+		for _, t := range strings.Split(tags, ",") {
+			if t = strings.TrimSpace(t); t != "" {
+				g.Tags = append(g.Tags, t)
+			}
+		}
+	case []interface{}:
+		for _, t := range tags {
+			g.Tags = append(g.Tags, fmt.Sprint(t))
+		}
+	}
+
+	switch f.Geometry.Type {
+	case "Point":
+		var p []float64
+		if err := json.Unmarshal(f.Geometry.Coordinates, &p); err != nil {
+			return Geofence{}, err
+		}
+		c, err := coordinatesFromGeoJSON(p)
+		if err != nil {
+			return Geofence{}, err
+		}
+		radius, ok := f.Properties["radius"].(float64)
+		if !ok {
+			return Geofence{}, errors.New("point geometry requires numeric property radius")
+		}
+		g.Shape = CircleGeofence
+		g.Center = c
+		g.Radius = radius
+	case "Polygon":
+		var rings [][][]float64
+		if err := json.Unmarshal(f.Geometry.Coordinates, &rings); err != nil {
+			return Geofence{}, err
+		}
+		g.Shape = PolygonGeofence
+		for _, ring := range rings {
+			// В GeoJSON контур замкнут: последняя точка совпадает с первой.
+			if len(ring) > 1 && fmt.Sprint(ring[0]) == fmt.Sprint(ring[len(ring)-1]) {
+				ring = ring[:len(ring)-1]
+			}
+			cs := make([]Coordinates, 0, len(ring))
+			for _, p := range ring {
+				c, err := coordinatesFromGeoJSON(p)
+				if err != nil {
+					return Geofence{}, err
+				}
+				cs = append(cs, c)
+			}
+			g.Rings = append(g.Rings, cs)
+		}
+	default:
+		return Geofence{}, fmt.Errorf("unsupported geometry type %q", f.Geometry.Type)
+	}
+
+	return g, nil
+}
+
+func newGeoJSONGeometry(geomType string, coordinates interface{}) (geoJSONGeometry, error) {
+	raw, err := json.Marshal(coordinates)
+	if err != nil {
+		return geoJSONGeometry{}, err
+	}
+	return geoJSONGeometry{Type: geomType, Coordinates: raw}, nil
+}
+
+func (c Coordinates) geoJSON() []float64 {
+	return []float64{c.Lon.Float64(), c.Lat.Float64()}
+}
+
+func (p Position) geoJSONProperties() map[string]interface{} {
+	props := map[string]interface{}{}
+	if ts := formatExportTime(p.Timestamp); ts != "" {
+		props["timestamp"] = ts
+	}
+	if ts := formatExportTime(p.TimestampRequest); ts != "" {
+		props["timestamp_request"] = ts
+	}
+	if p.Place != "" {
+		props["place"] = p.Place
+	}
+	if p.Deviation != nil {
+		props["radius"] = p.Deviation.Int()
+	}
+	if p.Distance != nil {
+		props["distance"] = p.Distance.Int()
+	}
+	if p.ETA != nil {
+		props["eta"] = p.ETA.Int()
+	}
+	if p.ETAStatus != nil {
+		props["eta_status"] = string(*p.ETAStatus)
+	}
+	return props
+}
+
+// WriteGeoJSON записывает треки в формате GeoJSON (RFC 7946) как FeatureCollection.
+// Для каждого трека создается линия (LineString, kind=track), точки местоположений
+// (Point, kind=position) с временем, местом, радиусом погрешности и ETA в свойствах,
+// а также точки назначения (Point, kind=destination).
+func WriteGeoJSON(w io.Writer, tracks ...Track) error {
+	fc := geoJSONFeatureCollection{Type: "FeatureCollection", Features: []geoJSONFeature{}}
+	add := func(geomType string, coordinates interface{}, props map[string]interface{}) error {
+		geom, err := newGeoJSONGeometry(geomType, coordinates)
+		if err != nil {
+			return err
+		}
+		fc.Features = append(fc.Features, geoJSONFeature{Type: "Feature", Geometry: geom, Properties: props})
+		return nil
+	}
+
+	for _, t := range tracks {
+		ps := t.Positions.Chronological()
+		if len(ps) > 1 {
+			line := make([][]float64, 0, len(ps))
+			for _, p := range ps {
+				line = append(line, p.Coordinates.geoJSON())
+			}
+			props := map[string]interface{}{
+				"kind":  "track",
+				"phone": t.Phone.String(),
+				"title": t.name(),
+				"start": formatExportTime(ps[0].Timestamp),
+				"end":   formatExportTime(ps[len(ps)-1].Timestamp),
+			}
+			if err := add("LineString", line, props); err != nil {
+				return err
+			}
+		}
+
+		for _, p := range ps {
+			props := p.geoJSONProperties()
+			props["kind"] = "position"
+			props["phone"] = t.Phone.String()
+			if err := add("Point", p.Coordinates.geoJSON(), props); err != nil {
+				return err
+			}
+		}
+
+		for _, d := range t.Destinations {
+			props := map[string]interface{}{
+				"kind":   "destination",
+				"phone":  t.Phone.String(),
+				"text":   d.Text,
+				"status": string(d.Status),
+			}
+			if ts := formatExportTimeValue(d.Time); ts != "" {
+				props["time"] = ts
+			}
+			if err := add("Point", d.Coordinates.geoJSON(), props); err != nil {
+				return err
+			}
+		}
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(fc)
+}
@@ -0,0 +1,353 @@
+package movizor
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ImportStatus представляет собой результат обработки строки импорта.
+type ImportStatus string
+
+const (
+	ImportInvalid ImportStatus = "invalid" // Строка не прошла проверку
+	ImportValid   ImportStatus = "valid"   // Строка корректна, объект не добавлялся (DryRun или ошибки в других строках)
+	ImportAdded   ImportStatus = "added"   // Объект добавлен
+	ImportFailed  ImportStatus = "failed"  // Сервис вернул ошибку при добавлении
+)
+
+// ImportOptions предоставляет настройки импорта объектов из CSV.
+type ImportOptions struct {
+	// CSV - формат файла: Comma (по умолчанию ','), DecimalComma, TimeFormat и Location
+	// для dateoff, времени прибытия и расписания (по умолчанию MoscowLocation - часовой
+	// пояс сервиса). UTF-8 BOM в начале файла пропускается.
+	CSV         CSVOptions
+	SlaveID     uint64 // Подчиненный кабинет для всех объектов, колонка account имеет приоритет. 0 - основной кабинет
+	Concurrency int    // Количество одновременных запросов. 0 - DefaultSyncConcurrency
+	DryRun      bool   // Только проверить строки, не добавляя объекты
+}
+
+// ImportRow - объект, прочитанный из строки CSV.
+type ImportRow struct {
+	Line    int // Номер строки в файле, заголовок - строка 1
+	Object  Object
+	Options ObjectOptions
+	SlaveID uint64
+}
+
+// ImportResult - результат обработки строки CSV.
+type ImportResult struct {
+	Row      ImportRow
+	Status   ImportStatus
+	Response APIResponse
+	Err      error
+}
+
+// ReadImportCSV читает объекты из CSV и проверяет каждую строку. Имена колонок совпадают
+// с ключами колонок WriteObjectInfosCSV: phone (обязательная), title, tags (через запятую),
+// tariff, dateoff, package_prolong, autoinform, account (ID подчиненного кабинета),
+// schedule (выражение ParseSchedule), "metadata:<ключ>" и "destination<N>_text",
+// "destination<N>_lat", "destination<N>_lon", "destination<N>_time".
+// Проверяются нормализация номера (Object.String()), повторы номеров и все опции так же,
+// как при передаче в сервис. Ошибка возвращается только при невозможности прочитать файл.
+// Время читается в часовом поясе opts.Location, nil - MoscowLocation (часовой пояс сервиса).
+func ReadImportCSV(r io.Reader, opts CSVOptions) ([]ImportResult, error) {
+	if opts.Location == nil {
+		opts.Location = MoscowLocation
+	}
+	f := opts.formatter()
+	cr := csv.NewReader(r)
+	if opts.Comma != 0 {
+		cr.Comma = opts.Comma
+	}
+	cr.FieldsPerRecord = -1
+
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("can't read csv header: %s", err)
+	}
+	columns, err := parseImportHeader(header)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []ImportResult
+	seen := make(map[string]int)
+	for line := 2; ; line++ {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return results, err
+		}
+
+		res := ImportResult{Status: ImportValid}
+		res.Row, res.Err = f.importRow(line, columns, record)
+		if res.Err == nil {
+			phone := res.Row.Object.String()
+			if first, ok := seen[phone]; ok {
+				res.Err = fmt.Errorf("duplicate phone %s, first seen on line %d", phone, first)
+			} else {
+				seen[phone] = line
+			}
+		}
+		if res.Err != nil {
+			res.Status = ImportInvalid
+		}
+		results = append(results, res)
+	}
+	return results, nil
+}
+
+// ImportObjects добавляет объекты из результатов ReadImportCSV через AddObjectToSlave,
+// не более Concurrency одновременно. Если хотя бы одна строка не прошла проверку,
+// объекты не добавляются и возвращается ошибка. Результаты обновляются на месте.
+func (api *API) ImportObjects(results []ImportResult, opts ImportOptions) error {
+	invalid := 0
+	for _, res := range results {
+		if res.Status == ImportInvalid {
+			invalid++
+		}
+	}
+	if invalid > 0 {
+		return fmt.Errorf("%d of %d rows are invalid, nothing is imported", invalid, len(results))
+	}
+	if opts.DryRun {
+		return nil
+	}
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = DefaultSyncConcurrency
+	}
+
+	failed := 0
+	errs := parallel(len(results), opts.Concurrency, func(i int) error {
+		row := results[i].Row
+		slaveID := row.SlaveID
+		if slaveID == 0 {
+			slaveID = opts.SlaveID
+		}
+		resp, err := api.AddObjectToSlave(row.Object, &row.Options, slaveID)
+		results[i].Response = resp
+		return err
+	})
+	for i, err := range errs {
+		results[i].Status, results[i].Err = ImportAdded, err
+		if err != nil {
+			results[i].Status = ImportFailed
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d objects are not added", failed, len(results))
+	}
+	return nil
+}
+
+// ImportObjectsCSV читает и проверяет объекты из CSV, затем добавляет их (см. ReadImportCSV
+// и ImportObjects). Результаты по строкам возвращаются и при ошибке, для отчета
+// используйте WriteImportReportCSV.
+func (api *API) ImportObjectsCSV(r io.Reader, opts ImportOptions) ([]ImportResult, error) {
+	results, err := ReadImportCSV(r, opts.CSV)
+	if err != nil {
+		return results, err
+	}
+	return results, api.ImportObjects(results, opts)
+}
+
+// WriteImportReportCSV выгружает отчет об импорте по строкам.
+// Колонки: line, phone, title, status, error.
+func WriteImportReportCSV(w io.Writer, results []ImportResult, opts CSVOptions) error {
+	columns := []csvColumn{
+		{key: "line", title: "Строка", value: func(i int) string { return strconv.Itoa(results[i].Row.Line) }},
+		{key: "phone", title: "Номер абонента", value: func(i int) string { return results[i].Row.Object.String() }},
+		{key: "title", title: "Имя абонента (название объекта)", value: func(i int) string { return results[i].Row.Options.Title }},
+		{key: "status", title: "Результат", value: func(i int) string { return string(results[i].Status) }},
+		{key: "error", title: "Ошибка", value: func(i int) string {
+			if results[i].Err == nil {
+				return ""
+			}
+			return results[i].Err.Error()
+		}},
+	}
+	return opts.write(w, columns, len(results))
+}
+
+// importColumn - разобранный заголовок колонки импорта.
+type importColumn struct {
+	key   string // Ключ колонки без номера точки назначения и префикса метаинформации
+	name  string // Ключ метаинформации
+	index int    // Индекс точки назначения, начиная с 0
+}
+
+func parseImportHeader(header []string) ([]importColumn, error) {
+	columns := make([]importColumn, len(header))
+	hasPhone := false
+	for i, h := range header {
+		h = strings.TrimSpace(h)
+		if i == 0 {
+			h = strings.TrimPrefix(h, "\ufeff")
+		}
+		key := strings.ToLower(h)
+
+		switch {
+		case strings.HasPrefix(key, csvMetadataPrefix):
+			columns[i] = importColumn{key: "metadata", name: h[len(csvMetadataPrefix):]}
+		case strings.HasPrefix(key, csvDestinationPrefix):
+			rest := key[len(csvDestinationPrefix):]
+			sep := strings.Index(rest, "_")
+			if sep < 0 {
+				return nil, fmt.Errorf("invalid csv column %q", h)
+			}
+			n, err := strconv.Atoi(rest[:sep])
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("invalid csv column %q", h)
+			}
+			switch field := rest[sep+1:]; field {
+			case "text", "lat", "lon", "time":
+				columns[i] = importColumn{key: csvDestinationPrefix + "_" + field, index: n - 1}
+			case "status":
+				// Статус выгружается WriteObjectInfosCSV, но не передается в сервис: колонка пропускается.
+			default:
+				return nil, fmt.Errorf("invalid csv column %q", h)
+			}
+		case key == "phone":
+			hasPhone = true
+			columns[i] = importColumn{key: key}
+		case key == "title", key == "tags", key == "tariff", key == "dateoff", key == "package_prolong",
+			key == "autoinform", key == "account", key == "schedule":
+			columns[i] = importColumn{key: key}
+		default:
+			return nil, fmt.Errorf("unknown csv column %q", h)
+		}
+	}
+	if !hasPhone {
+		return nil, fmt.Errorf("csv column %q is required", "phone")
+	}
+	return columns, nil
+}
+
+func (f csvFormatter) importRow(line int, columns []importColumn, record []string) (ImportRow, error) {
+	row := ImportRow{Line: line}
+	if len(record) != len(columns) {
+		return row, fmt.Errorf("wrong number of fields: %d, expected %d", len(record), len(columns))
+	}
+
+	oo := &row.Options
+	dests := make(map[int]*DestinationOptions)
+	dest := func(i int) *DestinationOptions {
+		if dests[i] == nil {
+			dests[i] = &DestinationOptions{}
+		}
+		return dests[i]
+	}
+
+	for i, c := range columns {
+		v := strings.TrimSpace(record[i])
+		if v == "" || c.key == "" {
+			continue
+		}
+
+		var err error
+		switch c.key {
+		case "phone":
+			row.Object = Object(v)
+		case "title":
+			oo.Title = v
+		case "tags":
+			for _, tag := range strings.Split(v, ",") {
+				if tag = strings.TrimSpace(tag); tag != "" {
+					oo.Tags = append(oo.Tags, tag)
+				}
+			}
+		case "tariff":
+			oo.Tariff = TariffType(v)
+			if !oo.Tariff.IsKnown() {
+				err = fmt.Errorf("unknown tariff %q", v)
+			}
+		case "dateoff":
+			oo.DateOff, err = f.parseTime(v)
+		case "package_prolong":
+			oo.PackageProlong, err = parseImportBool(v)
+		case "autoinform":
+			oo.CallToDriver, err = parseImportBool(v)
+		case "account":
+			row.SlaveID, err = strconv.ParseUint(v, 10, 64)
+		case "schedule":
+			oo.Schedules, err = ParseSchedule(v, f.opts.Location)
+		case "metadata":
+			if oo.Metadata == nil {
+				oo.Metadata = make(map[string]string)
+			}
+			oo.Metadata[c.name] = v
+		case "destination_text":
+			dest(c.index).Text = v
+		case "destination_lat":
+			dest(c.index).Lat, err = parseImportCoordinate(v)
+		case "destination_lon":
+			dest(c.index).Lon, err = parseImportCoordinate(v)
+		case "destination_time":
+			dest(c.index).ExpectedTime, err = f.parseTime(v)
+		}
+		if err != nil {
+			return row, fmt.Errorf("column %d: %s", i+1, err)
+		}
+	}
+
+	if row.Object.String() == "" {
+		return row, fmt.Errorf("invalid format of phone number: %q, should be 79XXXXXXXXX", string(row.Object))
+	}
+
+	indexes := make([]int, 0, len(dests))
+	for i := range dests {
+		indexes = append(indexes, i)
+	}
+	sort.Ints(indexes)
+	for n, i := range indexes {
+		if n != i {
+			return row, fmt.Errorf("destination %d is missing", n+1)
+		}
+		oo.Destinations = append(oo.Destinations, *dests[i])
+	}
+
+	// Проверка опций так же, как при передаче в сервис.
+	if err := oo.addValuesTo(&url.Values{}, nil); err != nil {
+		return row, err
+	}
+	return row, nil
+}
+
+// parseTime разбирает время в формате TimeFormat или в одном из форматов времени
+// прибытия в часовом поясе Location.
+func (f csvFormatter) parseTime(s string) (time.Time, error) {
+	layouts := append([]string{f.opts.TimeFormat}, destinationTimeLayouts...)
+	for _, layout := range layouts {
+		if t, err := time.ParseInLocation(layout, s, f.opts.Location); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q, should be in format %s", s, f.opts.TimeFormat)
+}
+
+func parseImportCoordinate(s string) (Coordinate, error) {
+	v, err := strconv.ParseFloat(strings.Replace(s, ",", ".", 1), 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid coordinate %q", s)
+	}
+	return Coordinate(v), nil
+}
+
+func parseImportBool(s string) (bool, error) {
+	switch strings.ToLower(s) {
+	case "1", "true", "yes", "да":
+		return true, nil
+	case "0", "false", "no", "нет":
+		return false, nil
+	}
+	return false, fmt.Errorf("invalid boolean %q", s)
+}
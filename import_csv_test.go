@@ -0,0 +1,131 @@
+package movizor
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadImportCSV(t *testing.T) {
+	f, err := os.Open(filepath.Join(dataPath, "import.csv"))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer f.Close()
+
+	results, err := ReadImportCSV(f, CSVOptions{Location: MoscowLocation})
+	if err != nil {
+		t.Fatalf("ReadImportCSV() error = %v", err)
+	}
+
+	wantStatus := []ImportStatus{ImportValid, ImportValid, ImportInvalid, ImportInvalid, ImportInvalid,
+		ImportInvalid, ImportInvalid, ImportInvalid}
+	if len(results) != len(wantStatus) {
+		t.Fatalf("ReadImportCSV() rows = %d, want %d", len(results), len(wantStatus))
+	}
+	for i, res := range results {
+		if res.Status != wantStatus[i] {
+			t.Errorf("line %d: status = %s, want %s (%v)", res.Row.Line, res.Status, wantStatus[i], res.Err)
+		}
+	}
+
+	first := results[0].Row
+	if first.Line != 2 || first.Object.String() != "79210010201" {
+		t.Errorf("first row = %d %s", first.Line, first.Object)
+	}
+	oo := first.Options
+	if oo.Title != "Иванов" || oo.Tariff != TariffEvery30 || !reflect.DeepEqual(oo.Tags, []string{"рейс", "москва"}) {
+		t.Errorf("first row options = %+v", oo)
+	}
+	if !oo.DateOff.Equal(time.Date(2019, 3, 1, 0, 0, 0, 0, MoscowLocation)) {
+		t.Errorf("first row dateoff = %v", oo.DateOff)
+	}
+	if oo.Metadata["Склад"] != "Восточный" || oo.Schedules.String() != "Mon-Fri 08:00-20:00 every 1h" {
+		t.Errorf("first row metadata = %v, schedule = %s", oo.Metadata, oo.Schedules)
+	}
+	if len(oo.Destinations) != 2 || oo.Destinations[1].Lat != 56.86 ||
+		!oo.Destinations[0].ExpectedTime.Equal(time.Date(2019, 2, 20, 12, 0, 0, 0, MoscowLocation)) {
+		t.Errorf("first row destinations = %+v", oo.Destinations)
+	}
+	if results[1].Row.SlaveID != 12345 {
+		t.Errorf("second row account = %d, want 12345", results[1].Row.SlaveID)
+	}
+	if !strings.Contains(results[2].Err.Error(), "line 2") {
+		t.Errorf("duplicate error = %v", results[2].Err)
+	}
+
+	api := &API{}
+	if err := api.ImportObjects(results, ImportOptions{}); err == nil {
+		t.Error("ImportObjects() with invalid rows should fail")
+	}
+	if err := api.ImportObjects(results[:2], ImportOptions{DryRun: true}); err != nil {
+		t.Errorf("ImportObjects() dry run error = %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteImportReportCSV(&buf, results[2:4], CSVOptions{}); err != nil {
+		t.Fatalf("WriteImportReportCSV() error = %v", err)
+	}
+	want := "line,phone,title,status,error\r\n" +
+		"4,79210010201,Иванов-2,invalid,\"duplicate phone 79210010201, first seen on line 2\"\r\n" +
+		"5,,Сидоров,invalid,\"invalid format of phone number: \"\"12345\"\", should be 79XXXXXXXXX\"\r\n"
+	if buf.String() != want {
+		t.Errorf("WriteImportReportCSV() = %q, want %q", buf.String(), want)
+	}
+}
+
+func TestReadImportCSV_header(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		wantErr bool
+	}{
+		{name: "bom and semicolon", data: "\ufeffphone;title\r\n79210010201;Иванов\r\n"},
+		{name: "destination status is skipped", data: "phone;destination1_status\r\n79210010201;ok\r\n"},
+		{name: "no phone", data: "title\r\nИванов\r\n", wantErr: true},
+		{name: "unknown column", data: "phone;color\r\n79210010201;red\r\n", wantErr: true},
+		{name: "bad destination column", data: "phone;destination_text\r\n79210010201;Москва\r\n", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, err := ReadImportCSV(strings.NewReader(tt.data), CSVOptions{Comma: ';'})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ReadImportCSV() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && (len(results) != 1 || results[0].Status != ImportValid) {
+				t.Errorf("ReadImportCSV() = %+v", results)
+			}
+		})
+	}
+}
+
+func TestReadImportCSV_defaultLocation(t *testing.T) {
+	// Как при запуске с TZ=UTC: время файла не должно зависеть от часового пояса машины.
+	local := time.Local
+	time.Local = time.UTC
+	defer func() { time.Local = local }()
+
+	data := "phone,dateoff,schedule,destination1_text,destination1_lat,destination1_lon,destination1_time\r\n" +
+		"79210010201,01.03.2019 00:00:00,Mon-Fri 08:00,Москва,55.75,37.61,20.02.2019 12:00\r\n"
+	results, err := ReadImportCSV(strings.NewReader(data), CSVOptions{})
+	if err != nil {
+		t.Fatalf("ReadImportCSV() error = %v", err)
+	}
+	if len(results) != 1 || results[0].Status != ImportValid {
+		t.Fatalf("ReadImportCSV() = %+v", results)
+	}
+	oo := results[0].Row.Options
+	if !oo.DateOff.Equal(time.Date(2019, 3, 1, 0, 0, 0, 0, MoscowLocation)) {
+		t.Errorf("dateoff = %v, want in MoscowLocation", oo.DateOff)
+	}
+	if !oo.Destinations[0].ExpectedTime.Equal(time.Date(2019, 2, 20, 12, 0, 0, 0, MoscowLocation)) {
+		t.Errorf("destination time = %v, want in MoscowLocation", oo.Destinations[0].ExpectedTime)
+	}
+	if oo.Schedules.FireAt[0].Location() != MoscowLocation || oo.Schedules.String() != "Mon-Fri 08:00" {
+		t.Errorf("schedule = %s in %s, want in MoscowLocation", oo.Schedules, oo.Schedules.FireAt[0].Location())
+	}
+}
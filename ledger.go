@@ -0,0 +1,344 @@
+package movizor
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TariffPeriod - тариф объекта, действующий с момента From.
+type TariffPeriod struct {
+	From   time.Time
+	Tariff TariffType
+}
+
+// LedgerObject - данные объекта для расчета расходов.
+type LedgerObject struct {
+	Object   Object
+	Operator Operator
+	Tags     []string
+	Metadata map[string]string
+	// Tariffs - история тарифов по возрастанию From. Первый тариф действует и до своего From.
+	Tariffs []TariffPeriod
+	Added   time.Time // Время подключения, нулевое - подключен до начала периода
+	Off     time.Time // Время отключения, нулевое - не отключен
+}
+
+// NewLedgerObject создает данные объекта по ObjectInfo и оператору. Сервис не хранит
+// историю тарифов, поэтому текущий тариф считается действующим весь период, а TariffNew -
+// со следующих суток после now (в часовом поясе loc, nil - MoscowLocation).
+func NewLedgerObject(oi ObjectInfo, op Operator, now time.Time, loc *time.Location) LedgerObject {
+	lo := LedgerObject{
+		Object:   oi.Phone,
+		Operator: op,
+		Tags:     oi.Tags,
+		Metadata: oi.Metadata,
+		Tariffs:  []TariffPeriod{{Tariff: oi.Tariff}},
+	}
+	if oi.TariffNew != nil && *oi.TariffNew != "" && *oi.TariffNew != oi.Tariff {
+		lo.Tariffs = append(lo.Tariffs, TariffPeriod{From: startOfDay(now, loc).AddDate(0, 0, 1), Tariff: *oi.TariffNew})
+	}
+	if oi.TimestampAdd.Time().Unix() > 0 {
+		lo.Added = oi.TimestampAdd.Time()
+	}
+	if off := oi.TimestampOff.Time(); off.Unix() > 0 && off.Before(now) {
+		lo.Off = off
+	}
+	return lo
+}
+
+func startOfDay(t time.Time, loc *time.Location) time.Time {
+	t = t.In(serviceLocation(loc))
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// tariffAt возвращает тариф, действующий в момент t.
+func (lo LedgerObject) tariffAt(t time.Time) TariffType {
+	if len(lo.Tariffs) == 0 {
+		return ""
+	}
+	tt := lo.Tariffs[0].Tariff
+	for _, p := range lo.Tariffs[1:] {
+		if p.From.After(t) {
+			break
+		}
+		tt = p.Tariff
+	}
+	return tt
+}
+
+// LedgerOptions предоставляет период и допущения расчета расходов.
+type LedgerOptions struct {
+	From, To    time.Time      // Период [From, To), суточная плата считается за сутки, пересекающие период
+	Location    *time.Location // Часовой пояс границ суток. nil - MoscowLocation
+	PackageDays int            // Срок пакета TariffOneMonth в сутках. 0 - DefaultPackageDays
+	// AfterEventID - ID последнего события до From, например сохраненный при прошлой выгрузке.
+	// API.CostLedger запрашивает события после него, 0 - с первого события в журнале сервиса.
+	AfterEventID uint64
+	// History - история тарифов по нормализованному номеру (Object.String()), заменяет
+	// историю из NewLedgerObject в API.CostLedger, например сохраненную из прошлых выгрузок.
+	History map[string][]TariffPeriod
+}
+
+// CostEntry - расходы на объект за период.
+type CostEntry struct {
+	Object       Object
+	Operator     Operator
+	Tags         []string
+	Metadata     map[string]string
+	Days         int     // Тарифицированные сутки
+	Requests     int     // Успешные запросы местоположения (RequestOkEvent)
+	Subscription float64 // Абонентская плата
+	RequestsCost float64 // Стоимость запросов
+	Total        float64
+	Warnings     []string // Тарифы, которых нет в таблице тарифов: по ним расходы не начислены
+}
+
+// CostGroup - расходы группы объектов (метки, значения метаинформации, оператора).
+type CostGroup struct {
+	Key          string
+	Objects      int
+	Days         int
+	Requests     int
+	Subscription float64
+	RequestsCost float64
+	Total        float64
+}
+
+func (g *CostGroup) add(e CostEntry) {
+	g.Objects++
+	g.Days += e.Days
+	g.Requests += e.Requests
+	g.Subscription += e.Subscription
+	g.RequestsCost += e.RequestsCost
+	g.Total += e.Total
+}
+
+// CostLedger - расходы по объектам за период.
+type CostLedger struct {
+	From, To time.Time
+	Entries  []CostEntry // По номерам объектов
+	Total    float64
+}
+
+// BuildCostLedger рассчитывает расходы за период по тарифам из баланса b. Суточная
+// абонентская плата начисляется за каждые сутки периода, в которые объект был подключен,
+// по тарифу на начало суток (TariffOneMonth - плата пакета, деленная на PackageDays).
+// Запросы считаются по событиям RequestOkEvent объектов по тарифу на момент запроса.
+func BuildCostLedger(b Balance, objects []LedgerObject, events ObjectEvents, opts LedgerOptions) (CostLedger, error) {
+	if !opts.To.After(opts.From) {
+		return CostLedger{}, fmt.Errorf("invalid period %s - %s", opts.From, opts.To)
+	}
+	days := opts.PackageDays
+	if days <= 0 {
+		days = DefaultPackageDays
+	}
+
+	requests := make(map[string][]time.Time)
+	for _, e := range events {
+		if e.Event != RequestOkEvent {
+			continue
+		}
+		if t := e.Timestamp.Time(); !t.Before(opts.From) && t.Before(opts.To) {
+			requests[e.Phone.String()] = append(requests[e.Phone.String()], t)
+		}
+	}
+
+	l := CostLedger{From: opts.From, To: opts.To}
+	for _, lo := range objects {
+		e := CostEntry{Object: lo.Object, Operator: lo.Operator, Tags: lo.Tags, Metadata: lo.Metadata}
+		missing := make(map[TariffType]bool)
+		tariff := func(tt TariffType) (Tariff, bool) {
+			t, err := b.Tariff(lo.Operator, tt)
+			if err != nil && !missing[tt] {
+				missing[tt] = true
+				e.Warnings = append(e.Warnings, err.Error())
+			}
+			return t, err == nil
+		}
+
+		for day := startOfDay(opts.From, opts.Location); day.Before(opts.To); day = day.AddDate(0, 0, 1) {
+			end := day.AddDate(0, 0, 1)
+			if !lo.Added.IsZero() && !lo.Added.Before(end) || !lo.Off.IsZero() && !lo.Off.After(day) {
+				continue
+			}
+			e.Days++
+			tt := lo.tariffAt(day)
+			if t, ok := tariff(tt); ok {
+				if tt == TariffOneMonth {
+					e.Subscription += t.AbonentPayment / float64(days)
+				} else {
+					e.Subscription += t.AbonentPayment
+				}
+			}
+		}
+		for _, at := range requests[lo.Object.String()] {
+			e.Requests++
+			if t, ok := tariff(lo.tariffAt(at)); ok {
+				e.RequestsCost += t.RequestCost
+			}
+		}
+		e.Total = e.Subscription + e.RequestsCost
+		l.Total += e.Total
+		l.Entries = append(l.Entries, e)
+	}
+	sort.SliceStable(l.Entries, func(i, j int) bool { return l.Entries[i].Object.String() < l.Entries[j].Object.String() })
+	return l, nil
+}
+
+// group собирает расходы по ключам объекта. Объект с несколькими ключами входит в каждую
+// группу, объект без ключей - в группу с пустым ключом.
+func (l CostLedger) group(keys func(e CostEntry) []string) []CostGroup {
+	index := make(map[string]*CostGroup)
+	for _, e := range l.Entries {
+		ks := keys(e)
+		if len(ks) == 0 {
+			ks = []string{""}
+		}
+		for _, k := range ks {
+			if index[k] == nil {
+				index[k] = &CostGroup{Key: k}
+			}
+			index[k].add(e)
+		}
+	}
+	groups := make([]CostGroup, 0, len(index))
+	for _, g := range index {
+		groups = append(groups, *g)
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i].Key < groups[j].Key })
+	return groups
+}
+
+// ByTag возвращает расходы по меткам. Расходы объекта с несколькими метками входят
+// в каждую из них, поэтому сумма групп может превышать Total.
+func (l CostLedger) ByTag() []CostGroup {
+	return l.group(func(e CostEntry) []string { return e.Tags })
+}
+
+// ByMetadata возвращает расходы по значениям ключа метаинформации, например "customer".
+func (l CostLedger) ByMetadata(key string) []CostGroup {
+	return l.group(func(e CostEntry) []string {
+		if v, ok := e.Metadata[key]; ok {
+			return []string{v}
+		}
+		return nil
+	})
+}
+
+// ByOperator возвращает расходы по операторам.
+func (l CostLedger) ByOperator() []CostGroup {
+	return l.group(func(e CostEntry) []string { return []string{string(e.Operator)} })
+}
+
+// CostLedger получает тарифы, объекты, их операторов и события и рассчитывает расходы
+// за период (см. BuildCostLedger). Учитываются только объекты, которые есть в GetObjects:
+// удаленные объекты сервис не возвращает. События запрашиваются постранично начиная
+// с opts.AfterEventID: без него загружается весь журнал событий до To.
+func (api *API) CostLedger(opts LedgerOptions) (CostLedger, error) {
+	b, err := api.GetBalance()
+	if err != nil {
+		return CostLedger{}, err
+	}
+	list, err := api.GetObjects()
+	if err != nil {
+		return CostLedger{}, err
+	}
+
+	now := time.Now()
+	objects := make([]LedgerObject, len(list))
+	errs := parallel(len(list), DefaultSyncConcurrency, func(i int) error {
+		o := list[i].Phone
+		oi, err := api.GetObjectInfo(o)
+		if err != nil {
+			return err
+		}
+		op, err := api.GetOperatorInfo(o)
+		if err != nil {
+			return err
+		}
+		objects[i] = NewLedgerObject(oi, op.Operator, now, opts.Location)
+		objects[i].Object = o
+		if h, ok := opts.History[o.String()]; ok {
+			objects[i].Tariffs = h
+		}
+		return nil
+	})
+	for i, err := range errs {
+		if err != nil {
+			return CostLedger{}, fmt.Errorf("%s: %s", list[i].Phone, err)
+		}
+	}
+
+	events, err := api.eventsUntil(opts.AfterEventID, opts.To)
+	if err != nil {
+		return CostLedger{}, err
+	}
+	return BuildCostLedger(b, objects, events, opts)
+}
+
+// eventsUntil получает события с ID больше afterID постранично, пока не будут получены
+// события после to или события не закончатся. Сервис не фильтрует события по времени,
+// поэтому при afterID 0 загружается весь журнал до to.
+func (api *API) eventsUntil(afterID uint64, to time.Time) (ObjectEvents, error) {
+	var all ObjectEvents
+	after := int64(afterID)
+	for {
+		events, err := api.GetEvents(ObjectEventsOptions{AfterEventID: uint64(after)})
+		if err != nil {
+			return nil, err
+		}
+		last := after
+		done := len(events) == 0
+		for _, e := range events {
+			if e.EventID > last {
+				last = e.EventID
+			}
+			if !e.Timestamp.Time().Before(to) {
+				done = true
+			}
+		}
+		all = append(all, events...)
+		if done || last == after {
+			return all, nil
+		}
+		after = last
+	}
+}
+
+// WriteCostReportCSV выгружает расходы по группам (ByTag, ByMetadata, ByOperator).
+// Колонки: key, objects, days, requests, subscription, requests_cost, total.
+func WriteCostReportCSV(w io.Writer, groups []CostGroup, opts CSVOptions) error {
+	f := opts.formatter()
+	columns := []csvColumn{
+		{key: "key", title: "Группа", value: func(i int) string { return groups[i].Key }},
+		{key: "objects", title: "Объектов", value: func(i int) string { return strconv.Itoa(groups[i].Objects) }},
+		{key: "days", title: "Суток", value: func(i int) string { return strconv.Itoa(groups[i].Days) }},
+		{key: "requests", title: "Запросов", value: func(i int) string { return strconv.Itoa(groups[i].Requests) }},
+		{key: "subscription", title: "Абонентская плата", value: func(i int) string { return f.money(groups[i].Subscription) }},
+		{key: "requests_cost", title: "Стоимость запросов", value: func(i int) string { return f.money(groups[i].RequestsCost) }},
+		{key: "total", title: "Итого", value: func(i int) string { return f.money(groups[i].Total) }},
+	}
+	return opts.write(w, columns, len(groups))
+}
+
+// WriteCostLedgerCSV выгружает расходы по объектам.
+// Колонки: phone, operator, tags, days, requests, subscription, requests_cost, total.
+func WriteCostLedgerCSV(w io.Writer, l CostLedger, opts CSVOptions) error {
+	f := opts.formatter()
+	e := l.Entries
+	columns := []csvColumn{
+		{key: "phone", title: "Номер абонента", value: func(i int) string { return e[i].Object.String() }},
+		{key: "operator", title: "Оператор", value: func(i int) string { return string(e[i].Operator) }},
+		{key: "tags", title: "Метки", value: func(i int) string { return strings.Join(e[i].Tags, ",") }},
+		{key: "days", title: "Суток", value: func(i int) string { return strconv.Itoa(e[i].Days) }},
+		{key: "requests", title: "Запросов", value: func(i int) string { return strconv.Itoa(e[i].Requests) }},
+		{key: "subscription", title: "Абонентская плата", value: func(i int) string { return f.money(e[i].Subscription) }},
+		{key: "requests_cost", title: "Стоимость запросов", value: func(i int) string { return f.money(e[i].RequestsCost) }},
+		{key: "total", title: "Итого", value: func(i int) string { return f.money(e[i].Total) }},
+	}
+	return opts.write(w, columns, len(e))
+}
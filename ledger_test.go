@@ -0,0 +1,148 @@
+package movizor
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBuildCostLedger(t *testing.T) {
+	b := loadTestBalance(t)
+	day := func(d, h int) time.Time { return time.Date(2019, 5, d, h, 0, 0, 0, MoscowLocation) }
+	objects := []LedgerObject{
+		{
+			// Весь период вручную, со 3 мая - каждые 30 минут.
+			Object: "79210010202", Operator: OperatorMTS, Tags: []string{"acme", "moscow"},
+			Metadata: map[string]string{"customer": "ACME"},
+			Tariffs:  []TariffPeriod{{Tariff: "manual"}, {From: day(3, 0), Tariff: TariffEvery30}},
+		},
+		{
+			// Подключен 2 мая в 15:00, отключен 3 мая в 00:00: одни сутки.
+			Object: "79210010201", Operator: OperatorMegafon, Tags: []string{"acme"},
+			Tariffs: []TariffPeriod{{Tariff: TariffManual}},
+			Added:   day(2, 15), Off: day(3, 0),
+		},
+		{
+			Object: "79210010203", Operator: OperatorMTS,
+			Tariffs: []TariffPeriod{{Tariff: TariffOnline}},
+		},
+	}
+	events := ObjectEvents{
+		{EventID: 1, Phone: "79210010202", Event: RequestOkEvent, Timestamp: Time(day(1, 10))},
+		{EventID: 2, Phone: "79210010202", Event: RequestOkEvent, Timestamp: Time(day(2, 10))},
+		{EventID: 3, Phone: "79210010202", Event: RequestOkEvent, Timestamp: Time(day(3, 10))},
+		{EventID: 4, Phone: "79210010202", Event: RequestErrorEvent, Timestamp: Time(day(2, 11))},
+		{EventID: 5, Phone: "79210010201", Event: RequestOkEvent, Timestamp: Time(day(2, 16))},
+		{EventID: 6, Phone: "79210010201", Event: RequestOkEvent, Timestamp: Time(day(4, 16))},
+	}
+
+	if _, err := BuildCostLedger(b, objects, events, LedgerOptions{From: day(4, 0), To: day(1, 0)}); err == nil {
+		t.Error("BuildCostLedger() with invalid period should fail")
+	}
+
+	l, err := BuildCostLedger(b, objects, events, LedgerOptions{From: day(1, 0), To: day(4, 0)})
+	if err != nil {
+		t.Fatalf("BuildCostLedger() error = %v", err)
+	}
+	if len(l.Entries) != 3 || l.Entries[0].Object != "79210010201" {
+		t.Fatalf("BuildCostLedger() entries = %+v", l.Entries)
+	}
+
+	megafon, mts, online := l.Entries[0], l.Entries[1], l.Entries[2]
+	if megafon.Days != 1 || megafon.Requests != 1 || megafon.Total != 3+3.5 {
+		t.Errorf("megafon entry = %+v", megafon)
+	}
+	// 2 суток вручную по 4 и сутки по 135, запросы по 3 вручную и бесплатно по тарифу 30.
+	if mts.Days != 3 || mts.Requests != 3 || mts.Subscription != 4+4+135 || mts.RequestsCost != 6 {
+		t.Errorf("mts entry = %+v", mts)
+	}
+	if online.Total != 0 || len(online.Warnings) != 1 {
+		t.Errorf("entry with unknown tariff = %+v", online)
+	}
+	if math.Abs(l.Total-(6.5+149)) > 1e-9 {
+		t.Errorf("Total = %g", l.Total)
+	}
+
+	tags := l.ByTag()
+	if len(tags) != 3 || tags[0].Key != "" || tags[1].Key != "acme" || tags[1].Objects != 2 ||
+		tags[1].Total != 155.5 || tags[2].Key != "moscow" || tags[2].Total != 149 {
+		t.Errorf("ByTag() = %+v", tags)
+	}
+	customers := l.ByMetadata("customer")
+	if len(customers) != 2 || customers[1].Key != "ACME" || customers[1].Total != 149 {
+		t.Errorf("ByMetadata() = %+v", customers)
+	}
+	operators := l.ByOperator()
+	if len(operators) != 2 || operators[0].Key != "megafon" || operators[1].Objects != 2 {
+		t.Errorf("ByOperator() = %+v", operators)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteCostReportCSV(&buf, tags, CSVOptions{Comma: ';', DecimalComma: true}); err != nil {
+		t.Fatalf("WriteCostReportCSV() error = %v", err)
+	}
+	want := "key;objects;days;requests;subscription;requests_cost;total\r\n" +
+		";1;3;0;0,00;0,00;0,00\r\n" +
+		"acme;2;4;4;146,00;9,50;155,50\r\n" +
+		"moscow;1;3;3;143,00;6,00;149,00\r\n"
+	if buf.String() != want {
+		t.Errorf("WriteCostReportCSV() =\n%s\nwant\n%s", buf.String(), want)
+	}
+
+	buf.Reset()
+	if err := WriteCostLedgerCSV(&buf, l, CSVOptions{Columns: []string{"phone", "tags", "total"}}); err != nil {
+		t.Fatalf("WriteCostLedgerCSV() error = %v", err)
+	}
+	if !strings.Contains(buf.String(), "79210010202,\"acme,moscow\",149.00\r\n") {
+		t.Errorf("WriteCostLedgerCSV() =\n%s", buf.String())
+	}
+}
+
+func TestNewLedgerObject(t *testing.T) {
+	now := time.Date(2019, 5, 20, 15, 0, 0, 0, MoscowLocation)
+	next := TariffEvery15
+	oi := ObjectInfo{Phone: "79210010203", Tariff: TariffManual, TariffNew: &next,
+		TimestampAdd: Time(now.AddDate(0, 0, -10)), TimestampOff: Time(now.AddDate(0, 0, 5))}
+
+	lo := NewLedgerObject(oi, OperatorMTS, now, nil)
+	if len(lo.Tariffs) != 2 || !lo.Tariffs[1].From.Equal(time.Date(2019, 5, 21, 0, 0, 0, 0, MoscowLocation)) {
+		t.Errorf("NewLedgerObject() tariffs = %+v", lo.Tariffs)
+	}
+	if !lo.Off.IsZero() || !lo.Added.Equal(now.AddDate(0, 0, -10)) {
+		t.Errorf("NewLedgerObject() added %s, off %s", lo.Added, lo.Off)
+	}
+	if lo.tariffAt(now) != TariffManual || lo.tariffAt(now.Add(10*time.Hour)) != TariffEvery15 {
+		t.Error("tariffAt() should switch to TariffNew from the next day")
+	}
+}
+
+func TestAPI_CostLedger_afterEventID(t *testing.T) {
+	from := time.Date(2019, 5, 1, 0, 0, 0, 0, MoscowLocation)
+	to := from.AddDate(0, 0, 1)
+	api := poolTestAPI(t, "main", map[string]string{
+		"balance":     "balance.json",
+		"object_list": `[]`,
+		"events": fmt.Sprintf(`[{"id":"6","timestamp":"%d","phone":"79630005272","type":"request"},`+
+			`{"id":"7","timestamp":"%d","phone":"79630005272","type":"request"}]`, from.Unix(), to.Unix()),
+	}, nil)
+	var afterIDs []string
+	transport := api.Client.Transport
+	api.Client.Transport = roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		if strings.HasSuffix(req.URL.Path, "/events") {
+			afterIDs = append(afterIDs, req.URL.Query().Get("afterid"))
+		}
+		return transport.RoundTrip(req)
+	})
+
+	if _, err := api.CostLedger(LedgerOptions{From: from, To: to, AfterEventID: 5}); err != nil {
+		t.Fatalf("CostLedger() error = %v", err)
+	}
+	// Событие 7 после To: следующая страница не запрашивается.
+	if len(afterIDs) != 1 || afterIDs[0] != "5" {
+		t.Errorf("CostLedger() events afterid = %v, want [5]", afterIDs)
+	}
+}
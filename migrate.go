@@ -0,0 +1,50 @@
+package movizor
+
+import "fmt"
+
+// CloneObject подключает абонента to к мониторингу с теми же опциями, что и у ранее
+// добавленного абонента from (см. ObjectInfo.Options). Если slaveID не равен 0,
+// абонент добавляется в подчиненный кабинет.
+func (api *API) CloneObject(from, to Object, slaveID uint64) (APIResponse, error) {
+	oi, err := api.GetObjectInfo(from)
+	if err != nil {
+		return APIResponse{}, err
+	}
+
+	oo := oi.Options()
+	return api.AddObjectToSlave(to, &oo, slaveID)
+}
+
+// MoveObject переносит абонента в подчиненный кабинет slaveID (0 - в основной кабинет)
+// с сохранением опций мониторинга: абонент удаляется и добавляется заново.
+// Если добавить абонента не удалось, производится попытка вернуть его в основной кабинет
+// с прежними опциями. Опции проверяются до удаления: если их нельзя передать в сервис,
+// абонент не удаляется. Повторное подключение требует нового подтверждения от абонента.
+func (api *API) MoveObject(o Object, slaveID uint64) (APIResponse, error) {
+	oi, err := api.GetObjectInfo(o)
+	if err != nil {
+		return APIResponse{}, err
+	}
+	oo := oi.Options()
+	v, err := o.values()
+	if err != nil {
+		return APIResponse{}, err
+	}
+	if err := oo.addValuesTo(&v, api.Location); err != nil {
+		return APIResponse{}, fmt.Errorf("object %s is not moved: %s", o, err)
+	}
+
+	if resp, err := api.DeleteObject(o); err != nil {
+		return resp, err
+	}
+
+	resp, err := api.AddObjectToSlave(o, &oo, slaveID)
+	if err != nil {
+		if _, rerr := api.AddObject(o, &oo); rerr != nil {
+			return resp, fmt.Errorf("object %s is deleted and not restored: %s (restore: %s)", o, err, rerr)
+		}
+		return resp, fmt.Errorf("object %s is not moved and restored in the main account: %s", o, err)
+	}
+
+	return resp, nil
+}
@@ -0,0 +1,59 @@
+package movizor
+
+import (
+	"net/http"
+	"net/url"
+	"sync"
+	"testing"
+)
+
+func TestAPI_MoveObject(t *testing.T) {
+	api := poolTestAPI(t, "main", map[string]string{
+		"object_get":    "object_get1.json",
+		"object_delete": `null`,
+		"object_add":    `null`,
+	}, nil)
+	queries := map[string]url.Values{}
+	transport := api.Client.Transport
+	api.Client.Transport = roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		queries[req.URL.Path] = req.URL.Query()
+		return transport.RoundTrip(req)
+	})
+
+	if _, err := api.MoveObject("79630005272", 42); err != nil {
+		t.Fatalf("MoveObject() error = %v", err)
+	}
+	add := queries["/api/main/object_add"]
+	// Используется тариф со следующих суток (tariff_new).
+	if add.Get("account") != "42" || add.Get("tariff") != string(TariffEvery15) {
+		t.Errorf("MoveObject() object_add query = %v", add)
+	}
+}
+
+func TestAPI_MoveObject_invalidOptions(t *testing.T) {
+	var calls sync.Map
+	api := poolTestAPI(t, "main", map[string]string{
+		"object_get":    `{"phone":"79630005272","status":"ok","tariff":"manual","destination":[{"text":"","lat":55.75,"lon":37.61}]}`,
+		"object_delete": `null`,
+		"object_add":    `null`,
+	}, &calls)
+
+	if _, err := api.MoveObject("79630005272", 42); err == nil {
+		t.Fatal("MoveObject() with invalid options should fail")
+	}
+	if _, ok := calls.Load("main/object_delete"); ok {
+		t.Error("MoveObject() should not delete the object when its options are invalid")
+	}
+}
+
+func TestObjectInfo_Options_incompleteSchedule(t *testing.T) {
+	noTimes := ObjectInfo{Tariff: "manual", Schedule: &SchedulingOptions{}}
+	noTimes.Schedule.WeekdayOn(Monday)
+	oo := noTimes.Options()
+	if oo.Tariff != TariffManual {
+		t.Errorf("ObjectOptions.Tariff = %q, want %q", oo.Tariff, TariffManual)
+	}
+	if oo.Schedules != nil {
+		t.Errorf("ObjectOptions.Schedules = %v, want nil for schedule without times", oo.Schedules)
+	}
+}
@@ -0,0 +1,180 @@
+package movizor
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// DefaultTimeout - таймаут HTTP запроса клиентов, созданных New.
+const DefaultTimeout = 30 * time.Second
+
+// Logger выводит отладочные сообщения клиента. *log.Logger реализует Logger.
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+// Option задает настройку клиента для New.
+type Option func(o *clientOptions) error
+
+type clientOptions struct {
+	endpoint  string
+	client    *http.Client
+	timeout   time.Duration
+	logger    Logger
+	debug     bool
+	retry     RetryPolicy
+	rateLimit float64
+	userAgent string
+	location  *time.Location
+}
+
+// WithHTTPClient задает HTTP клиент. Клиент не изменяется: если задан и WithTimeout,
+// используется копия клиента с этим таймаутом.
+func WithHTTPClient(c *http.Client) Option {
+	return func(o *clientOptions) error {
+		if c == nil {
+			return errors.New("http client is nil")
+		}
+		o.client = c
+		return nil
+	}
+}
+
+// WithTimeout задает таймаут одной попытки HTTP запроса. 0 - без таймаута.
+// По умолчанию DefaultTimeout, для WithHTTPClient - таймаут переданного клиента.
+func WithTimeout(d time.Duration) Option {
+	return func(o *clientOptions) error {
+		if d < 0 {
+			return fmt.Errorf("negative timeout %s", d)
+		}
+		o.timeout = d
+		return nil
+	}
+}
+
+// WithEndpoint задает адрес сервиса вместо DefaultAPIMovizorEndpoint.
+func WithEndpoint(endp string) Option {
+	return func(o *clientOptions) error {
+		if err := validateEndpoint(endp); err != nil {
+			return err
+		}
+		o.endpoint = strings.TrimSuffix(endp, "/")
+		return nil
+	}
+}
+
+// WithLogger задает вывод отладочных сообщений (см. WithDebug).
+func WithLogger(l Logger) Option {
+	return func(o *clientOptions) error {
+		if l == nil {
+			return errors.New("logger is nil")
+		}
+		o.logger = l
+		return nil
+	}
+}
+
+// WithDebug включает вывод запросов и ответов сервиса.
+func WithDebug(debug bool) Option {
+	return func(o *clientOptions) error {
+		o.debug = debug
+		return nil
+	}
+}
+
+// WithRetry задает повтор запросов при сетевых ошибках и ответах 5xx (см. RetryPolicy).
+func WithRetry(rp RetryPolicy) Option {
+	return func(o *clientOptions) error {
+		if err := rp.validate(); err != nil {
+			return err
+		}
+		o.retry = rp
+		return nil
+	}
+}
+
+// WithRateLimit ограничивает частоту запросов: не более rps запросов в секунду
+// для всех горутин, использующих клиент. 0 - без ограничения.
+func WithRateLimit(rps float64) Option {
+	return func(o *clientOptions) error {
+		if rps < 0 {
+			return fmt.Errorf("negative rate limit %g", rps)
+		}
+		o.rateLimit = rps
+		return nil
+	}
+}
+
+// WithUserAgent задает заголовок User-Agent запросов.
+func WithUserAgent(ua string) Option {
+	return func(o *clientOptions) error {
+		if strings.TrimSpace(ua) == "" {
+			return errors.New("user agent is empty")
+		}
+		o.userAgent = ua
+		return nil
+	}
+}
+
+// WithLocation задает часовой пояс API.Location вместо MoscowLocation.
+func WithLocation(loc *time.Location) Option {
+	return func(o *clientOptions) error {
+		if loc == nil {
+			return errors.New("location is nil")
+		}
+		o.location = loc
+		return nil
+	}
+}
+
+// New создает экземпляр Movizor API для проекта project с ключом token.
+// По умолчанию используются DefaultAPIMovizorEndpoint, HTTP клиент с таймаутом
+// DefaultTimeout и MoscowLocation, без повторов и ограничения частоты запросов.
+func New(project, token string, opts ...Option) (*API, error) {
+	if project == "" {
+		return nil, errors.New("project is not set")
+	}
+	if token == "" {
+		return nil, errors.New("token is not set")
+	}
+
+	o := clientOptions{
+		endpoint: DefaultAPIMovizorEndpoint,
+		timeout:  -1,
+		location: MoscowLocation,
+	}
+	for _, opt := range opts {
+		if err := opt(&o); err != nil {
+			return nil, err
+		}
+	}
+
+	client := o.client
+	switch {
+	case client == nil:
+		client = &http.Client{Timeout: DefaultTimeout}
+		if o.timeout >= 0 {
+			client.Timeout = o.timeout
+		}
+	case o.timeout >= 0 && o.timeout != client.Timeout:
+		c := *client
+		c.Timeout = o.timeout
+		client = &c
+	}
+
+	return &API{
+		Endpoint:  o.endpoint,
+		Project:   project,
+		Token:     token,
+		Client:    client,
+		IsDebug:   o.debug,
+		Location:  o.location,
+		Retry:     o.retry,
+		Logger:    o.logger,
+		UserAgent: o.userAgent,
+		limiter:   newRateLimiter(o.rateLimit),
+	}, nil
+}
@@ -0,0 +1,117 @@
+package movizor
+
+import (
+	"bytes"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNew(t *testing.T) {
+	api, err := New("demo", "secret")
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if api.Endpoint != DefaultAPIMovizorEndpoint || api.Client.Timeout != DefaultTimeout ||
+		api.Location != MoscowLocation || api.limiter != nil || api.IsDebug {
+		t.Errorf("New() = %+v", api)
+	}
+
+	yekt := time.FixedZone("YEKT", 5*60*60)
+	api, err = New("demo", "secret",
+		WithEndpoint("http://localhost:8080/api/"),
+		WithTimeout(0),
+		WithRetry(RetryPolicy{Attempts: 2}),
+		WithRateLimit(10),
+		WithUserAgent("fleet/1.0"),
+		WithLocation(yekt),
+		WithDebug(true),
+	)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if api.Endpoint != "http://localhost:8080/api" || api.Client.Timeout != 0 || api.Retry.Attempts != 2 ||
+		api.limiter == nil || api.UserAgent != "fleet/1.0" || api.Location != yekt || !api.IsDebug {
+		t.Errorf("New() with options = %+v", api)
+	}
+}
+
+func TestNew_errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		project string
+		token   string
+		opts    []Option
+	}{
+		{name: "no project", token: "secret"},
+		{name: "no token", project: "demo"},
+		{name: "nil client", opts: []Option{WithHTTPClient(nil)}},
+		{name: "negative timeout", opts: []Option{WithTimeout(-time.Second)}},
+		{name: "bad endpoint", opts: []Option{WithEndpoint("movizor.ru/api")}},
+		{name: "nil logger", opts: []Option{WithLogger(nil)}},
+		{name: "negative retries", opts: []Option{WithRetry(RetryPolicy{Attempts: -1})}},
+		{name: "negative rate limit", opts: []Option{WithRateLimit(-1)}},
+		{name: "empty user agent", opts: []Option{WithUserAgent(" ")}},
+		{name: "nil location", opts: []Option{WithLocation(nil)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.project == "" && tt.token == "" {
+				tt.project, tt.token = "demo", "secret"
+			}
+			if api, err := New(tt.project, tt.token, tt.opts...); err == nil {
+				t.Errorf("New() = %+v, want error", api)
+			}
+		})
+	}
+}
+
+func TestNew_httpClient(t *testing.T) {
+	client := &http.Client{Timeout: time.Minute}
+	api, err := New("demo", "secret", WithHTTPClient(client))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if api.Client != client {
+		t.Error("New() should use the client as is")
+	}
+
+	api, err = New("demo", "secret", WithHTTPClient(client), WithTimeout(time.Second))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if api.Client == client || api.Client.Timeout != time.Second || client.Timeout != time.Minute {
+		t.Errorf("WithTimeout() should use a copy of the client: %s, original %s", api.Client.Timeout, client.Timeout)
+	}
+}
+
+func TestNew_loggerAndUserAgent(t *testing.T) {
+	var buf bytes.Buffer
+	var userAgent string
+	api, err := New("demo", "secret",
+		WithEndpoint("http://movizor.test/api"),
+		WithLogger(log.New(&buf, "", 0)),
+		WithDebug(true),
+		WithUserAgent("fleet/1.0"),
+		WithHTTPClient(&http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			userAgent = req.Header.Get("User-Agent")
+			return &http.Response{StatusCode: http.StatusOK,
+				Body: ioutil.NopCloser(strings.NewReader(`{"result":"success","code":"OK"}`))}, nil
+		})}),
+	)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if _, err := api.MakeRequest("balance", nil); err != nil {
+		t.Fatalf("MakeRequest() error = %v", err)
+	}
+	if userAgent != "fleet/1.0" {
+		t.Errorf("User-Agent = %q", userAgent)
+	}
+	if !strings.HasPrefix(buf.String(), "INFO: request: http://movizor.test/api/demo/balance") {
+		t.Errorf("log = %q", buf.String())
+	}
+}
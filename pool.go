@@ -0,0 +1,433 @@
+package movizor
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Account - кабинет МоВизора в пуле клиентов. Каждый кабинет пула, в том числе подчиненный,
+// подключается со своим проектом и ключом API: списки объектов, события и баланс сервис
+// возвращает только для проекта, к которому обращается клиент. Все запросы по кабинету,
+// включая AddObject, выполняются от имени его проекта.
+type Account struct {
+	Name    string // Метка кабинета в результатах, уникальная в пуле
+	Project string // Проект кабинета, если API не задан
+	Token   string // Ключ API кабинета, если API не задан
+	// SlaveID - ID подчиненного кабинета в основном кабинете ("Номер клиента"), 0 - основной
+	// кабинет. Только для отчетов (AccountBalance): запросы выполняются через API кабинета.
+	SlaveID uint64
+	API     *API // Клиент кабинета. nil - создается New(Project, Token, opts...)
+}
+
+// PoolError содержит ошибки отдельных кабинетов при обращении ко всем кабинетам пула.
+// Результаты остальных кабинетов при этом возвращаются.
+type PoolError struct {
+	Errors map[string]error // Ошибки по имени кабинета
+}
+
+func (e *PoolError) Error() string {
+	names := make([]string, 0, len(e.Errors))
+	for name := range e.Errors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	msgs := make([]string, len(names))
+	for i, name := range names {
+		msgs[i] = fmt.Sprintf("%s: %s", name, e.Errors[name])
+	}
+	return fmt.Sprintf("%d account(s) failed: %s", len(names), strings.Join(msgs, "; "))
+}
+
+// ErrObjectNotInPool возвращается, если объект не найден ни в одном кабинете пула.
+var ErrObjectNotInPool = errors.New("object is not found in any account")
+
+// Pool объединяет несколько кабинетов (основной и подчиненные): направляет запросы
+// по объекту в кабинет, в котором он подключен, и собирает списки объектов,
+// местоположений, событий и балансов со всех кабинетов.
+type Pool struct {
+	// Concurrency - количество одновременных запросов к кабинетам. 0 - DefaultSyncConcurrency
+	Concurrency int
+
+	accounts []Account
+	index    map[string]int
+
+	mu     sync.RWMutex
+	routes map[string]string // Нормализованный номер -> имя кабинета
+}
+
+// NewPool создает пул кабинетов. Для кабинетов без API клиент создается через New
+// с опциями opts. Имена кабинетов должны быть непустыми и уникальными, проекты кабинетов
+// не должны повторяться, иначе объекты одного проекта учитывались бы дважды.
+func NewPool(accounts []Account, opts ...Option) (*Pool, error) {
+	if len(accounts) == 0 {
+		return nil, errors.New("pool has no accounts")
+	}
+	p := &Pool{
+		accounts: make([]Account, len(accounts)),
+		index:    make(map[string]int, len(accounts)),
+		routes:   make(map[string]string),
+	}
+	projects := make(map[string]string, len(accounts))
+	for i, acc := range accounts {
+		if acc.Name == "" {
+			return nil, fmt.Errorf("account #%d has no name", i+1)
+		}
+		if _, ok := p.index[acc.Name]; ok {
+			return nil, fmt.Errorf("duplicate account %q", acc.Name)
+		}
+		if acc.API == nil {
+			api, err := New(acc.Project, acc.Token, opts...)
+			if err != nil {
+				return nil, fmt.Errorf("account %q: %s", acc.Name, err)
+			}
+			acc.API = api
+		}
+		if other, ok := projects[acc.API.Project]; ok {
+			return nil, fmt.Errorf("accounts %q and %q use the same project %q", other, acc.Name, acc.API.Project)
+		}
+		projects[acc.API.Project] = acc.Name
+		p.accounts[i] = acc
+		p.index[acc.Name] = i
+	}
+	return p, nil
+}
+
+// Accounts возвращает кабинеты пула в порядке добавления.
+func (p *Pool) Accounts() []Account {
+	out := make([]Account, len(p.accounts))
+	copy(out, p.accounts)
+	return out
+}
+
+// Account возвращает кабинет по имени.
+func (p *Pool) Account(name string) (Account, bool) {
+	i, ok := p.index[name]
+	if !ok {
+		return Account{}, false
+	}
+	return p.accounts[i], true
+}
+
+// each вызывает f для всех кабинетов, не более Concurrency одновременно.
+// Ошибки собираются в *PoolError.
+func (p *Pool) each(f func(acc Account) error) error {
+	concurrency := p.Concurrency
+	if concurrency <= 0 {
+		concurrency = DefaultSyncConcurrency
+	}
+	errs := parallel(len(p.accounts), concurrency, func(i int) error {
+		return f(p.accounts[i])
+	})
+	pe := &PoolError{Errors: make(map[string]error)}
+	for i, err := range errs {
+		if err != nil {
+			pe.Errors[p.accounts[i].Name] = err
+		}
+	}
+	if len(pe.Errors) > 0 {
+		return pe
+	}
+	return nil
+}
+
+// AccountObject - объект мониторинга с меткой кабинета.
+type AccountObject struct {
+	Account string `json:"account"`
+	ObjectStatus
+}
+
+// GetObjects получает списки объектов всех кабинетов и перестраивает таблицу маршрутизации:
+// объекты, которых больше нет в кабинете, из нее удаляются. Для кабинетов, список которых
+// получить не удалось, сохраняются прежние записи. Если объект подключен в нескольких кабинетах, запросы по нему направляются в первый
+// по порядку пула. Результат упорядочен по кабинетам в порядке пула и номерам.
+func (p *Pool) GetObjects() ([]AccountObject, error) {
+	lists := make([]ObjectsWithStatus, len(p.accounts))
+	err := p.each(func(acc Account) error {
+		list, err := acc.API.GetObjects()
+		lists[p.index[acc.Name]] = list
+		return err
+	})
+
+	var failed map[string]error
+	if pe, ok := err.(*PoolError); ok {
+		failed = pe.Errors
+	}
+
+	var out []AccountObject
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	routes := make(map[string]string, len(p.routes))
+	for phone, name := range p.routes {
+		if _, ok := failed[name]; ok {
+			routes[phone] = name
+		}
+	}
+	for i := len(lists) - 1; i >= 0; i-- {
+		if _, ok := failed[p.accounts[i].Name]; ok {
+			continue
+		}
+		for _, st := range lists[i] {
+			routes[st.Phone.String()] = p.accounts[i].Name
+		}
+	}
+	p.routes = routes
+	for i, list := range lists {
+		sort.Sort(list)
+		for _, st := range list {
+			out = append(out, AccountObject{Account: p.accounts[i].Name, ObjectStatus: st})
+		}
+	}
+	return out, err
+}
+
+// Route закрепляет объект за кабинетом, например после подключения объекта в обход пула.
+func (p *Pool) Route(o Object, name string) error {
+	if _, ok := p.index[name]; !ok {
+		return fmt.Errorf("unknown account %q", name)
+	}
+	p.mu.Lock()
+	p.routes[o.String()] = name
+	p.mu.Unlock()
+	return nil
+}
+
+// AccountFor возвращает кабинет, в котором подключен объект. Если объекта нет
+// в таблице маршрутизации, она обновляется через GetObjects.
+func (p *Pool) AccountFor(o Object) (Account, error) {
+	phone := o.String()
+	if phone == "" {
+		return Account{}, fmt.Errorf("invalid format of phone number: %s", string(o))
+	}
+	if acc, ok := p.route(phone); ok {
+		return acc, nil
+	}
+	if _, err := p.GetObjects(); err != nil {
+		if acc, ok := p.route(phone); ok {
+			return acc, nil
+		}
+		return Account{}, err
+	}
+	if acc, ok := p.route(phone); ok {
+		return acc, nil
+	}
+	return Account{}, fmt.Errorf("%s: %s", phone, ErrObjectNotInPool)
+}
+
+func (p *Pool) route(phone string) (Account, bool) {
+	p.mu.RLock()
+	name, ok := p.routes[phone]
+	p.mu.RUnlock()
+	if !ok {
+		return Account{}, false
+	}
+	return p.accounts[p.index[name]], true
+}
+
+// API возвращает клиент кабинета, в котором подключен объект, для остальных методов API.
+func (p *Pool) API(o Object) (*API, error) {
+	acc, err := p.AccountFor(o)
+	if err != nil {
+		return nil, err
+	}
+	return acc.API, nil
+}
+
+// GetObjectInfo получает информацию об объекте в его кабинете.
+func (p *Pool) GetObjectInfo(o Object) (ObjectInfo, error) {
+	api, err := p.API(o)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	return api.GetObjectInfo(o)
+}
+
+// EditObjectWithActivate изменяет опции объекта в его кабинете.
+func (p *Pool) EditObjectWithActivate(o Object, oo *ObjectOptions, activate bool) (APIResponse, error) {
+	api, err := p.API(o)
+	if err != nil {
+		return APIResponse{}, err
+	}
+	return api.EditObjectWithActivate(o, oo, activate)
+}
+
+// DeleteObject удаляет объект из его кабинета.
+func (p *Pool) DeleteObject(o Object) (APIResponse, error) {
+	api, err := p.API(o)
+	if err != nil {
+		return APIResponse{}, err
+	}
+	resp, err := api.DeleteObject(o)
+	if err == nil {
+		p.mu.Lock()
+		delete(p.routes, o.String())
+		p.mu.Unlock()
+	}
+	return resp, err
+}
+
+// ReactivateObject повторно подключает объект в его кабинете.
+func (p *Pool) ReactivateObject(o Object) (APIResponse, error) {
+	api, err := p.API(o)
+	if err != nil {
+		return APIResponse{}, err
+	}
+	return api.ReactivateObject(o)
+}
+
+// GetObjectLastPosition получает последнее местоположение объекта в его кабинете.
+func (p *Pool) GetObjectLastPosition(o Object) (Position, error) {
+	api, err := p.API(o)
+	if err != nil {
+		return Position{}, err
+	}
+	return api.GetObjectLastPosition(o)
+}
+
+// GetObjectPositions получает местоположения объекта в его кабинете.
+func (p *Pool) GetObjectPositions(o Object, rpo *RequestPositionsOptions) (Positions, error) {
+	api, err := p.API(o)
+	if err != nil {
+		return Positions{}, err
+	}
+	return api.GetObjectPositions(o, rpo)
+}
+
+// RequestPosition запрашивает местоположение объекта в его кабинете.
+func (p *Pool) RequestPosition(o Object) (PositionRequest, error) {
+	api, err := p.API(o)
+	if err != nil {
+		return PositionRequest{}, err
+	}
+	return api.RequestPosition(o)
+}
+
+// AddObject подключает объект в кабинете name через его API (см. Account)
+// и закрепляет его за этим кабинетом.
+func (p *Pool) AddObject(name string, o Object, oo *ObjectOptions) (APIResponse, error) {
+	acc, ok := p.Account(name)
+	if !ok {
+		return APIResponse{}, fmt.Errorf("unknown account %q", name)
+	}
+	resp, err := acc.API.AddObject(o, oo)
+	if err == nil {
+		err = p.Route(o, name)
+	}
+	return resp, err
+}
+
+// AccountPosition - местоположение объекта с меткой кабинета.
+type AccountPosition struct {
+	Account string `json:"account"`
+	ObjectPosition
+}
+
+// GetObjectsPositions получает местоположения объектов всех кабинетов.
+// Результат упорядочен по кабинетам в порядке пула.
+func (p *Pool) GetObjectsPositions() ([]AccountPosition, error) {
+	lists := make([]ObjectPositions, len(p.accounts))
+	err := p.each(func(acc Account) error {
+		list, err := acc.API.GetObjectsPositions()
+		lists[p.index[acc.Name]] = list
+		return err
+	})
+
+	var out []AccountPosition
+	for i, list := range lists {
+		for _, op := range list {
+			out = append(out, AccountPosition{Account: p.accounts[i].Name, ObjectPosition: op})
+		}
+	}
+	return out, err
+}
+
+// AccountEvent - событие с меткой кабинета.
+type AccountEvent struct {
+	Account string `json:"account"`
+	ObjectEvent
+}
+
+// GetEvents получает события всех кабинетов. ID событий у каждого кабинета свои, поэтому
+// after содержит ID последнего полученного события по имени кабинета (nil - с начала).
+// Возвращаются события, упорядоченные по времени, и обновленные ID для следующего вызова.
+func (p *Pool) GetEvents(after map[string]int64) ([]AccountEvent, map[string]int64, error) {
+	lists := make([]ObjectEvents, len(p.accounts))
+	err := p.each(func(acc Account) error {
+		list, err := acc.API.GetEvents(ObjectEventsOptions{AfterEventID: uint64(after[acc.Name])})
+		lists[p.index[acc.Name]] = list
+		return err
+	})
+
+	next := make(map[string]int64, len(p.accounts))
+	for name, id := range after {
+		next[name] = id
+	}
+	var out []AccountEvent
+	for i, list := range lists {
+		name := p.accounts[i].Name
+		for _, e := range list {
+			out = append(out, AccountEvent{Account: name, ObjectEvent: e})
+			if e.EventID > next[name] {
+				next[name] = e.EventID
+			}
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		ti, tj := out[i].Timestamp.Time(), out[j].Timestamp.Time()
+		if !ti.Equal(tj) {
+			return ti.Before(tj)
+		}
+		if out[i].Account != out[j].Account {
+			return p.index[out[i].Account] < p.index[out[j].Account]
+		}
+		return out[i].EventID < out[j].EventID
+	})
+	return out, next, err
+}
+
+// AccountBalance - баланс кабинета.
+type AccountBalance struct {
+	Account string  `json:"account"`
+	SlaveID uint64  `json:"slave_id,omitempty"`
+	Balance Balance `json:"balance"`
+	Err     error   `json:"-"` // Ошибка получения баланса, Balance при этом нулевой
+}
+
+// BalanceRollup - сводка балансов кабинетов пула.
+type BalanceRollup struct {
+	Accounts []AccountBalance `json:"accounts"` // В порядке пула
+	Balance  float64          `json:"balance"`  // Сумма остатков полученных балансов
+	Credit   float64          `json:"credit"`   // Сумма кредитных средств полученных балансов
+}
+
+// Lowest возвращает кабинет с наименьшим остатком средств среди полученных балансов.
+func (r BalanceRollup) Lowest() (AccountBalance, bool) {
+	var lowest AccountBalance
+	found := false
+	for _, ab := range r.Accounts {
+		if ab.Err == nil && (!found || ab.Balance.Balance < lowest.Balance.Balance) {
+			lowest, found = ab, true
+		}
+	}
+	return lowest, found
+}
+
+// GetBalances получает балансы всех кабинетов. Ошибки отдельных кабинетов возвращаются
+// в AccountBalance.Err и в *PoolError, суммы считаются по остальным кабинетам.
+func (p *Pool) GetBalances() (BalanceRollup, error) {
+	r := BalanceRollup{Accounts: make([]AccountBalance, len(p.accounts))}
+	err := p.each(func(acc Account) error {
+		b, err := acc.API.GetBalance()
+		r.Accounts[p.index[acc.Name]] = AccountBalance{Account: acc.Name, SlaveID: acc.SlaveID, Balance: b, Err: err}
+		return err
+	})
+	for _, ab := range r.Accounts {
+		if ab.Err == nil {
+			r.Balance += ab.Balance.Balance
+			r.Credit += ab.Balance.Credit
+		}
+	}
+	return r, err
+}
@@ -0,0 +1,221 @@
+package movizor
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// poolTestAPI возвращает клиент, отвечающий на действия данными из responses
+// (имя файла в test-data или JSON) без обращения к сети.
+func poolTestAPI(t *testing.T, project string, responses map[string]string, calls *sync.Map) *API {
+	t.Helper()
+	api, err := New(project, "secret", WithEndpoint("http://movizor.test/api"),
+		WithHTTPClient(&http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			action := req.URL.Path[strings.LastIndex(req.URL.Path, "/")+1:]
+			if calls != nil {
+				n, _ := calls.LoadOrStore(project+"/"+action, new(int))
+				*n.(*int)++
+			}
+			data, ok := responses[action]
+			if !ok {
+				return nil, errors.New("connection refused")
+			}
+			if strings.HasSuffix(data, ".json") {
+				d, err := ioutil.ReadFile(filepath.Join(dataPath, data))
+				if err != nil {
+					return nil, err
+				}
+				data = string(d)
+			}
+			body := fmt.Sprintf(`{"result":"success","code":"OK","data":%s}`, data)
+			return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader(body))}, nil
+		})}))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	return api
+}
+
+func TestNewPool_errors(t *testing.T) {
+	api := poolTestAPI(t, "main", nil, nil)
+	tests := []struct {
+		name     string
+		accounts []Account
+	}{
+		{name: "empty"},
+		{name: "no name", accounts: []Account{{API: api}}},
+		{name: "duplicate", accounts: []Account{{Name: "a", API: api}, {Name: "a", API: api}}},
+		{name: "no credentials", accounts: []Account{{Name: "a"}}},
+		{name: "same project", accounts: []Account{{Name: "a", API: api}, {Name: "b", API: poolTestAPI(t, "main", nil, nil)}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewPool(tt.accounts); err == nil {
+				t.Error("NewPool() should fail")
+			}
+		})
+	}
+
+	p, err := NewPool([]Account{{Name: "a", Project: "demo", Token: "secret", SlaveID: 12}})
+	if err != nil {
+		t.Fatalf("NewPool() error = %v", err)
+	}
+	if acc, ok := p.Account("a"); !ok || acc.API == nil || acc.API.Project != "demo" || acc.SlaveID != 12 {
+		t.Errorf("Account() = %+v", acc)
+	}
+}
+
+func TestPool(t *testing.T) {
+	var calls sync.Map
+	main := poolTestAPI(t, "main", map[string]string{
+		"object_list": "object_list.json",
+		"object_get":  "object_get1.json",
+		"balance":     "balance.json",
+		"pos_objects": "pos_objects.json",
+		"events":      `[{"id":"7","timestamp":"1548076100","phone":"79050005727","type":"off"}]`,
+	}, &calls)
+	south := poolTestAPI(t, "south", map[string]string{
+		"object_list": `[{"phone":"79210010203","status":"ok"},{"phone":"79050005727","status":"ok"}]`,
+		"events":      "events.json",
+	}, &calls)
+	p, err := NewPool([]Account{{Name: "main", API: main}, {Name: "south", API: south, SlaveID: 5}})
+	if err != nil {
+		t.Fatalf("NewPool() error = %v", err)
+	}
+
+	objects, err := p.GetObjects()
+	if err != nil {
+		t.Fatalf("GetObjects() error = %v", err)
+	}
+	if len(objects) != 9 || objects[0].Account != "main" || objects[8].Account != "south" ||
+		objects[8].Phone != "79210010203" {
+		t.Errorf("GetObjects() = %+v", objects)
+	}
+
+	// Объект, подключенный в нескольких кабинетах, направляется в первый по порядку пула.
+	for phone, want := range map[Object]string{"79050005727": "main", "+7 921 001-02-03": "south"} {
+		acc, err := p.AccountFor(phone)
+		if err != nil || acc.Name != want {
+			t.Errorf("AccountFor(%s) = %s, %v, want %s", phone, acc.Name, err, want)
+		}
+	}
+	if _, err := p.AccountFor("79990000000"); err == nil || !strings.Contains(err.Error(), ErrObjectNotInPool.Error()) {
+		t.Errorf("AccountFor() error = %v, want %v", err, ErrObjectNotInPool)
+	}
+	if n, _ := calls.Load("main/object_list"); *n.(*int) != 2 {
+		t.Errorf("object_list calls = %d, want 2 (refresh for unknown object only)", *n.(*int))
+	}
+
+	if _, err := p.GetObjectInfo("79050005727"); err != nil {
+		t.Errorf("GetObjectInfo() error = %v", err)
+	}
+	if _, ok := calls.Load("south/object_get"); ok {
+		t.Error("GetObjectInfo() should be routed to main")
+	}
+
+	positions, err := p.GetObjectsPositions()
+	pe, ok := err.(*PoolError)
+	if !ok || len(pe.Errors) != 1 || pe.Errors["south"] == nil {
+		t.Fatalf("GetObjectsPositions() error = %v, want south failure", err)
+	}
+	if len(positions) != 2 || positions[0].Account != "main" {
+		t.Errorf("GetObjectsPositions() = %+v", positions)
+	}
+
+	events, next, err := p.GetEvents(map[string]int64{"south": 1})
+	if err != nil {
+		t.Fatalf("GetEvents() error = %v", err)
+	}
+	if len(events) != 11 || events[10].Account != "main" || events[10].EventID != 7 {
+		t.Errorf("GetEvents() last = %+v of %d", events[len(events)-1], len(events))
+	}
+	for i := 1; i < len(events); i++ {
+		if events[i].Timestamp.Time().Before(events[i-1].Timestamp.Time()) {
+			t.Fatalf("GetEvents() is not ordered by time at %d", i)
+		}
+	}
+	if next["main"] != 7 || next["south"] != 35425471 {
+		t.Errorf("GetEvents() next = %v", next)
+	}
+
+	rollup, err := p.GetBalances()
+	if pe, ok := err.(*PoolError); !ok || pe.Errors["south"] == nil {
+		t.Fatalf("GetBalances() error = %v, want south failure", err)
+	}
+	if rollup.Balance != 476.5 || rollup.Accounts[1].Err == nil || rollup.Accounts[1].SlaveID != 5 {
+		t.Errorf("GetBalances() = %+v", rollup)
+	}
+	if lowest, ok := rollup.Lowest(); !ok || lowest.Account != "main" {
+		t.Errorf("Lowest() = %+v", lowest)
+	}
+}
+
+func TestPool_AddObject(t *testing.T) {
+	main := poolTestAPI(t, "main", map[string]string{"object_list": "[]"}, nil)
+	south := poolTestAPI(t, "south", map[string]string{"object_list": "[]", "object_add": `null`}, nil)
+	var query url.Values
+	transport := south.Client.Transport
+	south.Client.Transport = roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		if strings.HasSuffix(req.URL.Path, "/object_add") {
+			query = req.URL.Query()
+		}
+		return transport.RoundTrip(req)
+	})
+	p, err := NewPool([]Account{{Name: "main", API: main}, {Name: "south", API: south, SlaveID: 5}})
+	if err != nil {
+		t.Fatalf("NewPool() error = %v", err)
+	}
+
+	if _, err := p.AddObject("north", "79210010209", nil); err == nil {
+		t.Error("AddObject() to unknown account should fail")
+	}
+	if _, err := p.AddObject("south", "79210010209", &ObjectOptions{Title: "Сидоров"}); err != nil {
+		t.Fatalf("AddObject() error = %v", err)
+	}
+	// Подчиненный кабинет подключен своим проектом, account не передается.
+	if query.Get("account") != "" || query.Get("title") != "Сидоров" {
+		t.Errorf("AddObject() object_add query = %v", query)
+	}
+	if acc, err := p.AccountFor("79210010209"); err != nil || acc.Name != "south" {
+		t.Errorf("AccountFor() = %s, %v, want south", acc.Name, err)
+	}
+}
+
+func TestPool_GetObjects_routes(t *testing.T) {
+	mainResponses := map[string]string{"object_list": `[{"phone":"79050005727","status":"ok"}]`}
+	southResponses := map[string]string{"object_list": `[{"phone":"79210010203","status":"ok"},{"phone":"79210010204","status":"ok"}]`}
+	p, err := NewPool([]Account{
+		{Name: "main", API: poolTestAPI(t, "main", mainResponses, nil)},
+		{Name: "south", API: poolTestAPI(t, "south", southResponses, nil)},
+	})
+	if err != nil {
+		t.Fatalf("NewPool() error = %v", err)
+	}
+	if _, err := p.GetObjects(); err != nil {
+		t.Fatalf("GetObjects() error = %v", err)
+	}
+
+	// Объект удален из south, список main получить не удалось.
+	southResponses["object_list"] = `[{"phone":"79210010203","status":"ok"}]`
+	delete(mainResponses, "object_list")
+	if _, err := p.GetObjects(); err == nil {
+		t.Fatal("GetObjects() should fail for main")
+	}
+
+	want := map[string]string{"79050005727": "main", "79210010203": "south"}
+	if len(p.routes) != len(want) {
+		t.Errorf("routes = %v, want %v", p.routes, want)
+	}
+	for phone, name := range want {
+		if p.routes[phone] != name {
+			t.Errorf("routes[%s] = %q, want %q", phone, p.routes[phone], name)
+		}
+	}
+}
@@ -52,18 +52,33 @@ func (o Object) values() (url.Values, error) {
 	return url.Values{"phone": {p}}, nil
 }
 
-// Coordinate - гео-координата.
-// ToDo: сделать проверку на допустимые значения и возможно заменить на float64
-type Coordinate float32
+// Coordinate - гео-координата (широта или долгота).
+type Coordinate float64
 
 // Float32 возвращает гео-координату в виде float32.
+// Оставлено для совместимости, точность float32 около метра.
 func (c Coordinate) Float32() float32 {
 	return float32(c)
 }
 
+// Float64 возвращает гео-координату в виде float64.
+func (c Coordinate) Float64() float64 {
+	return float64(c)
+}
+
 // String возвращает гео-координату в виде строки формата "%.8f"
 func (c Coordinate) String() string {
-	return fmt.Sprintf("%.8f", c.Float32())
+	return fmt.Sprintf("%.8f", c.Float64())
+}
+
+// IsValidLat проверяет, является ли координата допустимой широтой [-90, 90].
+func (c Coordinate) IsValidLat() bool {
+	return c >= -90 && c <= 90
+}
+
+// IsValidLon проверяет, является ли координата допустимой долготой [-180, 180].
+func (c Coordinate) IsValidLon() bool {
+	return c >= -180 && c <= 180
 }
 
 func (c *Coordinate) UnmarshalJSON(data []byte) (err error) {
@@ -73,16 +88,101 @@ func (c *Coordinate) UnmarshalJSON(data []byte) (err error) {
 		return
 	}
 
-	var val float32
-	val, err = jsonNumberToFloat32(num)
+	var val float64
+	val, err = num.Float64()
 	if err != nil {
 		return
 	}
 
+	// Широта или долгота здесь неизвестна, поэтому проверяется самый широкий диапазон.
+	// Проверка широты производится на уровне Coordinates.
+	if !Coordinate(val).IsValidLon() {
+		return fmt.Errorf("coordinate %s is out of range [-180, 180]", num)
+	}
+
 	*c = Coordinate(val)
 	return nil
 }
 
+// MoscowLocation - часовой пояс, в котором сервис МоВизор принимает и отдает локальное время.
+// Если база часовых поясов недоступна, используется фиксированное смещение UTC+3.
+var MoscowLocation = loadMoscowLocation()
+
+func loadMoscowLocation() *time.Location {
+	loc, err := time.LoadLocation("Europe/Moscow")
+	if err != nil {
+		return time.FixedZone("MSK", 3*60*60)
+	}
+	return loc
+}
+
+// destinationTimeLayout - формат времени прибытия в точку назначения.
+const destinationTimeLayout = "02.01.2006 15:04"
+
+// destinationTimeLayouts - форматы времени прибытия, которые может вернуть сервис.
+// Первым указан основной формат, в котором время передается в сервис.
+var destinationTimeLayouts = []string{
+	destinationTimeLayout,
+	"02.01.2006 15:04:05",
+	"02.01.2006",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	time.RFC3339,
+}
+
+// parseDestinationTime разбирает время прибытия в точку назначения в часовом поясе сервиса loc.
+// Пустое значение возвращается как нулевое время. Допускается Unix Timestamp.
+func parseDestinationTime(s string, loc *time.Location) (time.Time, error) {
+	loc = serviceLocation(loc)
+	s = strings.TrimSpace(s)
+	if s == "" || s == "0" {
+		return time.Time{}, nil
+	}
+
+	if ts, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Unix(ts, 0).In(loc), nil
+	}
+
+	for _, layout := range destinationTimeLayouts {
+		if t, err := time.ParseInLocation(layout, s, loc); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("invalid destination time %q, should be in format %s", s, destinationTimeLayout)
+}
+
+// serviceLocation возвращает часовой пояс сервиса: указанный или MoscowLocation, если не указан.
+func serviceLocation(loc *time.Location) *time.Location {
+	if loc == nil {
+		return MoscowLocation
+	}
+	return loc
+}
+
+// formatDestinationTime форматирует время прибытия в точку назначения для передачи в сервис
+// в часовом поясе сервиса loc.
+func formatDestinationTime(t time.Time, loc *time.Location) string {
+	return t.In(serviceLocation(loc)).Format(destinationTimeLayout)
+}
+
+// scheduleTimeIn переводит время срабатывания расписания в часовой пояс сервиса loc
+// и возвращает его как time.Date(0, 1, 1, hh, mm, 0, 0, loc) вместе со сдвигом дня недели
+// (-1, 0 или 1), если при переводе время переходит через полночь.
+// Учитываются только часы и минуты t в его часовом поясе. Они переносятся на текущую дату,
+// чтобы для значений вроде time.Date(0, 1, 1, 8, 0, 0, 0, loc) использовалось актуальное
+// смещение часового пояса, а не историческое.
+func scheduleTimeIn(t time.Time, loc *time.Location) (time.Time, int) {
+	loc = serviceLocation(loc)
+	now := time.Now().In(t.Location())
+	local := time.Date(now.Year(), now.Month(), now.Day(), t.Hour(), t.Minute(), 0, 0, t.Location())
+	st := local.In(loc)
+	day := func(t time.Time) int64 {
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC).Unix() / (24 * 60 * 60)
+	}
+	return time.Date(0, 1, 1, st.Hour(), st.Minute(), 0, 0, loc), int(day(st) - day(local))
+}
+
 // Time - временная метка.
 type Time time.Time
 
@@ -91,6 +191,11 @@ func (t Time) Time() time.Time {
 	return time.Time(t)
 }
 
+// MarshalJSON представляет временную метку числом секунд Unix, как ее возвращает сервис.
+func (t Time) MarshalJSON() ([]byte, error) {
+	return json.Marshal(t.Time().Unix())
+}
+
 func (t *Time) UnmarshalJSON(data []byte) error {
 	var num json.Number
 	err := json.Unmarshal(data, &num)
@@ -107,6 +212,38 @@ func (t *Time) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
+// jsonFlag - логический признак, который сервис может вернуть как true/false,
+// 1/0, "1"/"0" или null.
+type jsonFlag bool
+
+func (f *jsonFlag) UnmarshalJSON(data []byte) error {
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	switch val := v.(type) {
+	case nil:
+		*f = false
+	case bool:
+		*f = jsonFlag(val)
+	case float64:
+		*f = val != 0
+	case string:
+		switch strings.ToLower(strings.TrimSpace(val)) {
+		case "", "0", "false", "off":
+			*f = false
+		case "1", "true", "on":
+			*f = true
+		default:
+			return fmt.Errorf("invalid flag value %q", val)
+		}
+	default:
+		return fmt.Errorf("invalid flag value %s", data)
+	}
+	return nil
+}
+
 // Int - определение типа int для unmarshaling json с возможным
 // значением json null.
 type Int int
@@ -134,11 +271,3 @@ func (i *Int) UnmarshalJSON(data []byte) error {
 	*i = Int(val)
 	return nil
 }
-
-func jsonNumberToFloat32(number json.Number) (float32, error) {
-	val, err := number.Float64()
-	if err != nil {
-		return 0.0, err
-	}
-	return float32(val), nil
-}
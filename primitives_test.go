@@ -176,6 +176,37 @@ func TestCoordinate_Float32(t *testing.T) {
 	}
 }
 
+func TestCoordinate_Float64(t *testing.T) {
+	tests := []struct {
+		name string
+		c    Coordinate
+		want float64
+	}{
+		{
+			name: "zero",
+			c:    Coordinate(0.0),
+			want: 0.0,
+		},
+		{
+			name: "precise",
+			c:    Coordinate(55.79863118),
+			want: 55.79863118,
+		},
+		{
+			name: "negative",
+			c:    Coordinate(-0.00000001),
+			want: -0.00000001,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.c.Float64(); got != tt.want {
+				t.Errorf("Coordinate.Float64() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
 func TestCoordinate_String(t *testing.T) {
 	tests := []struct {
 		name string
@@ -197,11 +228,11 @@ func TestCoordinate_String(t *testing.T) {
 			c:    Coordinate(-180.0),
 			want: "-180.00000000",
 		},
-		//{
-		//	name: "nice format 4",
-		//	c:    Coordinate(179.99999999),
-		//	want: "180.00000000",
-		//},
+		{
+			name: "nice format 4",
+			c:    Coordinate(179.99999999),
+			want: "179.99999999",
+		},
 		{
 			name: "nice format 5",
 			c:    Coordinate(-0.00000001),
@@ -267,6 +298,22 @@ func TestCoordinate_UnmarshalJSON(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "out of range",
+			c:    new(Coordinate),
+			args: args{
+				data: []byte(`"200.0"`),
+			},
+			wantErr: true,
+		},
+		{
+			name: "out of range negative",
+			c:    new(Coordinate),
+			args: args{
+				data: []byte("-180.00000001"),
+			},
+			wantErr: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
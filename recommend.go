@@ -0,0 +1,194 @@
+package movizor
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// tariffOrder - порядок тарифов при равной стоимости: предпочитаются более простые тарифы.
+var tariffOrder = []TariffType{TariffManual, TariffEvery180, TariffEvery60, TariffEvery30, TariffEvery15, TariffOnline, TariffOneMonth}
+
+// RecommendOptions предоставляет допущения подбора тарифа.
+type RecommendOptions struct {
+	// ForecastOptions.ManualRequestsPerDay - фактическое количество запросов местоположения
+	// в сутки сверх расписания, PackageDays - срок пакета TariffOneMonth.
+	ForecastOptions
+	// Tariffs - тарифы, из которых выбирается рекомендация. nil - все известные тарифы оператора
+	Tariffs []TariffType
+	// MinSavings - минимальная экономия в сутки, при которой рекомендуется смена тарифа
+	MinSavings float64
+}
+
+// coversRequests возвращает true, если тариф tt обеспечивает perDay запросов местоположения
+// в сутки: тарифы с автоматическими запросами заменяют ручные запросы до своей частоты,
+// остальные тарифы (вручную, онлайн, пакет) - любое их количество.
+func coversRequests(tt TariffType, perDay float64) bool {
+	auto := tariffRequestsPerDay(tt)
+	return auto == 0 || perDay <= auto
+}
+
+func (ro RecommendOptions) allowed(tt TariffType) bool {
+	if !tt.IsKnown() {
+		return false
+	}
+	if ro.Tariffs == nil {
+		return true
+	}
+	for _, t := range ro.Tariffs {
+		if t == tt {
+			return true
+		}
+	}
+	return false
+}
+
+// TariffRecommendation - рекомендованный тариф объекта.
+type TariffRecommendation struct {
+	Object           Object
+	Operator         Operator
+	RequestsPerDay   float64       // Запросы в сутки сверх расписания, по которым подбирался тариф
+	Current          TariffType    // Тариф со следующих суток (с учетом TariffNew)
+	CurrentDaily     float64       // Суточные расходы по Current
+	Recommended      TariffType    // Самый дешевый подходящий тариф, Current при экономии меньше MinSavings
+	RecommendedDaily float64       // Суточные расходы по Recommended
+	Savings          float64       // CurrentDaily - RecommendedDaily
+	Options          []ObjectSpend // Прогноз по всем подходящим тарифам по возрастанию стоимости
+}
+
+// Change возвращает true, если рекомендуется сменить тариф.
+func (r TariffRecommendation) Change() bool {
+	return r.Recommended != r.Current
+}
+
+// RecommendTariff подбирает самый дешевый тариф оператора op из баланса b для объекта
+// с тарифом current, расписанием s и ManualRequestsPerDay запросами в сутки сверх расписания
+// (см. ForecastObjectSpend). Тарифы с автоматическими запросами подходят, только если
+// запрашивают местоположение не реже: каждые 3 часа - не больше 8 запросов в сутки.
+// При равной стоимости предпочитается текущий тариф. Если текущий тариф не подходит,
+// рекомендуется самый дешевый подходящий, даже если он дороже текущего.
+func RecommendTariff(b Balance, op Operator, current TariffType, s *SchedulingOptions, opts RecommendOptions) (TariffRecommendation, error) {
+	current = current.Normalize()
+	cur, err := ForecastObjectSpend(b, op, current, s, opts.ForecastOptions)
+	if err != nil {
+		return TariffRecommendation{}, err
+	}
+
+	r := TariffRecommendation{
+		Operator:       op,
+		RequestsPerDay: opts.ManualRequestsPerDay,
+		Current:        current,
+		CurrentDaily:   cur.Daily,
+	}
+	for _, tt := range tariffOrder {
+		if _, ok := b.OperatorTariffs[op][tt]; !ok || !opts.allowed(tt) || !coversRequests(tt, opts.ManualRequestsPerDay) {
+			continue
+		}
+		os, err := ForecastObjectSpend(b, op, tt, s, opts.ForecastOptions)
+		if err != nil {
+			return TariffRecommendation{}, err
+		}
+		r.Options = append(r.Options, os)
+	}
+	sort.SliceStable(r.Options, func(i, j int) bool {
+		oi, oj := r.Options[i], r.Options[j]
+		if oi.Daily != oj.Daily {
+			return oi.Daily < oj.Daily
+		}
+		return oi.Tariff == current && oj.Tariff != current
+	})
+
+	r.Recommended, r.RecommendedDaily = current, cur.Daily
+	if len(r.Options) > 0 {
+		best := r.Options[0]
+		savings := cur.Daily - best.Daily
+		if !coversRequests(current, opts.ManualRequestsPerDay) || savings > 0 && savings >= opts.MinSavings {
+			r.Recommended, r.RecommendedDaily = best.Tariff, best.Daily
+		}
+	}
+	r.Savings = r.CurrentDaily - r.RecommendedDaily
+	return r, nil
+}
+
+// RecommendTariffs подбирает тарифы объектов в статусе StatusOk по фактической частоте
+// успешных запросов местоположения (RequestOkEvent) за период [from, to). Запросы
+// по расписанию вычитаются. Остальные запросы, в том числе автоматические по текущему
+// тарифу, должен обеспечить рекомендованный тариф (см. RecommendTariff).
+// ManualRequestsPerDay в opts не используется. События загружаются постранично с начала
+// журнала сервиса до to, поэтому при длинном журнале вызов выполняет много запросов.
+func (api *API) RecommendTariffs(from, to time.Time, opts RecommendOptions) ([]TariffRecommendation, error) {
+	if !to.After(from) {
+		return nil, fmt.Errorf("invalid period %s - %s", from, to)
+	}
+	days := to.Sub(from).Hours() / 24
+
+	b, err := api.GetBalance()
+	if err != nil {
+		return nil, err
+	}
+	objects, err := api.GetObjects()
+	if err != nil {
+		return nil, err
+	}
+	var active []Object
+	for _, st := range objects {
+		if st.Status == StatusOk {
+			active = append(active, st.Phone)
+		}
+	}
+
+	events, err := api.eventsUntil(0, to)
+	if err != nil {
+		return nil, err
+	}
+	requests := make(map[string]int)
+	for _, e := range events {
+		if t := e.Timestamp.Time(); e.Event == RequestOkEvent && !t.Before(from) && t.Before(to) {
+			requests[e.Phone.String()]++
+		}
+	}
+
+	recs := make([]TariffRecommendation, len(active))
+	errs := parallel(len(active), DefaultSyncConcurrency, func(i int) error {
+		oi, err := api.GetObjectInfo(active[i])
+		if err != nil {
+			return err
+		}
+		op, err := api.GetOperatorInfo(active[i])
+		if err != nil {
+			return err
+		}
+		o := opts
+		observed := float64(requests[active[i].String()]) / days
+		o.ManualRequestsPerDay = math.Max(observed-scheduleRequestsPerDay(oi.Schedule), 0)
+		recs[i], err = RecommendTariff(b, op.Operator, forecastTariff(oi), oi.Schedule, o)
+		recs[i].Object = active[i]
+		return err
+	})
+	for i, err := range errs {
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", active[i], err)
+		}
+	}
+	sort.SliceStable(recs, func(i, j int) bool { return recs[i].Savings > recs[j].Savings })
+	return recs, nil
+}
+
+// ApplyTariffRecommendation меняет тариф объекта на рекомендованный без активации:
+// новый тариф действует со следующих суток и до этого может быть отменен
+// UndoTariffRecommendation. Если смена тарифа не рекомендуется, возвращается ошибка.
+func (api *API) ApplyTariffRecommendation(r TariffRecommendation) (APIResponse, error) {
+	if !r.Change() {
+		return APIResponse{}, errors.New("tariff change is not recommended")
+	}
+	return api.EditObjectWithActivate(r.Object, &ObjectOptions{Tariff: r.Recommended}, false)
+}
+
+// UndoTariffRecommendation отменяет смену тарифа, запланированную на следующие сутки
+// (CancelTariffChangeObject). Отменяется любая запланированная смена тарифа объекта,
+// в том числе сделанная до ApplyTariffRecommendation.
+func (api *API) UndoTariffRecommendation(r TariffRecommendation) (APIResponse, error) {
+	return api.CancelTariffChangeObject(r.Object)
+}
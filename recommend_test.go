@@ -0,0 +1,145 @@
+package movizor
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRecommendTariff(t *testing.T) {
+	b := loadTestBalance(t)
+	tests := []struct {
+		name        string
+		op          Operator
+		current     TariffType
+		opts        RecommendOptions
+		recommended TariffType
+		savings     float64
+		wantErr     bool
+	}{
+		// У МТС пакет за 90 на 30 суток дешевле всех тарифов.
+		{"mts package", OperatorMTS, TariffEvery15, RecommendOptions{}, TariffOneMonth, 280 - 3, false},
+		// 10 запросов вручную: 4 + 10*3 = 34 дешевле 36 за каждые 3 часа.
+		{"mts few requests", OperatorMTS, "manual",
+			RecommendOptions{ForecastOptions: ForecastOptions{ManualRequestsPerDay: 10}, Tariffs: []TariffType{TariffManual, TariffEvery180}},
+			TariffManual, 0, false},
+		// 24 запроса: каждые 3 часа не обеспечивают такую частоту, каждые 60 минут - 75 против 4 + 24*3.
+		{"mts many requests", OperatorMTS, TariffManual,
+			RecommendOptions{ForecastOptions: ForecastOptions{ManualRequestsPerDay: 24}, Tariffs: []TariffType{TariffManual, TariffEvery180, TariffEvery60}},
+			TariffEvery60, 76 - 75, false},
+		{"savings below minimum", OperatorMTS, TariffManual,
+			RecommendOptions{ForecastOptions: ForecastOptions{ManualRequestsPerDay: 24}, Tariffs: []TariffType{TariffManual, TariffEvery60}, MinSavings: 30},
+			TariffManual, 0, false},
+		// Опрос каждые 15 минут обеспечивают только тарифы каждые 15 минут и онлайн.
+		{"megafon frequent polling", OperatorMegafon, TariffManual,
+			RecommendOptions{ForecastOptions: ForecastOptions{ManualRequestsPerDay: 96},
+				Tariffs: []TariffType{TariffManual, TariffOnline, TariffEvery15, TariffEvery30, TariffEvery60}},
+			TariffEvery15, 3 + 96*3.5 - 30, false},
+		{"tele2 polling more often than every 15 minutes", OperatorTele2, TariffManual,
+			RecommendOptions{ForecastOptions: ForecastOptions{ManualRequestsPerDay: 120},
+				Tariffs: []TariffType{TariffManual, TariffOnline, TariffEvery15, TariffEvery60}},
+			TariffOnline, 0.5 + 120*1.5 - 22, false},
+		// Текущий тариф не обеспечивает 40 запросов в сутки: рекомендуется более дорогой.
+		{"current tariff does not cover requests", OperatorMegafon, TariffEvery60,
+			RecommendOptions{ForecastOptions: ForecastOptions{ManualRequestsPerDay: 40}, Tariffs: []TariffType{TariffEvery60, TariffEvery30, TariffEvery15}},
+			TariffEvery30, 25 - 28, false},
+		// У Мегафона онлайн за 35 дешевле 3 + 20*3.5 вручную, но дороже каждых 60 минут.
+		{"megafon", OperatorMegafon, TariffManual,
+			RecommendOptions{ForecastOptions: ForecastOptions{ManualRequestsPerDay: 20}, Tariffs: []TariffType{TariffManual, TariffOnline, TariffEvery60}},
+			TariffEvery60, 73 - 25, false},
+		{"unknown current tariff", OperatorMTS, TariffOnline, RecommendOptions{}, "", 0, true},
+		{"unknown operator", Operator("yota"), TariffManual, RecommendOptions{}, "", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := RecommendTariff(b, tt.op, tt.current, nil, tt.opts)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("RecommendTariff() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if r.Recommended != tt.recommended || r.Savings != tt.savings {
+				t.Errorf("RecommendTariff() = %s saving %g, want %s saving %g", r.Recommended, r.Savings, tt.recommended, tt.savings)
+			}
+			for i := 1; i < len(r.Options); i++ {
+				if r.Options[i-1].Daily > r.Options[i].Daily {
+					t.Errorf("RecommendTariff() options are not sorted: %+v", r.Options)
+				}
+			}
+		})
+	}
+}
+
+func TestAPI_RecommendTariffs(t *testing.T) {
+	from := time.Date(2019, 5, 1, 0, 0, 0, 0, MoscowLocation)
+	to := from.AddDate(0, 0, 2)
+	// 20 запросов за 2 суток на тарифе manual и событие после периода.
+	var events []string
+	for i := 0; i <= 20; i++ {
+		at := from.Add(time.Duration(i) * 2 * time.Hour)
+		if i == 20 {
+			at = to
+		}
+		events = append(events, fmt.Sprintf(`{"id":"%d","timestamp":"%d","phone":"79630005272","type":"request"}`, i+1, at.Unix()))
+	}
+
+	var calls sync.Map
+	api := poolTestAPI(t, "main", map[string]string{
+		"balance":              "balance.json",
+		"object_list":          `[{"phone":"79630005272","status":"ok"},{"phone":"79210010203","status":"off"}]`,
+		"object_get":           "object_get1.json",
+		"get_operator":         "get_operator.json",
+		"events":               "[" + strings.Join(events, ",") + "]",
+		"object_edit":          `null`,
+		"object_cancel_tariff": `null`,
+	}, &calls)
+	var queries []url.Values
+	transport := api.Client.Transport
+	api.Client.Transport = roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		queries = append(queries, req.URL.Query())
+		return transport.RoundTrip(req)
+	})
+
+	if _, err := api.RecommendTariffs(to, from, RecommendOptions{}); err == nil {
+		t.Error("RecommendTariffs() with invalid period should fail")
+	}
+
+	recs, err := api.RecommendTariffs(from, to, RecommendOptions{Tariffs: []TariffType{TariffManual, TariffEvery180, TariffEvery15}})
+	if err != nil {
+		t.Fatalf("RecommendTariffs() error = %v", err)
+	}
+	// Со следующих суток тариф 15 за 280, вручную 10 запросов в сутки: 4 + 30.
+	// Каждые 3 часа - только 8 запросов в сутки, тариф не подходит.
+	if len(recs) != 1 {
+		t.Fatalf("RecommendTariffs() = %+v", recs)
+	}
+	r := recs[0]
+	if r.Object != "79630005272" || r.Operator != OperatorMTS || r.RequestsPerDay != 10 || r.Current != TariffEvery15 ||
+		r.Recommended != TariffManual || r.Savings != 280-34 || len(r.Options) != 2 {
+		t.Errorf("RecommendTariffs() = %+v", r)
+	}
+
+	queries = nil
+	if _, err := api.ApplyTariffRecommendation(r); err != nil {
+		t.Fatalf("ApplyTariffRecommendation() error = %v", err)
+	}
+	if len(queries) != 1 || queries[0].Get("tariff") != "0" || queries[0].Get("activate") != "" {
+		t.Errorf("ApplyTariffRecommendation() queries = %v", queries)
+	}
+	if _, err := api.UndoTariffRecommendation(r); err != nil {
+		t.Fatalf("UndoTariffRecommendation() error = %v", err)
+	}
+	if n, ok := calls.Load("main/object_cancel_tariff"); !ok || *n.(*int) != 1 {
+		t.Error("UndoTariffRecommendation() should cancel the tariff change")
+	}
+
+	r.Recommended = r.Current
+	if _, err := api.ApplyTariffRecommendation(r); err == nil {
+		t.Error("ApplyTariffRecommendation() without change should fail")
+	}
+}
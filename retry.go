@@ -0,0 +1,143 @@
+package movizor
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// DefaultRetryDelay - пауза перед первым повтором запроса по умолчанию.
+const DefaultRetryDelay = 500 * time.Millisecond
+
+// RetryPolicy задает повтор запросов при сетевых ошибках и ответах сервиса 5xx и 429.
+// Ошибки, которые вернул сам сервис (result "error"), не повторяются.
+// При сетевых ошибках и ответах 5xx повторяются только действия, которые ничего не изменяют
+// (см. IsReadOnlyAction): запрос object_add или pos_request мог дойти до сервиса, и повтор
+// выполнил бы действие дважды. Остальные действия повторяются, только если запрос заведомо
+// не был обработан: не удалось установить соединение или сервис ответил 429.
+type RetryPolicy struct {
+	Attempts int           // Количество повторов. 0 - без повторов
+	Delay    time.Duration // Пауза перед первым повтором, далее удваивается. 0 - DefaultRetryDelay
+	MaxDelay time.Duration // Максимальная пауза между повторами. 0 - без ограничения
+}
+
+func (rp RetryPolicy) validate() error {
+	if rp.Attempts < 0 {
+		return fmt.Errorf("negative retry attempts %d", rp.Attempts)
+	}
+	if rp.Delay < 0 || rp.MaxDelay < 0 {
+		return fmt.Errorf("negative retry delay")
+	}
+	return nil
+}
+
+// delay возвращает паузу перед повтором с номером attempt, начиная с 0.
+func (rp RetryPolicy) delay(attempt int) time.Duration {
+	d := rp.Delay
+	if d <= 0 {
+		d = DefaultRetryDelay
+	}
+	for i := 0; i < attempt; i++ {
+		d *= 2
+		if rp.MaxDelay > 0 && d >= rp.MaxDelay {
+			break
+		}
+	}
+	if rp.MaxDelay > 0 && d > rp.MaxDelay {
+		d = rp.MaxDelay
+	}
+	return d
+}
+
+func isRetryableStatus(code int) bool {
+	return code >= http.StatusInternalServerError || code == http.StatusTooManyRequests
+}
+
+// readOnlyActions - действия API, которые только получают данные.
+var readOnlyActions = map[string]bool{
+	"balance":               true,
+	"events":                true,
+	"events_subscribe_list": true,
+	"get_operator":          true,
+	"object_get":            true,
+	"object_list":           true,
+	"pos_get":               true,
+	"pos_last":              true,
+	"pos_list":              true,
+	"pos_objects":           true,
+}
+
+// IsReadOnlyAction возвращает true, если действие API только получает данные и его можно
+// безопасно повторить при любой сетевой ошибке или ответе 5xx.
+func IsReadOnlyAction(action string) bool {
+	return readOnlyActions[action]
+}
+
+// shouldRetry определяет, можно ли повторить запрос действия, завершившийся ответом resp
+// или ошибкой err.
+func shouldRetry(readOnly bool, resp *http.Response, err error) bool {
+	if err != nil {
+		return readOnly || isNotSent(err)
+	}
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return true
+	}
+	return readOnly && isRetryableStatus(resp.StatusCode)
+}
+
+// isNotSent возвращает true, если запрос заведомо не был отправлен: не удалось
+// установить соединение с сервисом.
+func isNotSent(err error) bool {
+	for {
+		ue, ok := err.(*url.Error)
+		if !ok {
+			break
+		}
+		err = ue.Err
+	}
+	oe, ok := err.(*net.OpError)
+	return ok && oe.Op == "dial"
+}
+
+// rateLimiter равномерно распределяет запросы: не чаще одного в interval.
+type rateLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+	now      func() time.Time
+	sleep    func(time.Duration)
+}
+
+// newRateLimiter возвращает ограничение rate запросов в секунду, nil при rate <= 0.
+func newRateLimiter(rate float64) *rateLimiter {
+	if rate <= 0 {
+		return nil
+	}
+	return &rateLimiter{
+		interval: time.Duration(float64(time.Second) / rate),
+		now:      time.Now,
+		sleep:    time.Sleep,
+	}
+}
+
+// wait ожидает очереди на запрос. Для nil ограничение не действует.
+func (rl *rateLimiter) wait() {
+	if rl == nil {
+		return
+	}
+	rl.mu.Lock()
+	now := rl.now()
+	at := rl.next
+	if at.Before(now) {
+		at = now
+	}
+	rl.next = at.Add(rl.interval)
+	rl.mu.Unlock()
+
+	if d := at.Sub(now); d > 0 {
+		rl.sleep(d)
+	}
+}
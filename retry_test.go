@@ -0,0 +1,144 @@
+package movizor
+
+import (
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicy_delay(t *testing.T) {
+	tests := []struct {
+		rp      RetryPolicy
+		attempt int
+		want    time.Duration
+	}{
+		{RetryPolicy{}, 0, DefaultRetryDelay},
+		{RetryPolicy{Delay: time.Second}, 0, time.Second},
+		{RetryPolicy{Delay: time.Second}, 3, 8 * time.Second},
+		{RetryPolicy{Delay: time.Second, MaxDelay: 5 * time.Second}, 3, 5 * time.Second},
+		{RetryPolicy{Delay: time.Second, MaxDelay: 5 * time.Second}, 100, 5 * time.Second},
+	}
+	for _, tt := range tests {
+		if got := tt.rp.delay(tt.attempt); got != tt.want {
+			t.Errorf("%+v.delay(%d) = %s, want %s", tt.rp, tt.attempt, got, tt.want)
+		}
+	}
+}
+
+func TestRateLimiter_wait(t *testing.T) {
+	now := time.Date(2019, 5, 20, 10, 0, 0, 0, time.UTC)
+	var slept []time.Duration
+	rl := newRateLimiter(4)
+	rl.now = func() time.Time { return now }
+	rl.sleep = func(d time.Duration) { slept = append(slept, d) }
+
+	rl.wait()
+	rl.wait()
+	rl.wait()
+	now = now.Add(time.Second)
+	rl.wait()
+
+	want := []time.Duration{250 * time.Millisecond, 500 * time.Millisecond}
+	if len(slept) != len(want) || slept[0] != want[0] || slept[1] != want[1] {
+		t.Errorf("slept = %v, want %v", slept, want)
+	}
+
+	if newRateLimiter(0) != nil {
+		t.Error("newRateLimiter(0) should disable the limit")
+	}
+	var disabled *rateLimiter
+	disabled.wait()
+}
+
+type roundTripFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
+
+func TestAPI_MakeRequest_retry(t *testing.T) {
+	calls := 0
+	api, err := NewMovizorAPIWithEndpoint("http://movizor.test/api", "demo", "secret")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	api.Retry = RetryPolicy{Attempts: 2, Delay: time.Millisecond}
+	api.Client.Transport = roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		calls++
+		switch calls {
+		case 1:
+			return nil, errors.New("connection reset")
+		case 2:
+			return &http.Response{StatusCode: http.StatusBadGateway, Status: "502 Bad Gateway",
+				Body: ioutil.NopCloser(strings.NewReader("bad gateway"))}, nil
+		}
+		return &http.Response{StatusCode: http.StatusOK,
+			Body: ioutil.NopCloser(strings.NewReader(`{"result":"success","code":"OK","message":"Balance info"}`))}, nil
+	})
+
+	resp, err := api.MakeRequest("balance", nil)
+	if err != nil {
+		t.Fatalf("MakeRequest() error = %v", err)
+	}
+	if calls != 3 || resp.MessageType != "Balance info" {
+		t.Errorf("MakeRequest() = %+v after %d calls", resp, calls)
+	}
+
+	calls = 0
+	api.Retry.Attempts = 0
+	if _, err := api.MakeRequest("balance", nil); err == nil || calls != 1 {
+		t.Errorf("MakeRequest() without retries error = %v after %d calls", err, calls)
+	}
+}
+
+func TestAPI_MakeRequest_retryWrite(t *testing.T) {
+	tests := []struct {
+		name      string
+		resp      *http.Response
+		err       error
+		wantCalls int
+	}{
+		{"connection reset", nil, errors.New("connection reset"), 1},
+		{"bad gateway", &http.Response{StatusCode: http.StatusBadGateway, Status: "502 Bad Gateway"}, nil, 1},
+		{"too many requests", &http.Response{StatusCode: http.StatusTooManyRequests, Status: "429 Too Many Requests"}, nil, 3},
+		{"dial error", nil, &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api, err := NewMovizorAPIWithEndpoint("http://movizor.test/api", "demo", "secret")
+			if err != nil {
+				t.Fatalf("err: %s", err)
+			}
+			api.Retry = RetryPolicy{Attempts: 2, Delay: time.Millisecond}
+			calls := 0
+			api.Client.Transport = roundTripFunc(func(req *http.Request) (*http.Response, error) {
+				calls++
+				if tt.err != nil {
+					return nil, tt.err
+				}
+				resp := *tt.resp
+				resp.Body = ioutil.NopCloser(strings.NewReader("error"))
+				return &resp, nil
+			})
+
+			// pos_request мог дойти до сервиса: повтор выполнил бы платный запрос дважды.
+			api.MakeRequest("pos_request", url.Values{"phone": {"79630005272"}})
+			if calls != tt.wantCalls {
+				t.Errorf("MakeRequest(pos_request) calls = %d, want %d", calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestAPI_MakeRequest_invalidEndpoint(t *testing.T) {
+	api, err := NewMovizorAPIWithEndpoint("https://movizor.ru:port/api", "demo", "secret")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if _, err := api.MakeRequest("balance", nil); err == nil || !strings.Contains(err.Error(), "invalid endpoint") {
+		t.Errorf("MakeRequest() error = %v, want invalid endpoint", err)
+	}
+}
@@ -0,0 +1,547 @@
+package movizor
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// MinScheduleInterval - минимальный интервал между запросами в расписании ParseSchedule
+	// (как у тарифа TariffEvery15).
+	MinScheduleInterval = 15 * time.Minute
+	// MaxScheduleTimes - максимальное количество запросов в расписании на сутки.
+	MaxScheduleTimes = int(24 * time.Hour / MinScheduleInterval)
+)
+
+var weekdayNames = [7]string{"Mon", "Tue", "Wed", "Thu", "Fri", "Sat", "Sun"}
+
+// String возвращает краткое английское название дня недели (Mon, Tue, ...).
+func (d Weekday) String() string {
+	if d < Monday || d > Sunday {
+		return fmt.Sprintf("Weekday(%d)", int(d))
+	}
+	return weekdayNames[d]
+}
+
+// weekdayAliases - допустимые названия дней недели в выражениях расписания.
+var weekdayAliases = map[string]Weekday{
+	"mon": Monday, "monday": Monday, "пн": Monday,
+	"tue": Tuesday, "tuesday": Tuesday, "вт": Tuesday,
+	"wed": Wednesday, "wednesday": Wednesday, "ср": Wednesday,
+	"thu": Thursday, "thursday": Thursday, "чт": Thursday,
+	"fri": Friday, "friday": Friday, "пт": Friday,
+	"sat": Saturday, "saturday": Saturday, "сб": Saturday,
+	"sun": Sunday, "sunday": Sunday, "вс": Sunday,
+}
+
+// ParseSchedule создает расписание запросов по выражению. Поддерживаются две формы.
+//
+// Выражение из дней недели, времени и необязательного шага:
+//
+//	Mon-Fri 08:00-20:00 every 30m
+//	Sat,Sun 10:00,14:00,18:00
+//	daily 09:00
+//
+// Дни недели указываются списком и диапазонами (Mon-Fri, Fri-Mon, Пн-Пт) или словами
+// daily (или *), weekdays и weekends. Время - список hh:mm или диапазон hh:mm-hh:mm,
+// для диапазона обязателен шаг every (в формате time.ParseDuration).
+//
+// Подмножество crontab из пяти полей "минуты часы * * дни_недели":
+//
+//	*/30 8-19 * * 1-5
+//	0 9,18 * * *
+//
+// В полях минут, часов и дней недели допускаются *, списки, диапазоны и шаг (/n),
+// дни недели указываются числами 0-7 (0 и 7 - воскресенье) или названиями.
+// Дни месяца и месяцы не поддерживаются сервисом и должны быть *.
+//
+// Время расписания задается в часовом поясе loc, nil - MoscowLocation.
+// Полученное расписание проверяется методом Validate.
+func ParseSchedule(expr string, loc *time.Location) (*SchedulingOptions, error) {
+	loc = serviceLocation(loc)
+	fields := strings.Fields(expr)
+	if len(fields) == 0 {
+		return nil, errors.New("empty schedule expression")
+	}
+
+	var (
+		days    []Weekday
+		minutes []int
+		err     error
+	)
+	if isCronSchedule(fields) {
+		days, minutes, err = parseCronSchedule(fields)
+	} else {
+		days, minutes, err = parseTextSchedule(fields)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid schedule %q: %s", expr, err)
+	}
+
+	s := &SchedulingOptions{}
+	for _, d := range days {
+		s.WeekdayOn(d)
+	}
+	for _, m := range minutes {
+		s.FireAt = append(s.FireAt, time.Date(0, 1, 1, m/60, m%60, 0, 0, loc))
+	}
+	if err := s.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid schedule %q: %s", expr, err)
+	}
+	return s, nil
+}
+
+// MustParseSchedule аналогичен ParseSchedule, но паникует при ошибке.
+// Предназначен для расписаний, заданных константами.
+func MustParseSchedule(expr string, loc *time.Location) *SchedulingOptions {
+	s, err := ParseSchedule(expr, loc)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// Validate проверяет расписание, полученное ParseSchedule: должен быть включен хотя бы один
+// день недели, указано от 1 до MaxScheduleTimes различных значений времени, отстоящих друг
+// от друга не менее чем на MinScheduleInterval. Ограничения на количество и интервал
+// защищают от опечаток в выражениях и не проверяются при передаче расписания в сервис.
+func (s *SchedulingOptions) Validate() error {
+	if len(s.FireAt) <= 0 {
+		return errors.New("time to fire scheduling is not set (set FireAt property)")
+	}
+	if len(s.Weekdays()) == 0 {
+		return errors.New("no single weekday to fire scheduling is set")
+	}
+	if len(s.FireAt) > MaxScheduleTimes {
+		return fmt.Errorf("too many times to fire scheduling: %d, maximum is %d", len(s.FireAt), MaxScheduleTimes)
+	}
+
+	minutes := s.minutes()
+	step := int(MinScheduleInterval / time.Minute)
+	for i := range minutes {
+		next := minutes[(i+1)%len(minutes)]
+		if i+1 == len(minutes) {
+			// Интервал через полночь.
+			next += 24 * 60
+		}
+		if len(minutes) > 1 && next-minutes[i] < step {
+			return fmt.Errorf("times %s and %s are closer than %s",
+				formatScheduleMinute(minutes[i]), formatScheduleMinute(next%(24*60)), MinScheduleInterval)
+		}
+	}
+	return nil
+}
+
+// In возвращает расписание в часовом поясе loc (nil - MoscowLocation): время FireAt
+// переводится в loc, а дни недели сдвигаются на сутки, если время при переводе переходит
+// через полночь. Например, Mon 22:00 UTC соответствует Tue 01:00 MSK. Сервис хранит один
+// набор дней недели на все время расписания, поэтому, если разным значениям FireAt после
+// перевода соответствуют разные дни недели, возвращается ошибка.
+func (s *SchedulingOptions) In(loc *time.Location) (*SchedulingOptions, error) {
+	out := &SchedulingOptions{weekdays: s.weekdays}
+	for i, t := range s.FireAt {
+		st, shift := scheduleTimeIn(t, loc)
+		var days [7]bool
+		for d, on := range s.weekdays {
+			if on {
+				days[((d+shift)%7+7)%7] = true
+			}
+		}
+		if i > 0 && days != out.weekdays {
+			return nil, fmt.Errorf("schedule times %s and %s fall on different weekdays in %s",
+				s.FireAt[0].Format("15:04"), t.Format("15:04"), serviceLocation(loc))
+		}
+		out.weekdays = days
+		out.FireAt = append(out.FireAt, st)
+	}
+	return out, nil
+}
+
+// Weekdays возвращает включенные дни недели по порядку.
+func (s *SchedulingOptions) Weekdays() []Weekday {
+	var days []Weekday
+	for d := Monday; d <= Sunday; d++ {
+		if s.IsWeekdayOn(d) {
+			days = append(days, d)
+		}
+	}
+	return days
+}
+
+// String возвращает расписание в виде выражения, понятного ParseSchedule, например
+// "Mon-Fri 08:00-20:00 every 30m". Время выводится в часовом поясе значений FireAt.
+func (s *SchedulingOptions) String() string {
+	if s == nil {
+		return ""
+	}
+	var parts []string
+	if days := formatScheduleDays(s.Weekdays()); days != "" {
+		parts = append(parts, days)
+	}
+	if times := formatScheduleTimes(s.minutes()); times != "" {
+		parts = append(parts, times)
+	}
+	return strings.Join(parts, " ")
+}
+
+// scheduleJSON - представление расписания в JSON: дни недели (Mon, Tue, ...),
+// время hh:mm и часовой пояс, в котором задано время.
+type scheduleJSON struct {
+	Weekdays []string `json:"weekdays"`
+	Times    []string `json:"times"`
+	Location string   `json:"location,omitempty"`
+}
+
+// MarshalJSON представляет расписание объектом с днями недели, временем и часовым поясом
+// значений FireAt, например {"weekdays":["Mon"],"times":["08:00"],"location":"Europe/Moscow"}.
+func (s SchedulingOptions) MarshalJSON() ([]byte, error) {
+	sj := scheduleJSON{Weekdays: []string{}, Times: []string{}}
+	for _, d := range s.Weekdays() {
+		sj.Weekdays = append(sj.Weekdays, d.String())
+	}
+	for _, t := range s.FireAt {
+		sj.Times = append(sj.Times, t.Format("15:04"))
+	}
+	if len(s.FireAt) > 0 {
+		sj.Location = s.FireAt[0].Location().String()
+	}
+	return json.Marshal(sj)
+}
+
+// UnmarshalJSON разбирает расписание в формате MarshalJSON. Если часовой пояс не указан,
+// время разбирается в MoscowLocation.
+func (s *SchedulingOptions) UnmarshalJSON(data []byte) error {
+	var sj scheduleJSON
+	if err := json.Unmarshal(data, &sj); err != nil {
+		return err
+	}
+
+	loc := MoscowLocation
+	if sj.Location != "" && sj.Location != MoscowLocation.String() {
+		var err error
+		if loc, err = time.LoadLocation(sj.Location); err != nil {
+			return fmt.Errorf("invalid schedule location: %s", err)
+		}
+	}
+
+	out := SchedulingOptions{}
+	for _, name := range sj.Weekdays {
+		d, ok := weekdayAliases[strings.ToLower(name)]
+		if !ok {
+			return fmt.Errorf("unknown weekday %s", name)
+		}
+		out.WeekdayOn(d)
+	}
+	for _, st := range sj.Times {
+		m, err := parseScheduleMinute(st)
+		if err != nil {
+			return err
+		}
+		out.FireAt = append(out.FireAt, time.Date(0, 1, 1, m/60, m%60, 0, 0, loc))
+	}
+	*s = out
+	return nil
+}
+
+// minutes возвращает отсортированные минуты от начала суток для значений FireAt.
+func (s *SchedulingOptions) minutes() []int {
+	minutes := make([]int, 0, len(s.FireAt))
+	for _, t := range s.FireAt {
+		minutes = append(minutes, t.Hour()*60+t.Minute())
+	}
+	sort.Ints(minutes)
+	return minutes
+}
+
+func isCronSchedule(fields []string) bool {
+	if len(fields) != 5 {
+		return false
+	}
+	for _, f := range fields[:2] {
+		if strings.Contains(f, ":") {
+			return false
+		}
+	}
+	return true
+}
+
+func parseTextSchedule(fields []string) ([]Weekday, []int, error) {
+	days, err := parseScheduleDays(fields[0])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var step time.Duration
+	switch {
+	case len(fields) == 2:
+	case len(fields) == 4 && strings.EqualFold(fields[2], "every"):
+		if step, err = time.ParseDuration(fields[3]); err != nil {
+			return nil, nil, err
+		}
+		if step <= 0 || step%time.Minute != 0 {
+			return nil, nil, fmt.Errorf("step %s should be a positive number of minutes", fields[3])
+		}
+	default:
+		return nil, nil, errors.New("expected \"<days> <times> [every <step>]\"")
+	}
+
+	var minutes []int
+	for _, item := range strings.Split(fields[1], ",") {
+		bounds := strings.Split(item, "-")
+		switch len(bounds) {
+		case 1:
+			m, err := parseScheduleMinute(bounds[0])
+			if err != nil {
+				return nil, nil, err
+			}
+			minutes = append(minutes, m)
+		case 2:
+			if step == 0 {
+				return nil, nil, fmt.Errorf("time range %s requires every <step>", item)
+			}
+			from, err := parseScheduleMinute(bounds[0])
+			if err != nil {
+				return nil, nil, err
+			}
+			to, err := parseScheduleMinute(bounds[1])
+			if err != nil {
+				return nil, nil, err
+			}
+			if to < from {
+				return nil, nil, fmt.Errorf("time range %s ends before it starts", item)
+			}
+			for m := from; m <= to; m += int(step / time.Minute) {
+				minutes = append(minutes, m)
+			}
+		default:
+			return nil, nil, fmt.Errorf("invalid time %s", item)
+		}
+	}
+	return days, uniqueInts(minutes), nil
+}
+
+func parseScheduleDays(s string) ([]Weekday, error) {
+	switch strings.ToLower(s) {
+	case "*", "daily":
+		return []Weekday{Monday, Tuesday, Wednesday, Thursday, Friday, Saturday, Sunday}, nil
+	case "weekdays":
+		return []Weekday{Monday, Tuesday, Wednesday, Thursday, Friday}, nil
+	case "weekends":
+		return []Weekday{Saturday, Sunday}, nil
+	}
+
+	var days []Weekday
+	for _, item := range strings.Split(s, ",") {
+		bounds := strings.Split(item, "-")
+		if len(bounds) > 2 {
+			return nil, fmt.Errorf("invalid weekday range %s", item)
+		}
+		from, ok := weekdayAliases[strings.ToLower(bounds[0])]
+		if !ok {
+			return nil, fmt.Errorf("unknown weekday %s", bounds[0])
+		}
+		to := from
+		if len(bounds) == 2 {
+			if to, ok = weekdayAliases[strings.ToLower(bounds[1])]; !ok {
+				return nil, fmt.Errorf("unknown weekday %s", bounds[1])
+			}
+		}
+		// Диапазон может переходить через воскресенье: Fri-Mon.
+		for d := from; ; d = (d + 1) % 7 {
+			days = append(days, d)
+			if d == to {
+				break
+			}
+		}
+	}
+	return days, nil
+}
+
+func parseScheduleMinute(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %s, should be in format hh:mm", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+func parseCronSchedule(fields []string) ([]Weekday, []int, error) {
+	if fields[2] != "*" || fields[3] != "*" {
+		return nil, nil, errors.New("day of month and month are not supported, use *")
+	}
+	mins, err := parseCronField(fields[0], 0, 59, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("minutes: %s", err)
+	}
+	hours, err := parseCronField(fields[1], 0, 23, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("hours: %s", err)
+	}
+	dows, err := parseCronField(fields[4], 0, 7, cronWeekdayNumber)
+	if err != nil {
+		return nil, nil, fmt.Errorf("weekdays: %s", err)
+	}
+
+	var days []Weekday
+	for _, d := range dows {
+		// В crontab 0 и 7 - воскресенье, 1 - понедельник.
+		days = append(days, Weekday((d+6)%7))
+	}
+	var minutes []int
+	for _, h := range hours {
+		for _, m := range mins {
+			minutes = append(minutes, h*60+m)
+		}
+	}
+	return days, uniqueInts(minutes), nil
+}
+
+// cronWeekdayNumber возвращает номер дня недели по названию. Воскресенье в конце
+// диапазона (end) - 7, чтобы диапазоны вида FRI-SUN не заканчивались раньше начала.
+func cronWeekdayNumber(s string, end bool) (int, bool) {
+	d, ok := weekdayAliases[strings.ToLower(s)]
+	if !ok {
+		return 0, false
+	}
+	if d == Sunday && end {
+		return 7, true
+	}
+	return (int(d) + 1) % 7, true
+}
+
+// parseCronField разбирает поле crontab: *, списки, диапазоны и шаг.
+// names позволяет задавать значения названиями, end - значение является концом диапазона.
+func parseCronField(s string, min, max int, names func(s string, end bool) (int, bool)) ([]int, error) {
+	value := func(v string, end bool) (int, error) {
+		if names != nil {
+			if n, ok := names(v, end); ok {
+				return n, nil
+			}
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil || n < min || n > max {
+			return 0, fmt.Errorf("value %s is out of range %d-%d", v, min, max)
+		}
+		return n, nil
+	}
+
+	var out []int
+	for _, item := range strings.Split(s, ",") {
+		step := 1
+		if i := strings.Index(item, "/"); i >= 0 {
+			n, err := strconv.Atoi(item[i+1:])
+			if err != nil || n <= 0 {
+				return nil, fmt.Errorf("invalid step in %s", item)
+			}
+			step, item = n, item[:i]
+		}
+
+		from, to := min, max
+		if item != "*" {
+			bounds := strings.Split(item, "-")
+			if len(bounds) > 2 {
+				return nil, fmt.Errorf("invalid range %s", item)
+			}
+			var err error
+			if from, err = value(bounds[0], false); err != nil {
+				return nil, err
+			}
+			to = from
+			if len(bounds) == 2 {
+				if to, err = value(bounds[1], true); err != nil {
+					return nil, err
+				}
+			} else if step > 1 {
+				// "5/15" - с 5 до конца диапазона с шагом 15.
+				to = max
+			}
+			if to < from {
+				return nil, fmt.Errorf("range %s ends before it starts", item)
+			}
+		}
+		for v := from; v <= to; v += step {
+			out = append(out, v)
+		}
+	}
+	return uniqueInts(out), nil
+}
+
+func uniqueInts(in []int) []int {
+	sort.Ints(in)
+	out := in[:0]
+	for i, v := range in {
+		if i == 0 || v != in[i-1] {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+func formatScheduleMinute(m int) string {
+	return fmt.Sprintf("%02d:%02d", m/60, m%60)
+}
+
+func formatScheduleDays(days []Weekday) string {
+	switch len(days) {
+	case 0:
+		return ""
+	case 7:
+		return "daily"
+	}
+
+	var parts []string
+	for i := 0; i < len(days); {
+		j := i
+		for j+1 < len(days) && days[j+1] == days[j]+1 {
+			j++
+		}
+		switch j - i {
+		case 0:
+			parts = append(parts, days[i].String())
+		case 1:
+			parts = append(parts, days[i].String(), days[j].String())
+		default:
+			parts = append(parts, days[i].String()+"-"+days[j].String())
+		}
+		i = j + 1
+	}
+	return strings.Join(parts, ",")
+}
+
+// formatScheduleTimes выводит время списком или диапазоном с шагом, если значений
+// больше двух и они следуют с равным интервалом.
+func formatScheduleTimes(minutes []int) string {
+	if len(minutes) > 2 {
+		step := minutes[1] - minutes[0]
+		regular := step > 0
+		for i := 2; i < len(minutes) && regular; i++ {
+			regular = minutes[i]-minutes[i-1] == step
+		}
+		if regular {
+			return fmt.Sprintf("%s-%s every %s", formatScheduleMinute(minutes[0]),
+				formatScheduleMinute(minutes[len(minutes)-1]), formatScheduleStep(step))
+		}
+	}
+
+	parts := make([]string, 0, len(minutes))
+	for _, m := range minutes {
+		parts = append(parts, formatScheduleMinute(m))
+	}
+	return strings.Join(parts, ",")
+}
+
+func formatScheduleStep(minutes int) string {
+	switch {
+	case minutes%60 == 0:
+		return fmt.Sprintf("%dh", minutes/60)
+	case minutes > 60:
+		return fmt.Sprintf("%dh%dm", minutes/60, minutes%60)
+	default:
+		return fmt.Sprintf("%dm", minutes)
+	}
+}
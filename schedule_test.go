@@ -0,0 +1,225 @@
+package movizor
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseSchedule(t *testing.T) {
+	tests := []struct {
+		name     string
+		expr     string
+		wantDays []Weekday
+		wantST   []string
+		wantErr  bool
+	}{
+		{
+			name:     "range with step",
+			expr:     "Mon-Fri 08:00-10:00 every 30m",
+			wantDays: []Weekday{Monday, Tuesday, Wednesday, Thursday, Friday},
+			wantST:   []string{"08:00", "08:30", "09:00", "09:30", "10:00"},
+		},
+		{
+			name:     "list",
+			expr:     "Sat,Sun 18:00,10:00",
+			wantDays: []Weekday{Saturday, Sunday},
+			wantST:   []string{"10:00", "18:00"},
+		},
+		{
+			name:     "wrapping days in russian",
+			expr:     "Пт-Пн 09:00",
+			wantDays: []Weekday{Monday, Friday, Saturday, Sunday},
+			wantST:   []string{"09:00"},
+		},
+		{
+			name:     "daily",
+			expr:     "daily 09:00",
+			wantDays: []Weekday{Monday, Tuesday, Wednesday, Thursday, Friday, Saturday, Sunday},
+			wantST:   []string{"09:00"},
+		},
+		{
+			name:     "cron",
+			expr:     "*/30 8-9 * * 1-5",
+			wantDays: []Weekday{Monday, Tuesday, Wednesday, Thursday, Friday},
+			wantST:   []string{"08:00", "08:30", "09:00", "09:30"},
+		},
+		{
+			name:     "cron sunday and names",
+			expr:     "0 9,18 * * sat,0",
+			wantDays: []Weekday{Saturday, Sunday},
+			wantST:   []string{"09:00", "18:00"},
+		},
+		{
+			name:     "cron range to sunday",
+			expr:     "0 9 * * FRI-SUN",
+			wantDays: []Weekday{Friday, Saturday, Sunday},
+			wantST:   []string{"09:00"},
+		},
+		{
+			name:     "cron range to 7",
+			expr:     "0 9 * * 5-7",
+			wantDays: []Weekday{Friday, Saturday, Sunday},
+			wantST:   []string{"09:00"},
+		},
+		{
+			name:    "too frequent",
+			expr:    "daily 08:00-09:00 every 10m",
+			wantErr: true,
+		},
+		{
+			name:    "too close over midnight",
+			expr:    "daily 23:55,00:05",
+			wantErr: true,
+		},
+		{
+			name:    "range without step",
+			expr:    "Mon 08:00-09:00",
+			wantErr: true,
+		},
+		{
+			name:    "unknown weekday",
+			expr:    "Mun 08:00",
+			wantErr: true,
+		},
+		{
+			name:    "cron day of month",
+			expr:    "0 9 1 * *",
+			wantErr: true,
+		},
+		{
+			name:    "bad time",
+			expr:    "Mon 25:00",
+			wantErr: true,
+		},
+		{
+			name:    "empty",
+			expr:    "",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := ParseSchedule(tt.expr, nil)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseSchedule() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got := s.Weekdays(); !reflect.DeepEqual(got, tt.wantDays) {
+				t.Errorf("ParseSchedule() days = %v, want %v", got, tt.wantDays)
+			}
+			v := url.Values{}
+			if err := s.addValuesTo(&v, nil); err != nil {
+				t.Fatalf("SchedulingOptions.addValuesTo() error = %v", err)
+			}
+			if got := v["st[]"]; !reflect.DeepEqual(got, tt.wantST) {
+				t.Errorf("ParseSchedule() st[] = %v, want %v", got, tt.wantST)
+			}
+		})
+	}
+}
+
+func TestSchedulingOptions_String(t *testing.T) {
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{expr: "Mon-Fri 08:00-20:00 every 30m", want: "Mon-Fri 08:00-20:00 every 30m"},
+		{expr: "weekends 10:00,14:00", want: "Sat,Sun 10:00,14:00"},
+		{expr: "0 9 * * *", want: "daily 09:00"},
+		{expr: "Mon,Wed-Fri 06:00-12:00 every 90m", want: "Mon,Wed-Fri 06:00-12:00 every 1h30m"},
+		{expr: "Tue 08:00,09:00,11:00", want: "Tue 08:00,09:00,11:00"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			s := MustParseSchedule(tt.expr, nil)
+			got := s.String()
+			if got != tt.want {
+				t.Errorf("SchedulingOptions.String() = %q, want %q", got, tt.want)
+			}
+			back, err := ParseSchedule(got, nil)
+			if err != nil {
+				t.Fatalf("ParseSchedule(String()) error = %v", err)
+			}
+			if back.weekdays != s.weekdays || !reflect.DeepEqual(back.minutes(), s.minutes()) {
+				t.Errorf("round trip = %s, want %s", back, s)
+			}
+		})
+	}
+
+	noDays := &SchedulingOptions{FireAt: []time.Time{time.Date(0, 1, 1, 8, 0, 0, 0, MoscowLocation)}}
+	if got := noDays.String(); got != "08:00" {
+		t.Errorf("SchedulingOptions.String() without weekdays = %q, want %q", got, "08:00")
+	}
+}
+
+func TestSchedulingOptions_Validate(t *testing.T) {
+	s := &SchedulingOptions{FireAt: []time.Time{time.Date(0, 1, 1, 8, 0, 0, 0, MoscowLocation)}}
+	if err := s.Validate(); err == nil {
+		t.Error("SchedulingOptions.Validate() without weekdays should fail")
+	}
+	s.WeekdayOn(Monday)
+	if err := s.Validate(); err != nil {
+		t.Errorf("SchedulingOptions.Validate() error = %v", err)
+	}
+	s.FireAt = append(s.FireAt, s.FireAt[0])
+	if err := s.Validate(); err == nil {
+		t.Error("SchedulingOptions.Validate() with duplicate times should fail")
+	}
+}
+
+func TestSchedulingOptions_In(t *testing.T) {
+	tests := []struct {
+		expr    string
+		from    *time.Location
+		to      *time.Location
+		want    string
+		wantErr bool
+	}{
+		{expr: "Mon-Fri 08:00", from: time.UTC, to: MoscowLocation, want: "Mon-Fri 11:00"},
+		{expr: "Mon 22:00", from: time.UTC, to: MoscowLocation, want: "Tue 01:00"},
+		{expr: "Sat,Sun 22:00", from: time.UTC, to: MoscowLocation, want: "Mon,Sun 01:00"},
+		{expr: "Mon 01:00", from: MoscowLocation, to: time.UTC, want: "Sun 22:00"},
+		{expr: "daily 20:00,22:00", from: time.UTC, to: MoscowLocation, want: "daily 01:00,23:00"},
+		{expr: "Mon 20:00,22:00", from: time.UTC, to: MoscowLocation, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			s, err := MustParseSchedule(tt.expr, tt.from).In(tt.to)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("SchedulingOptions.In() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && s.String() != tt.want {
+				t.Errorf("SchedulingOptions.In() = %s, want %s", s, tt.want)
+			}
+		})
+	}
+
+	// В сервис передаются дни недели в его часовом поясе.
+	v := url.Values{}
+	if err := MustParseSchedule("Mon 22:00", time.UTC).addValuesTo(&v, nil); err != nil {
+		t.Fatalf("SchedulingOptions.addValuesTo() error = %v", err)
+	}
+	if want := (url.Values{"sw2": {"1"}, "st[]": {"01:00"}}); !reflect.DeepEqual(v, want) {
+		t.Errorf("SchedulingOptions.addValuesTo() = %v, want %v", v, want)
+	}
+}
+
+func TestSchedulingOptions_addValuesTo_noIntervalLimit(t *testing.T) {
+	// Ограничение MinScheduleInterval действует только для ParseSchedule.
+	s := &SchedulingOptions{FireAt: []time.Time{
+		time.Date(0, 1, 1, 8, 0, 0, 0, MoscowLocation),
+		time.Date(0, 1, 1, 8, 5, 0, 0, MoscowLocation),
+	}}
+	s.WeekdayOn(Monday)
+	v := url.Values{}
+	if err := s.addValuesTo(&v, nil); err != nil {
+		t.Errorf("SchedulingOptions.addValuesTo() error = %v", err)
+	}
+	if _, err := ParseSchedule("Mon 08:00,08:05", nil); err == nil {
+		t.Error("ParseSchedule() with times closer than MinScheduleInterval should fail")
+	}
+}
@@ -0,0 +1,80 @@
+package movizor
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Tariff возвращает тариф tt оператора op. Тариф приводится методом TariffType.Normalize.
+func (b Balance) Tariff(op Operator, tt TariffType) (Tariff, error) {
+	tt = tt.Normalize()
+	tariffs, ok := b.OperatorTariffs[op]
+	if !ok {
+		return Tariff{}, fmt.Errorf("no tariffs for operator %q", op)
+	}
+	t, ok := tariffs[tt]
+	if !ok {
+		return Tariff{}, fmt.Errorf("tariff %q is not available for operator %q", tt, op)
+	}
+	return t, nil
+}
+
+// ValidateTariff проверяет, что тариф tt известен и подключен для оператора op,
+// то есть может быть указан в ObjectOptions.Tariff для объекта этого оператора.
+// Тариф приводится методом TariffType.Normalize.
+func (b Balance) ValidateTariff(op Operator, tt TariffType) error {
+	tt = tt.Normalize()
+	if !tt.IsKnown() {
+		return fmt.Errorf("unknown tariff %q", tt)
+	}
+	_, err := b.Tariff(op, tt)
+	return err
+}
+
+// OperatorPrice - стоимость тарифа у оператора.
+type OperatorPrice struct {
+	Operator Operator
+	Tariff   Tariff
+	Daily    float64 // Суточные расходы на объект (см. ForecastObjectSpend)
+}
+
+// ComparePrices сравнивает стоимость тарифа tt у операторов, у которых он подключен,
+// по суточным расходам на объект с допущениями opts. Результат по возрастанию Daily.
+func (b Balance) ComparePrices(tt TariffType, opts ForecastOptions) []OperatorPrice {
+	var prices []OperatorPrice
+	for op := range b.OperatorTariffs {
+		t, err := b.Tariff(op, tt)
+		if err != nil {
+			continue
+		}
+		os, err := ForecastObjectSpend(b, op, tt, nil, opts)
+		if err != nil {
+			continue
+		}
+		prices = append(prices, OperatorPrice{Operator: op, Tariff: t, Daily: os.Daily})
+	}
+	sort.Slice(prices, func(i, j int) bool {
+		if prices[i].Daily != prices[j].Daily {
+			return prices[i].Daily < prices[j].Daily
+		}
+		return prices[i].Operator < prices[j].Operator
+	})
+	return prices
+}
+
+// ValidateTariff получает оператора объекта и тарифы и проверяет, что тариф tt
+// можно указать объекту в AddObject или EditObject. Возвращает тариф с ценами.
+func (api *API) ValidateTariff(o Object, tt TariffType) (Tariff, error) {
+	op, err := api.GetOperatorInfo(o)
+	if err != nil {
+		return Tariff{}, err
+	}
+	b, err := api.GetBalance()
+	if err != nil {
+		return Tariff{}, err
+	}
+	if err := b.ValidateTariff(op.Operator, tt); err != nil {
+		return Tariff{}, err
+	}
+	return b.Tariff(op.Operator, tt)
+}
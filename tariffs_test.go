@@ -0,0 +1,147 @@
+package movizor
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTariffType_Interval(t *testing.T) {
+	tests := []struct {
+		tariff TariffType
+		want   time.Duration
+	}{
+		{TariffManual, 0},
+		{TariffOnline, 0},
+		{TariffOneMonth, 0},
+		{TariffEvery15, 15 * time.Minute},
+		{TariffEvery30, 30 * time.Minute},
+		{TariffEvery60, time.Hour},
+		{TariffEvery180, 3 * time.Hour},
+		{"manual", 0},
+		{"42", 0},
+	}
+	for _, tt := range tests {
+		if got := tt.tariff.Interval(); got != tt.want {
+			t.Errorf("TariffType(%q).Interval() = %s, want %s", tt.tariff, got, tt.want)
+		}
+	}
+}
+
+func TestTariffType_Normalize(t *testing.T) {
+	tests := []struct {
+		tariff TariffType
+		want   TariffType
+	}{
+		{"manual", TariffManual},
+		{TariffManual, TariffManual},
+		{TariffEvery15, TariffEvery15},
+		{"", ""},
+		{"42", "42"},
+	}
+	for _, tt := range tests {
+		if got := tt.tariff.Normalize(); got != tt.want {
+			t.Errorf("TariffType(%q).Normalize() = %q, want %q", tt.tariff, got, tt.want)
+		}
+	}
+}
+
+func TestBalance_Tariff(t *testing.T) {
+	b := loadTestBalance(t)
+	tests := []struct {
+		name    string
+		op      Operator
+		tariff  TariffType
+		want    Tariff
+		wantErr bool
+	}{
+		{"manual", OperatorMTS, TariffManual, Tariff{AbonentPayment: 4, RequestCost: 3, TariffTitle: "Вручную"}, false},
+		{"manual alias", OperatorMTS, "manual", Tariff{AbonentPayment: 4, RequestCost: 3, TariffTitle: "Вручную"}, false},
+		{"periodic", OperatorMegafon, TariffEvery60, Tariff{AbonentPayment: 25, TariffTitle: "Каждые 60 мин"}, false},
+		{"not available", OperatorMTS, TariffOnline, Tariff{}, true},
+		{"unknown operator", Operator("yota"), TariffManual, Tariff{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := b.Tariff(tt.op, tt.tariff)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Tariff() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Tariff() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBalance_ValidateTariff(t *testing.T) {
+	b := loadTestBalance(t)
+	tests := []struct {
+		op      Operator
+		tariff  TariffType
+		wantErr bool
+	}{
+		{OperatorMegafon, TariffOnline, false},
+		{OperatorMTS, TariffEvery180, false},
+		{OperatorMTS, TariffOnline, true},
+		{OperatorMegafon, TariffEvery180, true},
+		// "manual" возвращает object_get, он приводится к TariffManual.
+		{OperatorMTS, "manual", false},
+		{OperatorMTS, "", true},
+		{Operator("yota"), TariffManual, true},
+	}
+	for _, tt := range tests {
+		if err := b.ValidateTariff(tt.op, tt.tariff); (err != nil) != tt.wantErr {
+			t.Errorf("ValidateTariff(%s, %q) error = %v, wantErr %v", tt.op, tt.tariff, err, tt.wantErr)
+		}
+	}
+}
+
+func TestBalance_ComparePrices(t *testing.T) {
+	b := loadTestBalance(t)
+	tests := []struct {
+		name   string
+		tariff TariffType
+		opts   ForecastOptions
+		want   []Operator
+		daily  []float64
+	}{
+		{"periodic", TariffEvery60, ForecastOptions{},
+			[]Operator{OperatorTele2, OperatorBeeline, OperatorMegafon, OperatorMTS}, []float64{13, 20, 25, 75}},
+		{"manual", TariffManual, ForecastOptions{ManualRequestsPerDay: 10},
+			[]Operator{OperatorTele2, OperatorMTS, OperatorBeeline, OperatorMegafon}, []float64{15.5, 34, 35, 38}},
+		// Онлайн у МТС не подключен, при равной цене - по названию оператора.
+		{"online", TariffOnline, ForecastOptions{},
+			[]Operator{OperatorTele2, OperatorBeeline, OperatorMegafon}, []float64{22, 35, 35}},
+		{"package", TariffOneMonth, ForecastOptions{PackageDays: 45},
+			[]Operator{OperatorBeeline, OperatorMegafon, OperatorMTS, OperatorTele2}, []float64{2, 2, 2, 2}},
+		{"unknown", "42", ForecastOptions{}, nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := b.ComparePrices(tt.tariff, tt.opts)
+			if len(got) != len(tt.want) {
+				t.Fatalf("ComparePrices() = %+v", got)
+			}
+			for i, p := range got {
+				if p.Operator != tt.want[i] || p.Daily != tt.daily[i] {
+					t.Errorf("ComparePrices()[%d] = %s %g, want %s %g", i, p.Operator, p.Daily, tt.want[i], tt.daily[i])
+				}
+			}
+		})
+	}
+}
+
+func TestAPI_ValidateTariff(t *testing.T) {
+	api := poolTestAPI(t, "main", map[string]string{
+		"balance":      "balance.json",
+		"get_operator": "get_operator.json",
+	}, nil)
+
+	tr, err := api.ValidateTariff("79630005272", TariffEvery180)
+	if err != nil || tr.AbonentPayment != 36 {
+		t.Errorf("ValidateTariff() = %+v, %v", tr, err)
+	}
+	if _, err := api.ValidateTariff("79630005272", TariffOnline); err == nil {
+		t.Error("ValidateTariff() for tariff not available to operator should fail")
+	}
+}
@@ -0,0 +1,227 @@
+package movizor
+
+import (
+	"math"
+	"sort"
+)
+
+const (
+	// DefaultMaxSpeed - максимально допустимая скорость между точками по умолчанию (км/ч).
+	DefaultMaxSpeed = 150.0
+	// DefaultDeviation - радиус погрешности (м) для точек, у которых он не указан.
+	DefaultDeviation = 300.0
+	// DefaultProcessNoise - ожидаемая скорость изменения местоположения (м/с) для сглаживания.
+	DefaultProcessNoise = 15.0
+)
+
+// CleanReason представляет собой причину удаления точки из трека.
+type CleanReason string
+
+const (
+	DuplicateCleanReason CleanReason = "duplicate" // Точка с тем же временем, что и предыдущая
+	DeviationCleanReason CleanReason = "deviation" // Радиус погрешности больше допустимого
+	SpeedCleanReason     CleanReason = "speed"     // Скорость перемещения к точке больше допустимой
+)
+
+// CleanOptions предоставляет настройки очистки трека.
+type CleanOptions struct {
+	// MaxSpeed - максимально допустимая скорость между точками (км/ч). 0 - DefaultMaxSpeed.
+	// При расчете расстояние между точками уменьшается на сумму их радиусов погрешности,
+	// поэтому точки с большим радиусом допускают большие скачки.
+	MaxSpeed float64
+	// MaxDeviation - точки с радиусом погрешности больше этого значения (м) удаляются.
+	// 0 - без ограничения.
+	MaxDeviation float64
+	// Smooth включает сглаживание координат фильтром Калмана с обратным проходом
+	// (сглаживатель Рауха-Тунга-Стрибела). Вес точки обратно пропорционален квадрату
+	// радиуса погрешности.
+	Smooth bool
+	// ProcessNoise - ожидаемая скорость изменения местоположения (м/с). 0 - DefaultProcessNoise.
+	ProcessNoise float64
+	// DefaultDeviation - радиус погрешности (м) для точек без радиуса. 0 - DefaultDeviation.
+	DefaultDeviation float64
+}
+
+func (o CleanOptions) withDefaults() CleanOptions {
+	if o.MaxSpeed <= 0 {
+		o.MaxSpeed = DefaultMaxSpeed
+	}
+	if o.ProcessNoise <= 0 {
+		o.ProcessNoise = DefaultProcessNoise
+	}
+	if o.DefaultDeviation <= 0 {
+		o.DefaultDeviation = DefaultDeviation
+	}
+	return o
+}
+
+// RemovedPosition содержит удаленную из трека точку и причину удаления.
+type RemovedPosition struct {
+	Position Position    // Удаленная точка
+	Reason   CleanReason // Причина удаления
+	Speed    float64     // Скорость перемещения к точке (км/ч) для SpeedCleanReason
+}
+
+// CleanReport содержит отчет об очистке трека.
+type CleanReport struct {
+	Input    int               // Количество точек до очистки
+	Output   int               // Количество точек после очистки
+	Removed  []RemovedPosition // Удаленные точки в хронологическом порядке
+	Smoothed bool              // Координаты сглажены
+	MaxShift float64           // Максимальное смещение точки при сглаживании (м)
+}
+
+// CleanTrack очищает трек от дубликатов, точек с недопустимым радиусом погрешности и
+// невозможных скачков (скорость выше MaxSpeed), а также опционально сглаживает координаты.
+// Возвращает очищенный трек в хронологическом порядке и отчет об удаленных точках.
+func CleanTrack(ps Positions, opts CleanOptions) (Positions, CleanReport) {
+	opts = opts.withDefaults()
+	ps = ps.Chronological()
+	report := CleanReport{Input: len(ps)}
+
+	remove := func(p Position, reason CleanReason, speed float64) {
+		report.Removed = append(report.Removed, RemovedPosition{Position: p, Reason: reason, Speed: speed})
+	}
+
+	candidates := make(Positions, 0, len(ps))
+	for _, p := range ps {
+		if opts.MaxDeviation > 0 && opts.deviation(p) > opts.MaxDeviation {
+			remove(p, DeviationCleanReason, 0)
+			continue
+		}
+		if n := len(candidates); n > 0 && candidates[n-1].Timestamp.Time().Equal(p.Timestamp.Time()) {
+			// Из двух точек с одним временем оставляется более точная.
+			if opts.deviation(p) < opts.deviation(candidates[n-1]) {
+				remove(candidates[n-1], DuplicateCleanReason, 0)
+				candidates[n-1] = p
+			} else {
+				remove(p, DuplicateCleanReason, 0)
+			}
+			continue
+		}
+		candidates = append(candidates, p)
+	}
+
+	cleaned := make(Positions, 0, len(candidates))
+	for i, p := range candidates {
+		var next, afterNext *Position
+		if i+1 < len(candidates) {
+			next = &candidates[i+1]
+		}
+		if i+2 < len(candidates) {
+			afterNext = &candidates[i+2]
+		}
+
+		if len(cleaned) == 0 {
+			// Первая точка сама может быть выбросом: проверяется по двум следующим.
+			if next != nil && afterNext != nil &&
+				opts.speed(p, *next) > opts.MaxSpeed && opts.speed(*next, *afterNext) <= opts.MaxSpeed {
+				remove(p, SpeedCleanReason, opts.speed(p, *next))
+				continue
+			}
+			cleaned = append(cleaned, p)
+			continue
+		}
+
+		prev := cleaned[len(cleaned)-1]
+		speed := opts.speed(prev, p)
+		// Скачок от предыдущей точки допускается, только если следующая точка подтверждает
+		// новое местоположение и не согласуется с предыдущей (т.е. выбросом была предыдущая точка).
+		if speed > opts.MaxSpeed &&
+			(next == nil || opts.speed(prev, *next) <= opts.MaxSpeed || opts.speed(p, *next) > opts.MaxSpeed) {
+			remove(p, SpeedCleanReason, speed)
+			continue
+		}
+		cleaned = append(cleaned, p)
+	}
+
+	if opts.Smooth && len(cleaned) > 1 {
+		report.MaxShift = opts.smooth(cleaned)
+		report.Smoothed = true
+	}
+
+	sort.SliceStable(report.Removed, func(i, j int) bool {
+		return report.Removed[i].Position.Timestamp.Time().Before(report.Removed[j].Position.Timestamp.Time())
+	})
+	report.Output = len(cleaned)
+	return cleaned, report
+}
+
+func (o CleanOptions) deviation(p Position) float64 {
+	if p.Deviation == nil || *p.Deviation <= 0 {
+		return o.DefaultDeviation
+	}
+	return float64(*p.Deviation)
+}
+
+// speed возвращает скорость (км/ч) перемещения между точками с учетом радиусов погрешности.
+func (o CleanOptions) speed(a, b Position) float64 {
+	dt := b.Timestamp.Time().Sub(a.Timestamp.Time())
+	d := Distance(a.Coordinates, b.Coordinates)
+	if a.Deviation != nil {
+		d -= float64(*a.Deviation)
+	}
+	if b.Deviation != nil {
+		d -= float64(*b.Deviation)
+	}
+	if d <= 0 {
+		return 0
+	}
+	if dt <= 0 {
+		return math.Inf(1)
+	}
+	return d / 1000 / dt.Hours()
+}
+
+// smooth сглаживает координаты точек на месте и возвращает максимальное смещение (м).
+// Используется модель случайного блуждания отдельно по осям локальной проекции.
+func (o CleanOptions) smooth(ps Positions) float64 {
+	proj := newLocalProjection(ps[0].Coordinates)
+	n := len(ps)
+	z := make([]planePoint, n)
+	r := make([]float64, n)
+	for i, p := range ps {
+		z[i] = proj.project(p.Coordinates)
+		dev := o.deviation(p)
+		r[i] = dev * dev
+	}
+
+	// Прямой проход фильтра Калмана.
+	xf := make([]planePoint, n)
+	pf := make([]float64, n)
+	pp := make([]float64, n) // Предсказанная дисперсия
+	xf[0], pf[0], pp[0] = z[0], r[0], r[0]
+	for i := 1; i < n; i++ {
+		dt := ps[i].Timestamp.Time().Sub(ps[i-1].Timestamp.Time()).Seconds()
+		q := o.ProcessNoise * dt
+		pp[i] = pf[i-1] + q*q
+		k := pp[i] / (pp[i] + r[i])
+		xf[i] = planePoint{
+			x: xf[i-1].x + k*(z[i].x-xf[i-1].x),
+			y: xf[i-1].y + k*(z[i].y-xf[i-1].y),
+		}
+		pf[i] = (1 - k) * pp[i]
+	}
+
+	// Обратный проход сглаживателя.
+	xs := make([]planePoint, n)
+	xs[n-1] = xf[n-1]
+	for i := n - 2; i >= 0; i-- {
+		c := 1.0
+		if pp[i+1] > 0 {
+			c = pf[i] / pp[i+1]
+		}
+		xs[i] = planePoint{
+			x: xf[i].x + c*(xs[i+1].x-xf[i].x),
+			y: xf[i].y + c*(xs[i+1].y-xf[i].y),
+		}
+	}
+
+	var maxShift float64
+	for i := range ps {
+		c := proj.unproject(xs[i])
+		maxShift = math.Max(maxShift, Distance(ps[i].Coordinates, c))
+		ps[i].Coordinates = c
+	}
+	return maxShift
+}
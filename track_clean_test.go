@@ -0,0 +1,88 @@
+package movizor
+
+import (
+	"math"
+	"testing"
+)
+
+func TestCleanTrack(t *testing.T) {
+	// Движение на север со скоростью ~40 км/ч, точки раз в 5 минут.
+	var ps Positions
+	for i := 0; i < 8; i++ {
+		ps = append(Positions{testPosition(55.0+float64(i)*0.03, 37.0, int64(1000+i*300), 200)}, ps...)
+	}
+	// Телепорт на 50 км в сторону.
+	ps = append(ps, testPosition(55.09, 37.8, 1000+3*300+150, 200))
+	// Дубликат по времени с худшей точностью.
+	ps = append(ps, testPosition(55.12, 37.0, 1000+4*300, 900))
+	// Точка с огромным радиусом.
+	ps = append(ps, testPosition(55.15, 37.0, 1000+5*300+10, 5000))
+
+	cleaned, report := CleanTrack(ps, CleanOptions{MaxDeviation: 3000})
+	if report.Input != 11 || report.Output != 8 || len(cleaned) != 8 {
+		t.Fatalf("CleanTrack() input = %d, output = %d, len = %d", report.Input, report.Output, len(cleaned))
+	}
+
+	reasons := map[CleanReason]int{}
+	for _, r := range report.Removed {
+		reasons[r.Reason]++
+	}
+	want := map[CleanReason]int{SpeedCleanReason: 1, DuplicateCleanReason: 1, DeviationCleanReason: 1}
+	for k, v := range want {
+		if reasons[k] != v {
+			t.Errorf("CleanTrack() removed %s = %d, want %d", k, reasons[k], v)
+		}
+	}
+	for _, r := range report.Removed {
+		if r.Reason == SpeedCleanReason && r.Position.Lon != 37.8 {
+			t.Errorf("CleanTrack() removed wrong point by speed: %+v", r.Position)
+		}
+	}
+	for i := 1; i < len(cleaned); i++ {
+		if cleaned[i].Timestamp.Time().Before(cleaned[i-1].Timestamp.Time()) {
+			t.Fatal("CleanTrack() result is not chronological")
+		}
+	}
+	if report.Smoothed {
+		t.Error("CleanTrack() should not smooth without Smooth option")
+	}
+}
+
+func TestCleanTrack_FirstOutlier(t *testing.T) {
+	ps := Positions{
+		testPosition(56.0, 37.0, 1000, 100),
+		testPosition(55.0, 37.0, 1300, 100),
+		testPosition(55.01, 37.0, 1600, 100),
+		testPosition(55.02, 37.0, 1900, 100),
+	}
+	cleaned, report := CleanTrack(ps, CleanOptions{})
+	if len(cleaned) != 3 || len(report.Removed) != 1 || report.Removed[0].Position.Lat != 56.0 {
+		t.Errorf("CleanTrack() = %v, removed %+v", cleaned, report.Removed)
+	}
+}
+
+func TestCleanTrack_Smooth(t *testing.T) {
+	// Стоящий объект с шумом, одна точка с большой погрешностью смещена сильнее.
+	ps := Positions{
+		testPosition(55.0000, 37.0, 1000, 50),
+		testPosition(55.0010, 37.0, 1300, 50),
+		testPosition(54.9990, 37.0, 1600, 50),
+		testPosition(55.0100, 37.0, 1900, 2000),
+		testPosition(55.0005, 37.0, 2200, 50),
+		testPosition(54.9995, 37.0, 2500, 50),
+	}
+	cleaned, report := CleanTrack(ps, CleanOptions{Smooth: true, ProcessNoise: 0.1})
+	if !report.Smoothed || len(cleaned) != len(ps) {
+		t.Fatalf("CleanTrack() smoothed = %v, len = %d", report.Smoothed, len(cleaned))
+	}
+	// Точка с радиусом 2 км должна притянуться к соседним точным точкам.
+	if d := Distance(cleaned[3].Coordinates, Coordinates{Lat: 55.0, Lon: 37.0}); d > 200 {
+		t.Errorf("CleanTrack() noisy point is %v m away from true position", d)
+	}
+	if math.Abs(report.MaxShift-1000) > 150 {
+		t.Errorf("CleanTrack() MaxShift = %v, want about 1 km", report.MaxShift)
+	}
+	if ps[3].Lat != 55.01 {
+		t.Error("CleanTrack() should not modify input positions")
+	}
+}
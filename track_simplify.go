@@ -0,0 +1,213 @@
+package movizor
+
+import (
+	"container/heap"
+	"math"
+	"time"
+)
+
+// SimplifyOptions предоставляет настройки упрощения трека для отображения на карте.
+type SimplifyOptions struct {
+	Tolerance float64     // Допуск упрощения (м)
+	Stops     TripOptions // Настройки определения стоянок. Первая точка каждой стоянки сохраняется
+}
+
+// SimplifyDouglasPeucker упрощает трек алгоритмом Дугласа-Пекера: удаляются точки,
+// отстоящие от упрощенной линии не более чем на Tolerance метров.
+// Первая и последняя точки трека, а также первые точки стоянок сохраняются.
+// Возвращает трек в хронологическом порядке.
+func SimplifyDouglasPeucker(ps Positions, opts SimplifyOptions) Positions {
+	ps = ps.Chronological()
+	keep := simplifyAnchors(ps, opts.Stops)
+	if opts.Tolerance <= 0 {
+		return ps
+	}
+
+	from := 0
+	for i := 1; i < len(ps); i++ {
+		if keep[i] {
+			douglasPeucker(ps, from, i, opts.Tolerance, keep)
+			from = i
+		}
+	}
+
+	return filterPositions(ps, keep)
+}
+
+func douglasPeucker(ps Positions, from, to int, tolerance float64, keep []bool) {
+	if to-from < 2 {
+		return
+	}
+
+	proj := newLocalProjection(ps[from].Coordinates)
+	a, b := proj.project(ps[from].Coordinates), proj.project(ps[to].Coordinates)
+	maxDist, idx := 0.0, -1
+	for i := from + 1; i < to; i++ {
+		if d := segmentDistance(proj.project(ps[i].Coordinates), a, b); d > maxDist {
+			maxDist, idx = d, i
+		}
+	}
+
+	if maxDist > tolerance {
+		keep[idx] = true
+		douglasPeucker(ps, from, idx, tolerance, keep)
+		douglasPeucker(ps, idx, to, tolerance, keep)
+	}
+}
+
+// SimplifyVisvalingam упрощает трек алгоритмом Висвалингам-Уайатта: последовательно удаляются
+// точки, образующие с соседями треугольник наименьшей площади, пока площадь меньше Tolerance².
+// Первая и последняя точки трека, а также первые точки стоянок сохраняются.
+// Возвращает трек в хронологическом порядке.
+func SimplifyVisvalingam(ps Positions, opts SimplifyOptions) Positions {
+	ps = ps.Chronological()
+	keep := simplifyAnchors(ps, opts.Stops)
+	if opts.Tolerance <= 0 || len(ps) < 3 {
+		return ps
+	}
+
+	n := len(ps)
+	prev := make([]int, n)
+	next := make([]int, n)
+	removed := make([]bool, n)
+	version := make([]int, n)
+	for i := range ps {
+		prev[i], next[i] = i-1, i+1
+	}
+
+	area := func(i int) float64 {
+		proj := newLocalProjection(ps[i].Coordinates)
+		a := proj.project(ps[prev[i]].Coordinates)
+		b := proj.project(ps[next[i]].Coordinates)
+		return math.Abs(a.x*b.y-b.x*a.y) / 2
+	}
+
+	h := &areaHeap{}
+	for i := 1; i < n-1; i++ {
+		if !keep[i] {
+			heap.Push(h, areaItem{idx: i, area: area(i)})
+		}
+	}
+
+	threshold := opts.Tolerance * opts.Tolerance
+	for h.Len() > 0 {
+		it := heap.Pop(h).(areaItem)
+		if removed[it.idx] || it.version != version[it.idx] {
+			continue
+		}
+		if it.area >= threshold {
+			break
+		}
+
+		removed[it.idx] = true
+		p, nx := prev[it.idx], next[it.idx]
+		next[p], prev[nx] = nx, p
+		for _, j := range []int{p, nx} {
+			if j > 0 && j < n-1 && !keep[j] && !removed[j] {
+				version[j]++
+				// Площадь соседа не может стать меньше площади удаленной точки,
+				// иначе порядок удаления нарушится.
+				heap.Push(h, areaItem{idx: j, area: math.Max(area(j), it.area), version: version[j]})
+			}
+		}
+	}
+
+	for i := range keep {
+		keep[i] = keep[i] || !removed[i]
+	}
+	return filterPositions(ps, keep)
+}
+
+type areaItem struct {
+	idx     int
+	area    float64
+	version int
+}
+
+type areaHeap []areaItem
+
+func (h areaHeap) Len() int            { return len(h) }
+func (h areaHeap) Less(i, j int) bool  { return h[i].area < h[j].area }
+func (h areaHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *areaHeap) Push(x interface{}) { *h = append(*h, x.(areaItem)) }
+func (h *areaHeap) Pop() interface{} {
+	old := *h
+	it := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return it
+}
+
+// DownsampleByTime прореживает трек для графиков: остается не более одной точки на каждый
+// интервал bucket (интервалы выравниваются по началу эпохи Unix). Из точек интервала выбирается
+// точка с наименьшим радиусом погрешности. Возвращает трек в хронологическом порядке.
+func DownsampleByTime(ps Positions, bucket time.Duration) Positions {
+	ps = ps.Chronological()
+	if bucket <= 0 {
+		return ps
+	}
+
+	var out Positions
+	var current int64
+	for _, p := range ps {
+		b := timeBucket(p.Timestamp.Time(), bucket)
+		if len(out) == 0 || b != current {
+			current = b
+			out = append(out, p)
+			continue
+		}
+		if positionDeviation(p) < positionDeviation(out[len(out)-1]) {
+			out[len(out)-1] = p
+		}
+	}
+	return out
+}
+
+// timeBucket возвращает номер интервала bucket от начала эпохи Unix, в который попадает t.
+// time.Truncate отсчитывает интервалы от нулевого времени и не подходит для интервалов,
+// на которые не делятся сутки.
+func timeBucket(t time.Time, bucket time.Duration) int64 {
+	ns := t.UnixNano()
+	b := ns / int64(bucket)
+	if ns < 0 && ns%int64(bucket) != 0 {
+		b--
+	}
+	return b
+}
+
+func positionDeviation(p Position) float64 {
+	if p.Deviation == nil {
+		return math.Inf(1)
+	}
+	return float64(*p.Deviation)
+}
+
+// simplifyAnchors отмечает точки, которые сохраняются при любом упрощении:
+// первую, последнюю и первые точки стоянок.
+func simplifyAnchors(ps Positions, stops TripOptions) []bool {
+	keep := make([]bool, len(ps))
+	if len(ps) == 0 {
+		return keep
+	}
+	keep[0], keep[len(ps)-1] = true, true
+
+	starts := make(map[int64]bool)
+	for _, s := range SegmentTrips(ps, stops).Stops {
+		starts[s.Start.UnixNano()] = true
+	}
+	for i, p := range ps {
+		if starts[p.Timestamp.Time().UnixNano()] {
+			keep[i] = true
+		}
+	}
+	return keep
+}
+
+func filterPositions(ps Positions, keep []bool) Positions {
+	out := make(Positions, 0, len(ps))
+	for i, p := range ps {
+		if keep[i] {
+			out = append(out, p)
+		}
+	}
+	return out
+}
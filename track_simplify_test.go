@@ -0,0 +1,112 @@
+package movizor
+
+import (
+	"testing"
+	"time"
+)
+
+// testZigzagTrack возвращает трек движения на север с небольшими отклонениями в сторону
+// и стоянкой в середине.
+func testZigzagTrack() Positions {
+	var ps Positions
+	ts := int64(1000)
+	for i := 0; i < 20; i++ {
+		lon := 37.0
+		if i%2 == 1 {
+			lon += 0.0003 // ~20 м в сторону
+		}
+		ps = append(ps, testPosition(55.0+float64(i)*0.01, lon, ts, 50))
+		ts += 60
+	}
+	// Стоянка 30 минут.
+	for i := 0; i < 7; i++ {
+		ps = append(ps, testPosition(55.19, 37.0, ts, 50))
+		ts += 300
+	}
+	for i := 1; i < 10; i++ {
+		ps = append(ps, testPosition(55.19, 37.0+float64(i)*0.01, ts, 50))
+		ts += 60
+	}
+	return ps
+}
+
+func TestSimplifyDouglasPeucker(t *testing.T) {
+	ps := testZigzagTrack()
+	stopStart := Time(SegmentTrips(ps, TripOptions{}).Stops[0].Start)
+
+	got := SimplifyDouglasPeucker(ps, SimplifyOptions{Tolerance: 50})
+	if len(got) >= len(ps)/4 {
+		t.Errorf("SimplifyDouglasPeucker() len = %d, want much less than %d", len(got), len(ps))
+	}
+	if got[0].Timestamp != ps[0].Timestamp || got[len(got)-1].Timestamp != ps[len(ps)-1].Timestamp {
+		t.Error("SimplifyDouglasPeucker() should keep first and last points")
+	}
+	if !positionsHaveTimestamp(got, stopStart) {
+		t.Error("SimplifyDouglasPeucker() should keep the first point of a stop")
+	}
+
+	if got := SimplifyDouglasPeucker(ps, SimplifyOptions{Tolerance: 5}); len(got) < 20 {
+		t.Errorf("SimplifyDouglasPeucker() with small tolerance len = %d, want zigzag kept", len(got))
+	}
+}
+
+func TestSimplifyVisvalingam(t *testing.T) {
+	ps := testZigzagTrack()
+	stopStart := Time(SegmentTrips(ps, TripOptions{}).Stops[0].Start)
+
+	got := SimplifyVisvalingam(ps, SimplifyOptions{Tolerance: 200})
+	if len(got) >= len(ps)/4 {
+		t.Errorf("SimplifyVisvalingam() len = %d, want much less than %d", len(got), len(ps))
+	}
+	if got[0].Timestamp != ps[0].Timestamp || got[len(got)-1].Timestamp != ps[len(ps)-1].Timestamp {
+		t.Error("SimplifyVisvalingam() should keep first and last points")
+	}
+	if !positionsHaveTimestamp(got, stopStart) {
+		t.Error("SimplifyVisvalingam() should keep the first point of a stop")
+	}
+	for i := 1; i < len(got); i++ {
+		if got[i].Timestamp.Time().Before(got[i-1].Timestamp.Time()) {
+			t.Fatal("SimplifyVisvalingam() result is not chronological")
+		}
+	}
+}
+
+func TestDownsampleByTime(t *testing.T) {
+	ps := Positions{
+		testPosition(55.0, 37.0, 600, 500),
+		testPosition(55.0, 37.0, 700, 100),
+		testPosition(55.0, 37.0, 1150, 300),
+		testPosition(55.0, 37.0, 1300, 300),
+		testPosition(55.0, 37.0, 2000, 300),
+	}
+	got := DownsampleByTime(ps, 10*time.Minute)
+	if len(got) != 3 {
+		t.Fatalf("DownsampleByTime() len = %d, want 3", len(got))
+	}
+	if got[0].Timestamp.Time().Unix() != 700 || got[1].Timestamp.Time().Unix() != 1300 {
+		t.Errorf("DownsampleByTime() = %v", got)
+	}
+}
+
+func TestDownsampleByTime_unixAligned(t *testing.T) {
+	// Интервалы по 7 минут от начала эпохи Unix: [420, 840), [840, 1260).
+	ps := Positions{
+		testPosition(55.0, 37.0, 420, 100),
+		testPosition(55.0, 37.0, 839, 50),
+		testPosition(55.0, 37.0, 840, 300),
+		testPosition(55.0, 37.0, 1259, 200),
+	}
+	got := DownsampleByTime(ps, 7*time.Minute)
+	if len(got) != 2 || got[0].Timestamp.Time().Unix() != 839 || got[1].Timestamp.Time().Unix() != 1259 {
+		t.Errorf("DownsampleByTime() = %v", got)
+	}
+}
+
+func positionsHaveTimestamp(ps Positions, ts Time) bool {
+	for _, p := range ps {
+		if p.Timestamp == ts {
+			return true
+		}
+	}
+	return false
+}
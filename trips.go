@@ -0,0 +1,285 @@
+package movizor
+
+import (
+	"sort"
+	"time"
+)
+
+const (
+	// DefaultStopRadius - радиус стоянки по умолчанию (м).
+	DefaultStopRadius = 300.0
+	// DefaultMinStopDuration - минимальная продолжительность стоянки по умолчанию.
+	DefaultMinStopDuration = 10 * time.Minute
+	// DefaultMinTripDistance - минимальная длина поездки по умолчанию (м).
+	DefaultMinTripDistance = 500.0
+)
+
+// TripOptions предоставляет настройки разбиения трека на поездки и стоянки.
+type TripOptions struct {
+	StopRadius      float64       // Радиус (м), в пределах которого точки считаются стоянкой. 0 - DefaultStopRadius
+	MinStopDuration time.Duration // Минимальная продолжительность стоянки. 0 - DefaultMinStopDuration
+	MinTripDistance float64       // Поездки короче (м) считаются частью стоянки. 0 - DefaultMinTripDistance
+	// ParkingEvents - события OnParkingEvent и OffParkingEvent этого же объекта (например из GetEvents).
+	// Точки, попадающие между постановкой на парковку и началом движения, считаются стоянкой
+	// независимо от расстояния и продолжительности. Если события не переданы, используется
+	// признак Position.OnParking точек, где сервис его вернул.
+	ParkingEvents ObjectEvents
+}
+
+func (o TripOptions) withDefaults() TripOptions {
+	if o.StopRadius <= 0 {
+		o.StopRadius = DefaultStopRadius
+	}
+	if o.MinStopDuration <= 0 {
+		o.MinStopDuration = DefaultMinStopDuration
+	}
+	if o.MinTripDistance <= 0 {
+		o.MinTripDistance = DefaultMinTripDistance
+	}
+	return o
+}
+
+// Trip представляет собой поездку - движение объекта между двумя стоянками.
+type Trip struct {
+	Start        Position      // Точка начала поездки
+	End          Position      // Точка окончания поездки
+	Distance     float64       // Пройденное расстояние (м) по прямым между точками
+	Duration     time.Duration // Продолжительность поездки
+	AverageSpeed float64       // Средняя скорость (км/ч)
+	Positions    Positions     // Точки поездки, включая точки начала и окончания
+}
+
+// StartPlace возвращает населенный пункт начала поездки.
+func (t Trip) StartPlace() string {
+	return t.Start.Place
+}
+
+// EndPlace возвращает населенный пункт окончания поездки.
+func (t Trip) EndPlace() string {
+	return t.End.Place
+}
+
+// Stop представляет собой стоянку объекта.
+type Stop struct {
+	Coordinates               // Центр стоянки
+	Start       time.Time     // Время первой точки на стоянке
+	End         time.Time     // Время последней точки на стоянке
+	Duration    time.Duration // Продолжительность стоянки
+	Place       string        // Населенный пункт стоянки
+	Parking     bool          // Стоянка подтверждена событием парковки МоВизора
+	Positions   Positions     // Точки стоянки
+}
+
+// TripReport содержит результат разбиения трека на поездки и стоянки.
+type TripReport struct {
+	Trips       []Trip        // Поездки в хронологическом порядке
+	Stops       []Stop        // Стоянки в хронологическом порядке
+	Distance    float64       // Общее пройденное расстояние (м)
+	MovingTime  time.Duration // Общее время в движении
+	StoppedTime time.Duration // Общее время на стоянках
+}
+
+// DailyMileage содержит пробег объекта за сутки.
+type DailyMileage struct {
+	Date       time.Time     // Начало суток
+	Distance   float64       // Пройденное расстояние (м)
+	Trips      int           // Количество поездок
+	MovingTime time.Duration // Время в движении
+}
+
+// DailyMileage возвращает пробег по суткам в указанном часовом поясе. Поездка
+// целиком относится к суткам, в которые она началась.
+func (r TripReport) DailyMileage(loc *time.Location) []DailyMileage {
+	if loc == nil {
+		loc = time.Local
+	}
+
+	var days []DailyMileage
+	for _, t := range r.Trips {
+		st := t.Start.Timestamp.Time().In(loc)
+		date := time.Date(st.Year(), st.Month(), st.Day(), 0, 0, 0, 0, loc)
+		if len(days) == 0 || !days[len(days)-1].Date.Equal(date) {
+			days = append(days, DailyMileage{Date: date})
+		}
+		d := &days[len(days)-1]
+		d.Distance += t.Distance
+		d.Trips++
+		d.MovingTime += t.Duration
+	}
+	return days
+}
+
+// SegmentTrips разбивает трек объекта на поездки и стоянки.
+// Стоянкой считается группа последовательных точек в пределах StopRadius (с учетом радиуса
+// погрешности) продолжительностью не менее MinStopDuration, либо интервал парковки
+// по событиям ParkingEvents или признаку OnParking точек. Остальные точки образуют поездки.
+func SegmentTrips(ps Positions, opts TripOptions) TripReport {
+	opts = opts.withDefaults()
+	ps = ps.Chronological()
+	if len(ps) == 0 {
+		return TripReport{}
+	}
+
+	stopped := make([]bool, len(ps))
+	parking := parkingMask(ps, opts.ParkingEvents)
+
+	// Кластеры точек, остающихся рядом с первой точкой кластера.
+	for i := 0; i < len(ps); {
+		j := i + 1
+		for j < len(ps) {
+			radius := opts.StopRadius
+			if dev := ps[j].Deviation; dev != nil && float64(*dev) > radius {
+				radius = float64(*dev)
+			}
+			if Distance(ps[i].Coordinates, ps[j].Coordinates) > radius {
+				break
+			}
+			j++
+		}
+		if ps[j-1].Timestamp.Time().Sub(ps[i].Timestamp.Time()) >= opts.MinStopDuration {
+			for k := i; k < j; k++ {
+				stopped[k] = true
+			}
+			i = j
+			continue
+		}
+		i++
+	}
+	for k := range stopped {
+		stopped[k] = stopped[k] || parking[k]
+	}
+
+	// Короткие поездки между стоянками считаются дрожанием координат на стоянке.
+	for _, r := range positionRuns(stopped) {
+		if stopped[r.from] || r.from == 0 || r.to == len(ps)-1 {
+			continue
+		}
+		if trackDistance(ps[r.from-1:r.to+2]) < opts.MinTripDistance {
+			for k := r.from; k <= r.to; k++ {
+				stopped[k] = true
+			}
+		}
+	}
+
+	report := TripReport{}
+	for _, r := range positionRuns(stopped) {
+		if stopped[r.from] {
+			s := newStop(ps[r.from : r.to+1])
+			for k := r.from; k <= r.to; k++ {
+				s.Parking = s.Parking || parking[k]
+			}
+			report.Stops = append(report.Stops, s)
+			report.StoppedTime += s.Duration
+			continue
+		}
+
+		from, to := r.from, r.to
+		if from > 0 {
+			from--
+		}
+		if to < len(ps)-1 {
+			to++
+		}
+		t := newTrip(ps[from : to+1])
+		report.Trips = append(report.Trips, t)
+		report.Distance += t.Distance
+		report.MovingTime += t.Duration
+	}
+
+	return report
+}
+
+// positionRun - интервал индексов [from, to] с одинаковым признаком стоянки.
+type positionRun struct {
+	from, to int
+}
+
+func positionRuns(stopped []bool) []positionRun {
+	var runs []positionRun
+	for i := 0; i < len(stopped); {
+		j := i
+		for j+1 < len(stopped) && stopped[j+1] == stopped[i] {
+			j++
+		}
+		runs = append(runs, positionRun{from: i, to: j})
+		i = j + 1
+	}
+	return runs
+}
+
+// parkingMask отмечает точки, попадающие в интервалы парковки по событиям,
+// а без событий - точки с признаком OnParking.
+func parkingMask(ps Positions, events ObjectEvents) []bool {
+	mask := make([]bool, len(ps))
+	if len(events) == 0 {
+		for i, p := range ps {
+			mask[i] = p.OnParking != nil && *p.OnParking
+		}
+		return mask
+	}
+
+	evs := make(ObjectEvents, 0, len(events))
+	for _, e := range events {
+		if e.Event == OnParkingEvent || e.Event == OffParkingEvent {
+			evs = append(evs, e)
+		}
+	}
+	sort.Slice(evs, func(i, j int) bool { return evs[i].Timestamp.Time().Before(evs[j].Timestamp.Time()) })
+
+	for i, p := range ps {
+		ts := p.Timestamp.Time()
+		onParking := false
+		for _, e := range evs {
+			if e.Timestamp.Time().After(ts) {
+				break
+			}
+			onParking = e.Event == OnParkingEvent
+		}
+		mask[i] = onParking
+	}
+	return mask
+}
+
+// trackDistance возвращает длину ломаной по точкам (м).
+func trackDistance(ps Positions) float64 {
+	var d float64
+	for i := 1; i < len(ps); i++ {
+		d += Distance(ps[i-1].Coordinates, ps[i].Coordinates)
+	}
+	return d
+}
+
+func newTrip(ps Positions) Trip {
+	t := Trip{
+		Start:     ps[0],
+		End:       ps[len(ps)-1],
+		Distance:  trackDistance(ps),
+		Positions: ps,
+	}
+	t.Duration = t.End.Timestamp.Time().Sub(t.Start.Timestamp.Time())
+	if t.Duration > 0 {
+		t.AverageSpeed = t.Distance / 1000 / t.Duration.Hours()
+	}
+	return t
+}
+
+func newStop(ps Positions) Stop {
+	s := Stop{
+		Start:     ps[0].Timestamp.Time(),
+		End:       ps[len(ps)-1].Timestamp.Time(),
+		Positions: ps,
+	}
+	s.Duration = s.End.Sub(s.Start)
+
+	var lat, lon float64
+	for _, p := range ps {
+		lat += p.Lat.Float64()
+		lon += p.Lon.Float64()
+		if s.Place == "" {
+			s.Place = p.Place
+		}
+	}
+	s.Lat = Coordinate(lat / float64(len(ps)))
+	s.Lon = Coordinate(lon / float64(len(ps)))
+	return s
+}
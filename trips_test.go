@@ -0,0 +1,119 @@
+package movizor
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// testTripTrack возвращает трек: стоянка 30 мин, поездка ~11 км на север за 20 мин,
+// стоянка 30 мин. Точки в обратном порядке, как их отдает МоВизор.
+func testTripTrack() Positions {
+	var ps Positions
+	ts := int64(1548061200) // 21.01.2019 12:00 MSK
+	add := func(lat float64, place string) {
+		p := testPosition(lat, 37.0, ts, 100)
+		p.Place = place
+		ps = append(Positions{p}, ps...)
+		ts += 300
+	}
+	for i := 0; i < 7; i++ {
+		add(55.0+float64(i%2)*0.0005, "Москва")
+	}
+	for i := 1; i <= 3; i++ {
+		add(55.0+float64(i)*0.025, "")
+	}
+	for i := 0; i < 7; i++ {
+		add(55.1+float64(i%2)*0.0005, "Химки")
+	}
+	return ps
+}
+
+func TestSegmentTrips(t *testing.T) {
+	r := SegmentTrips(testTripTrack(), TripOptions{})
+	if len(r.Stops) != 2 || len(r.Trips) != 1 {
+		t.Fatalf("SegmentTrips() stops = %d, trips = %d, want 2 and 1", len(r.Stops), len(r.Trips))
+	}
+
+	trip := r.Trips[0]
+	if math.Abs(trip.Distance-11120) > 100 {
+		t.Errorf("Trip.Distance = %v, want about 11.1 km", trip.Distance)
+	}
+	if trip.Duration != 20*time.Minute {
+		t.Errorf("Trip.Duration = %v, want 20m", trip.Duration)
+	}
+	if math.Abs(trip.AverageSpeed-33.4) > 0.5 {
+		t.Errorf("Trip.AverageSpeed = %v, want about 33.4 km/h", trip.AverageSpeed)
+	}
+	if trip.StartPlace() != "Москва" || trip.EndPlace() != "Химки" {
+		t.Errorf("Trip places = %s -> %s", trip.StartPlace(), trip.EndPlace())
+	}
+	if r.Stops[0].Duration != 30*time.Minute || r.Stops[1].Place != "Химки" {
+		t.Errorf("SegmentTrips() stops = %+v", r.Stops)
+	}
+	if r.Stops[0].Parking {
+		t.Error("Stop.Parking should be false without parking events")
+	}
+
+	days := r.DailyMileage(time.UTC)
+	if len(days) != 1 || days[0].Trips != 1 || days[0].Distance != trip.Distance {
+		t.Errorf("TripReport.DailyMileage() = %+v", days)
+	}
+}
+
+func TestSegmentTrips_ParkingEvents(t *testing.T) {
+	ps := testTripTrack()
+	first := ps.Chronological()[0].Timestamp.Time()
+	events := ObjectEvents{
+		{Event: OffParkingEvent, Timestamp: Time(first.Add(35 * time.Minute))},
+		{Event: OnParkingEvent, Timestamp: Time(first.Add(40 * time.Minute))},
+		{Event: RequestOkEvent, Timestamp: Time(first.Add(41 * time.Minute))},
+		{Event: OnParkingEvent, Timestamp: Time(first)},
+	}
+
+	r := SegmentTrips(ps, TripOptions{ParkingEvents: events, MinStopDuration: 2 * time.Hour})
+	if len(r.Stops) != 2 || len(r.Trips) != 1 {
+		t.Fatalf("SegmentTrips() stops = %d, trips = %d, want 2 and 1", len(r.Stops), len(r.Trips))
+	}
+	if !r.Stops[0].Parking || !r.Stops[1].Parking {
+		t.Errorf("SegmentTrips() stops should be confirmed by parking events: %+v", r.Stops)
+	}
+	if r.Trips[0].Duration != 10*time.Minute {
+		t.Errorf("Trip.Duration = %v, want 10m", r.Trips[0].Duration)
+	}
+}
+
+func TestSegmentTrips_OnParking(t *testing.T) {
+	ps := testTripTrack().Chronological()
+	first := ps[0].Timestamp.Time()
+	for i := range ps {
+		// Как в TestSegmentTrips_ParkingEvents: движение с 35 до 40 минуты.
+		parked := ps[i].Timestamp.Time().Sub(first) != 35*time.Minute
+		ps[i].OnParking = &parked
+	}
+
+	r := SegmentTrips(ps, TripOptions{MinStopDuration: 2 * time.Hour})
+	if len(r.Stops) != 2 || len(r.Trips) != 1 {
+		t.Fatalf("SegmentTrips() stops = %d, trips = %d, want 2 and 1", len(r.Stops), len(r.Trips))
+	}
+	if !r.Stops[0].Parking || !r.Stops[1].Parking {
+		t.Errorf("SegmentTrips() stops should be confirmed by OnParking: %+v", r.Stops)
+	}
+	if r.Trips[0].Duration != 10*time.Minute {
+		t.Errorf("Trip.Duration = %v, want 10m", r.Trips[0].Duration)
+	}
+
+	// События парковки имеют приоритет над признаком точек.
+	r = SegmentTrips(ps, TripOptions{MinStopDuration: 2 * time.Hour,
+		ParkingEvents: ObjectEvents{{Event: OffParkingEvent, Timestamp: Time(first)}}})
+	if len(r.Stops) != 0 {
+		t.Errorf("SegmentTrips() with parking events stops = %+v, want none", r.Stops)
+	}
+}
+
+func TestSegmentTrips_Empty(t *testing.T) {
+	r := SegmentTrips(nil, TripOptions{})
+	if len(r.Trips) != 0 || len(r.Stops) != 0 {
+		t.Errorf("SegmentTrips() = %+v, want empty", r)
+	}
+}
@@ -2,8 +2,12 @@ package movizor
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/url"
+	"sort"
 	"strconv"
+	"strings"
+	"time"
 )
 
 // APIResponse представляет собой ответ от сервиса с описанием типа
@@ -99,8 +103,16 @@ func (b *Balance) UnmarshalJSON(data []byte) (err error) {
 // CurrentCoordinates представляют собой текущие гео-координаты объекта.
 // Допускается null значения.
 type CurrentCoordinates struct {
-	CurrentLon *Coordinate `json:"current_lon"` // Широта последнего местоположения
-	CurrentLat *Coordinate `json:"current_lat"` // Долгота последнего местоположения
+	CurrentLon *Coordinate `json:"current_lon"` // Долгота последнего местоположения
+	CurrentLat *Coordinate `json:"current_lat"` // Широта последнего местоположения
+}
+
+// Validate проверяет допустимость текущих гео-координат, если они заданы.
+func (cc CurrentCoordinates) Validate() error {
+	if cc.CurrentLat == nil || cc.CurrentLon == nil {
+		return nil
+	}
+	return Coordinates{Lat: *cc.CurrentLat, Lon: *cc.CurrentLon}.Validate()
 }
 
 // ObjectInfo содержит почти полную информацию по объекту, включая опции,
@@ -123,12 +135,31 @@ type ObjectInfo struct {
 	TimestampOff Time              `json:"timestamp_off"`          // Время автоматического отключения от мониторинга
 	TimestampAdd Time              `json:"timestamp_add"`          // Время добавления объекта в Мовизор
 	Metadata     map[string]string `json:"metadata,omitempty"`     // Метаинформация объекта, массив
+
+	Tags           []string           `json:"tags,omitempty"`     // Список меток объекта
+	PackageProlong bool               `json:"package_prolong"`    // Автоматически продлевать пакет
+	CallToDriver   bool               `json:"autoinform"`         // Включена услуга автоинформатора
+	Schedule       *SchedulingOptions `json:"schedule,omitempty"` // sw1..sw7, st - Расписание запросов, nil если не задано
 }
 
 func (oi *ObjectInfo) UnmarshalJSON(data []byte) (err error) {
 	type Alias ObjectInfo
 	aux := &struct {
-		Metadata json.RawMessage `json:"metadata,omitempty"`
+		Metadata       json.RawMessage `json:"metadata,omitempty"`
+		Tags           json.RawMessage `json:"tags,omitempty"`
+		PackageProlong jsonFlag        `json:"package_prolong"`
+		CallToDriver   jsonFlag        `json:"autoinform"`
+		Sw1            jsonFlag        `json:"sw1"`
+		Sw2            jsonFlag        `json:"sw2"`
+		Sw3            jsonFlag        `json:"sw3"`
+		Sw4            jsonFlag        `json:"sw4"`
+		Sw5            jsonFlag        `json:"sw5"`
+		Sw6            jsonFlag        `json:"sw6"`
+		Sw7            jsonFlag        `json:"sw7"`
+		St             json.RawMessage `json:"st,omitempty"`
+		// Расписание в формате SchedulingOptions.MarshalJSON, если ObjectInfo
+		// разбирается после повторной сериализации.
+		Schedule *SchedulingOptions `json:"schedule,omitempty"`
 		*Alias
 	}{
 		Alias: (*Alias)(oi),
@@ -138,6 +169,43 @@ func (oi *ObjectInfo) UnmarshalJSON(data []byte) (err error) {
 		return err
 	}
 
+	if err = oi.CurrentCoordinates.Validate(); err != nil {
+		return err
+	}
+
+	oi.Tariff = oi.Tariff.Normalize()
+	if oi.TariffNew != nil {
+		tn := oi.TariffNew.Normalize()
+		oi.TariffNew = &tn
+	}
+	oi.PackageProlong = bool(aux.PackageProlong)
+	oi.CallToDriver = bool(aux.CallToDriver)
+	if oi.Tags, err = decodeStringList(aux.Tags); err != nil {
+		return fmt.Errorf("invalid tags: %s", err)
+	}
+
+	times, err := decodeStringList(aux.St)
+	if err != nil {
+		return fmt.Errorf("invalid schedule times: %s", err)
+	}
+	weekdays := [7]bool{bool(aux.Sw1), bool(aux.Sw2), bool(aux.Sw3), bool(aux.Sw4),
+		bool(aux.Sw5), bool(aux.Sw6), bool(aux.Sw7)}
+	// Дни недели без времени не задают расписание.
+	oi.Schedule = aux.Schedule
+	if len(times) > 0 {
+		oi.Schedule = &SchedulingOptions{weekdays: weekdays}
+		for _, st := range times {
+			m, err := parseScheduleMinute(st)
+			if err != nil {
+				return err
+			}
+			oi.Schedule.FireAt = append(oi.Schedule.FireAt, time.Date(0, 1, 1, m/60, m%60, 0, 0, MoscowLocation))
+		}
+	}
+
+	if len(aux.Metadata) == 0 {
+		return nil
+	}
 	var probe []interface{}
 	if err = json.Unmarshal(aux.Metadata, &probe); err == nil {
 		return nil
@@ -146,19 +214,186 @@ func (oi *ObjectInfo) UnmarshalJSON(data []byte) (err error) {
 	return json.Unmarshal(aux.Metadata, &oi.Metadata)
 }
 
+// localize переводит локальное время из ответа сервиса (время прибытия в точки назначения
+// и время расписания), которое UnmarshalJSON разбирает в MoscowLocation, в часовой пояс
+// сервиса loc (API.Location).
+func (oi *ObjectInfo) localize(loc *time.Location) {
+	loc = serviceLocation(loc)
+	for i := range oi.Destination {
+		oi.Destination[i].localize(loc)
+	}
+	if oi.Schedule != nil {
+		for i, t := range oi.Schedule.FireAt {
+			oi.Schedule.FireAt[i] = time.Date(0, 1, 1, t.Hour(), t.Minute(), 0, 0, loc)
+		}
+	}
+}
+
+// Options создает опции объекта, эквивалентные текущим настройкам мониторинга,
+// для повторного добавления объекта через AddObject или AddObjectToSlave.
+// Если запланирована смена тарифа (TariffNew), используется новый тариф.
+// Дата отключения переносится, только если она еще не наступила. Расписание без времени
+// или дней недели не переносится: сервис его не примет.
+func (oi ObjectInfo) Options() ObjectOptions {
+	oo := ObjectOptions{
+		Title:          oi.Title,
+		Tariff:         oi.Tariff.Normalize(),
+		PackageProlong: oi.PackageProlong,
+		CallToDriver:   oi.CallToDriver,
+	}
+	if oi.TariffNew != nil && *oi.TariffNew != "" {
+		oo.Tariff = oi.TariffNew.Normalize()
+	}
+	if off := oi.TimestampOff.Time(); off.Unix() > 0 && off.After(time.Now()) {
+		oo.DateOff = off
+	}
+	if len(oi.Tags) > 0 {
+		oo.Tags = append([]string(nil), oi.Tags...)
+	}
+	for _, d := range oi.Destination {
+		oo.Destinations = append(oo.Destinations, d.Options())
+	}
+	if oi.Schedule != nil && len(oi.Schedule.FireAt) > 0 && len(oi.Schedule.Weekdays()) > 0 {
+		s := *oi.Schedule
+		s.FireAt = append([]time.Time(nil), oi.Schedule.FireAt...)
+		oo.Schedules = &s
+	}
+	if len(oi.Metadata) > 0 {
+		oo.Metadata = make(map[string]string, len(oi.Metadata))
+		for k, v := range oi.Metadata {
+			oo.Metadata[k] = v
+		}
+	}
+	return oo
+}
+
+// decodeStringList разбирает список строк, который сервис возвращает массивом,
+// вложенными массивами (как передается st[]) или строкой через запятую.
+func decodeStringList(data json.RawMessage) ([]string, error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, err
+	}
+
+	var list []string
+	var walk func(v interface{}) error
+	walk = func(v interface{}) error {
+		switch val := v.(type) {
+		case nil:
+		case string:
+			for _, item := range strings.Split(val, ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					list = append(list, item)
+				}
+			}
+		case []interface{}:
+			for _, item := range val {
+				if err := walk(item); err != nil {
+					return err
+				}
+			}
+		default:
+			return fmt.Errorf("unexpected value %v", val)
+		}
+		return nil
+	}
+	if err := walk(v); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
 // Coordinates представляет собой структуру гео-координат.
 type Coordinates struct {
-	Lat Coordinate `json:"lat"` // Долгота
-	Lon Coordinate `json:"lon"` // Широта
+	Lat Coordinate `json:"lat"` // Широта
+	Lon Coordinate `json:"lon"` // Долгота
+}
+
+// NewCoordinates создает гео-координаты с проверкой допустимых значений.
+func NewCoordinates(lat, lon float64) (Coordinates, error) {
+	c := Coordinates{Lat: Coordinate(lat), Lon: Coordinate(lon)}
+	if err := c.Validate(); err != nil {
+		return Coordinates{}, err
+	}
+	return c, nil
+}
+
+// Validate проверяет, что широта находится в диапазоне [-90, 90],
+// а долгота в диапазоне [-180, 180].
+func (c Coordinates) Validate() error {
+	if !c.Lat.IsValidLat() {
+		return fmt.Errorf("latitude %s is out of range [-90, 90]", c.Lat)
+	}
+	if !c.Lon.IsValidLon() {
+		return fmt.Errorf("longitude %s is out of range [-180, 180]", c.Lon)
+	}
+	return nil
 }
 
 // Destination представляю собой структуру описания точки назначения,
 // в которую следует объект.
 type Destination struct {
-	Text string `json:"text"`
+	Text string `json:"text"` // Адрес точки назначения
 	Coordinates
-	Time   string    `json:"time"`
-	Status ETAStatus `json:"status"`
+	Time   time.Time `json:"time"`   // Ожидаемое время прибытия (в часовом поясе сервиса), нулевое если не задано или не разобрано
+	Status ETAStatus `json:"status"` // Статус прибытия в точку назначения
+	// RawTime - время прибытия в том виде, в котором его вернул сервис. Если разобрать его
+	// не удалось, Time остается нулевым, а ObjectInfo разбирается без ошибки.
+	RawTime string `json:"-"`
+}
+
+func (d *Destination) UnmarshalJSON(data []byte) (err error) {
+	type Alias Destination
+	aux := &struct {
+		Time json.RawMessage `json:"time"`
+		*Alias
+	}{
+		Alias: (*Alias)(d),
+	}
+	if err = json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+
+	d.RawTime = ""
+	if len(aux.Time) > 0 && string(aux.Time) != "null" {
+		var num json.Number
+		var s string
+		switch {
+		case json.Unmarshal(aux.Time, &num) == nil:
+			d.RawTime = string(num)
+		case json.Unmarshal(aux.Time, &s) == nil:
+			// Не число - значит строка с датой.
+			d.RawTime = s
+		default:
+			d.RawTime = string(aux.Time)
+		}
+	}
+	// Неразобранное время не должно делать недоступной всю информацию об объекте.
+	// Время разбирается в MoscowLocation, API.GetObjectInfo переводит его в API.Location.
+	d.Time, _ = parseDestinationTime(d.RawTime, MoscowLocation)
+
+	return d.Coordinates.Validate()
+}
+
+// localize разбирает время прибытия в часовом поясе сервиса loc.
+func (d *Destination) localize(loc *time.Location) {
+	d.Time, _ = parseDestinationTime(d.RawTime, loc)
+}
+
+// Options создает опции точки назначения для передачи в AddObject или EditObject.
+// Время прибытия передается с точностью до минуты, поэтому Destination, полученный
+// обратно от сервиса, будет содержать то же время.
+func (d Destination) Options() DestinationOptions {
+	return DestinationOptions{
+		Text:         d.Text,
+		Lat:          d.Lat,
+		Lon:          d.Lon,
+		ExpectedTime: d.Time,
+	}
 }
 
 // ObjectStatus представляет собой текущий статус объекта трекинга.
@@ -220,15 +455,38 @@ type CoordinatesAttributes struct {
 // Positions является списком местоположений.
 type Positions []Position
 
+func (ps Positions) Len() int           { return len(ps) }
+func (ps Positions) Swap(i, j int)      { ps[i], ps[j] = ps[j], ps[i] }
+func (ps Positions) Less(i, j int) bool { return ps[i].Timestamp.Time().Before(ps[j].Timestamp.Time()) }
+
+// Chronological возвращает копию списка местоположений, упорядоченную по времени
+// получения координат. МоВизор отдает список начиная с последних записей.
+func (ps Positions) Chronological() Positions {
+	sorted := make(Positions, len(ps))
+	copy(sorted, ps)
+	sort.Stable(sorted)
+	return sorted
+}
+
 // Position содержит информацию о последнем зафиксированном в системе местоположении.
 type Position struct {
 	Coordinates
-	Timestamp        Time `json:"timestamp"`                   // Время получения координат для этой точки
-	TimestampRequest Time `json:"timestamp_request,omitempty"` // Время создания запроса на получение координат
-	Deviation        *Int `json:"radius,omitempty"`            // Радиус погрешности (м)
+	Timestamp        Time  `json:"timestamp"`                   // Время получения координат для этой точки
+	TimestampRequest Time  `json:"timestamp_request,omitempty"` // Время создания запроса на получение координат
+	Deviation        *Int  `json:"radius,omitempty"`            // Радиус погрешности (м)
+	OnParking        *bool `json:"on_parking,omitempty"`        // Объект находится на парковке, nil если не известно
 	CoordinatesAttributes
 }
 
+func (p *Position) UnmarshalJSON(data []byte) (err error) {
+	type Alias Position
+	if err = json.Unmarshal(data, (*Alias)(p)); err != nil {
+		return err
+	}
+
+	return p.Coordinates.Validate()
+}
+
 // ObjectPositions является списком объектов с гео-координатами, последним
 // временем обновления координат, текущим местонахождением и ETA.
 type ObjectPositions []ObjectPosition
@@ -240,6 +498,24 @@ type ObjectPosition struct {
 	Position
 }
 
+// UnmarshalJSON необходим, т.к. иначе будет использован UnmarshalJSON встроенного Position
+// и номер телефона не будет заполнен.
+func (op *ObjectPosition) UnmarshalJSON(data []byte) (err error) {
+	if err = op.Position.UnmarshalJSON(data); err != nil {
+		return err
+	}
+
+	aux := struct {
+		Phone Object `json:"phone"`
+	}{}
+	if err = json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	op.Phone = aux.Phone
+
+	return nil
+}
+
 // PositionRequest хранит ID запроса на опреления гео-координат.
 type PositionRequest struct {
 	RequestID int64 `json:"request_id"`
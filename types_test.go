@@ -1,9 +1,14 @@
 package movizor
 
 import (
+	"encoding/json"
+	"fmt"
 	"io/ioutil"
+	"net/url"
 	"path/filepath"
+	"reflect"
 	"testing"
+	"time"
 )
 
 const dataPath = "./test-data"
@@ -126,6 +131,24 @@ func TestObjectInfo_UnmarshalJSON(t *testing.T) {
 			filename: "object_get3.json",
 			wantErr:  false,
 		},
+		{
+			name:     "object_get4",
+			fields:   fields{},
+			filename: "object_get4.json",
+			wantErr:  false,
+		},
+		{
+			name:     "object_get_empty_time",
+			fields:   fields{},
+			filename: "object_get_empty_time.json",
+			wantErr:  false,
+		},
+		{
+			name:     "object_get_bad_time",
+			fields:   fields{},
+			filename: "object_get_bad_time.json",
+			wantErr:  false,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -392,3 +415,345 @@ func TestSubscribedEvent_UnmarshalJSON(t *testing.T) {
 //		})
 //	}
 //}
+
+func TestPosition_UnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    []byte
+		want    Coordinates
+		wantErr bool
+	}{
+		{
+			name:    "good",
+			data:    []byte(`{"lat": "55.798355", "lon": "37.579491", "timestamp": 1548165275}`),
+			want:    Coordinates{Lat: 55.798355, Lon: 37.579491},
+			wantErr: false,
+		},
+		{
+			name:    "latitude out of range",
+			data:    []byte(`{"lat": "155.798355", "lon": "37.579491", "timestamp": 1548165275}`),
+			wantErr: true,
+		},
+		{
+			name:    "longitude out of range",
+			data:    []byte(`{"lat": "55.798355", "lon": "-237.579491", "timestamp": 1548165275}`),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &Position{}
+			err := p.UnmarshalJSON(tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Position.UnmarshalJSON() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr && p.Coordinates != tt.want {
+				t.Errorf("Position.UnmarshalJSON() = %v, want %v", p.Coordinates, tt.want)
+			}
+		})
+	}
+}
+
+func TestObjectPositions_UnmarshalJSON(t *testing.T) {
+	d, err := ioutil.ReadFile(filepath.Join(dataPath, "pos_objects.json"))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	var op ObjectPositions
+	if err := json.Unmarshal(d, &op); err != nil {
+		t.Fatalf("ObjectPositions unmarshal error = %v", err)
+	}
+	if len(op) != 2 {
+		t.Fatalf("ObjectPositions len = %d, want 2", len(op))
+	}
+	if op[0].Phone != "79630005272" {
+		t.Errorf("ObjectPosition.Phone = %s, want 79630005272", op[0].Phone)
+	}
+	if op[0].Lat != 55.892011 || op[0].Deviation == nil || *op[0].Deviation != 250 {
+		t.Errorf("ObjectPosition.Position is not filled: %+v", op[0].Position)
+	}
+}
+
+func TestDestination_UnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    []byte
+		want    time.Time
+		wantRaw string
+		wantErr bool
+	}{
+		{
+			name: "minutes",
+			data: []byte(`{"lat": "55.2258", "lon": "36.64481", "time": "14.02.2019 21:50"}`),
+			want: time.Date(2019, 2, 14, 21, 50, 0, 0, MoscowLocation),
+		},
+		{
+			name: "seconds",
+			data: []byte(`{"lat": "55.2258", "lon": "36.64481", "time": "14.02.2019 21:50:30"}`),
+			want: time.Date(2019, 2, 14, 21, 50, 30, 0, MoscowLocation),
+		},
+		{
+			name: "date only",
+			data: []byte(`{"lat": "55.2258", "lon": "36.64481", "time": "14.02.2019"}`),
+			want: time.Date(2019, 2, 14, 0, 0, 0, 0, MoscowLocation),
+		},
+		{
+			name: "iso",
+			data: []byte(`{"lat": "55.2258", "lon": "36.64481", "time": "2019-02-14 21:50:00"}`),
+			want: time.Date(2019, 2, 14, 21, 50, 0, 0, MoscowLocation),
+		},
+		{
+			name: "rfc3339",
+			data: []byte(`{"lat": "55.2258", "lon": "36.64481", "time": "2019-02-14T18:50:00Z"}`),
+			want: time.Date(2019, 2, 14, 21, 50, 0, 0, MoscowLocation),
+		},
+		{
+			name: "unix string",
+			data: []byte(`{"lat": "55.2258", "lon": "36.64481", "time": "1550170200"}`),
+			want: time.Date(2019, 2, 14, 21, 50, 0, 0, MoscowLocation),
+		},
+		{
+			name: "unix number",
+			data: []byte(`{"lat": "55.2258", "lon": "36.64481", "time": 1550170200}`),
+			want: time.Date(2019, 2, 14, 21, 50, 0, 0, MoscowLocation),
+		},
+		{
+			name: "empty",
+			data: []byte(`{"lat": "55.2258", "lon": "36.64481", "time": ""}`),
+		},
+		{
+			name: "null",
+			data: []byte(`{"lat": "55.2258", "lon": "36.64481", "time": null}`),
+		},
+		{
+			name: "missing",
+			data: []byte(`{"lat": "55.2258", "lon": "36.64481"}`),
+		},
+		{
+			name:    "malformed",
+			data:    []byte(`{"lat": "55.2258", "lon": "36.64481", "time": "14/02/2019 9pm"}`),
+			wantRaw: "14/02/2019 9pm",
+		},
+		{
+			name:    "wrong type",
+			data:    []byte(`{"lat": "55.2258", "lon": "36.64481", "time": true}`),
+			wantRaw: "true",
+		},
+		{
+			name:    "invalid coordinates",
+			data:    []byte(`{"lat": "95.2258", "lon": "36.64481", "time": "14.02.2019 21:50"}`),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := &Destination{}
+			err := d.UnmarshalJSON(tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Destination.UnmarshalJSON() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr && !d.Time.Equal(tt.want) {
+				t.Errorf("Destination.Time = %v, want %v", d.Time, tt.want)
+			}
+			if tt.wantRaw != "" && d.RawTime != tt.wantRaw {
+				t.Errorf("Destination.RawTime = %q, want %q", d.RawTime, tt.wantRaw)
+			}
+		})
+	}
+}
+
+func TestDestination_Options(t *testing.T) {
+	d, err := ioutil.ReadFile(filepath.Join(dataPath, "object_get3.json"))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	var oi ObjectInfo
+	if err := json.Unmarshal(d, &oi); err != nil {
+		t.Fatalf("ObjectInfo unmarshal error = %v", err)
+	}
+
+	for _, dest := range oi.Destination {
+		v := url.Values{}
+		if err := dest.Options().addValuesTo(0, &v, nil); err != nil {
+			t.Fatalf("DestinationOptions.addValuesTo() error = %v", err)
+		}
+
+		// Сервис возвращает точку назначения в том же формате, в котором она была передана.
+		back := &Destination{}
+		data := fmt.Sprintf(`{"text": %q, "lat": "%s", "lon": "%s", "time": %q}`,
+			v.Get("destination[0][text]"), dest.Lat, dest.Lon, v.Get("destination[0][time]"))
+		if err := back.UnmarshalJSON([]byte(data)); err != nil {
+			t.Fatalf("Destination.UnmarshalJSON() error = %v", err)
+		}
+		if back.Text != dest.Text || back.Coordinates != dest.Coordinates || !back.Time.Equal(dest.Time) {
+			t.Errorf("round trip = %+v, want %+v", *back, dest)
+		}
+	}
+}
+
+func TestObjectInfo_UnmarshalJSON_options(t *testing.T) {
+	tests := []struct {
+		filename     string
+		wantTariff   TariffType
+		wantTags     []string
+		wantProlong  bool
+		wantInform   bool
+		wantSchedule string
+	}{
+		{
+			filename:   "object_get1.json",
+			wantTariff: TariffManual,
+		},
+		{
+			filename:     "object_get4.json",
+			wantTariff:   TariffManual,
+			wantTags:     []string{"рейс", "москва"},
+			wantProlong:  true,
+			wantInform:   false,
+			wantSchedule: "Mon-Fri 08:00,12:30,18:00",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.filename, func(t *testing.T) {
+			d, err := ioutil.ReadFile(filepath.Join(dataPath, tt.filename))
+			if err != nil {
+				t.Fatalf("err: %s", err)
+			}
+			var oi ObjectInfo
+			if err := json.Unmarshal(d, &oi); err != nil {
+				t.Fatalf("ObjectInfo unmarshal error = %v", err)
+			}
+			// "manual" из ответа object_get приводится к TariffManual.
+			if oi.Tariff != tt.wantTariff || oi.TariffNew == nil || *oi.TariffNew != TariffEvery15 {
+				t.Errorf("ObjectInfo.Tariff = %q, TariffNew = %v, want %q", oi.Tariff, oi.TariffNew, tt.wantTariff)
+			}
+			if !reflect.DeepEqual(oi.Tags, tt.wantTags) {
+				t.Errorf("ObjectInfo.Tags = %v, want %v", oi.Tags, tt.wantTags)
+			}
+			if oi.PackageProlong != tt.wantProlong {
+				t.Errorf("ObjectInfo.PackageProlong = %v, want %v", oi.PackageProlong, tt.wantProlong)
+			}
+			if oi.CallToDriver != tt.wantInform {
+				t.Errorf("ObjectInfo.CallToDriver = %v, want %v", oi.CallToDriver, tt.wantInform)
+			}
+			if got := oi.Schedule.String(); got != tt.wantSchedule {
+				t.Errorf("ObjectInfo.Schedule = %q, want %q", got, tt.wantSchedule)
+			}
+		})
+	}
+}
+
+func TestObjectInfo_UnmarshalJSON_weekdaysWithoutTimes(t *testing.T) {
+	var oi ObjectInfo
+	if err := json.Unmarshal([]byte(`{"phone":"79630005272","sw1":"1","sw2":"1"}`), &oi); err != nil {
+		t.Fatalf("ObjectInfo unmarshal error = %v", err)
+	}
+	if oi.Schedule != nil {
+		t.Errorf("ObjectInfo.Schedule = %v, want nil without st", oi.Schedule)
+	}
+}
+
+func TestObjectInfo_MarshalJSON(t *testing.T) {
+	d, err := ioutil.ReadFile(filepath.Join(dataPath, "object_get4.json"))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	var oi ObjectInfo
+	if err := json.Unmarshal(d, &oi); err != nil {
+		t.Fatalf("ObjectInfo unmarshal error = %v", err)
+	}
+	oi.localize(time.UTC)
+
+	data, err := json.Marshal(oi)
+	if err != nil {
+		t.Fatalf("ObjectInfo marshal error = %v", err)
+	}
+	var back ObjectInfo
+	if err := json.Unmarshal(data, &back); err != nil {
+		t.Fatalf("ObjectInfo unmarshal error = %v, data %s", err, data)
+	}
+	if !reflect.DeepEqual(back.Tags, oi.Tags) || back.PackageProlong != oi.PackageProlong || back.CallToDriver != oi.CallToDriver {
+		t.Errorf("round trip = %+v, want %+v", back, oi)
+	}
+	if back.Schedule.String() != oi.Schedule.String() || back.Schedule.FireAt[0].Location().String() != "UTC" {
+		t.Errorf("round trip Schedule = %v, want %v", back.Schedule, oi.Schedule)
+	}
+}
+
+func TestObjectInfo_Options(t *testing.T) {
+	d, err := ioutil.ReadFile(filepath.Join(dataPath, "object_get3.json"))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	var oi ObjectInfo
+	if err := json.Unmarshal(d, &oi); err != nil {
+		t.Fatalf("ObjectInfo unmarshal error = %v", err)
+	}
+	next := TariffEvery60
+	oi.TariffNew = &next
+	oi.Tags = []string{"рейс"}
+	oi.Schedule = MustParseSchedule("Mon-Fri 08:00,18:00", nil)
+
+	oo := oi.Options()
+	if oo.Title != oi.Title || oo.Tariff != TariffEvery60 {
+		t.Errorf("ObjectOptions = %+v", oo)
+	}
+	if !oo.DateOff.IsZero() {
+		t.Errorf("ObjectOptions.DateOff = %v, want zero for past timestamp_off", oo.DateOff)
+	}
+	if !reflect.DeepEqual(oo.Metadata, oi.Metadata) {
+		t.Errorf("ObjectOptions.Metadata = %v, want %v", oo.Metadata, oi.Metadata)
+	}
+	if len(oo.Destinations) != len(oi.Destination) {
+		t.Fatalf("ObjectOptions.Destinations len = %d, want %d", len(oo.Destinations), len(oi.Destination))
+	}
+	for i, do := range oo.Destinations {
+		if do != oi.Destination[i].Options() {
+			t.Errorf("ObjectOptions.Destinations[%d] = %+v", i, do)
+		}
+	}
+	if oo.Schedules.String() != oi.Schedule.String() {
+		t.Errorf("ObjectOptions.Schedules = %s, want %s", oo.Schedules, oi.Schedule)
+	}
+
+	// Опции не должны разделять данные с исходным объектом.
+	oo.Metadata["ТС"] = "changed"
+	oo.Tags[0] = "changed"
+	oo.Schedules.FireAt[0] = time.Time{}
+	if oi.Metadata["ТС"] == "changed" || oi.Tags[0] == "changed" || oi.Schedule.FireAt[0].IsZero() {
+		t.Error("ObjectInfo.Options() shares data with ObjectInfo")
+	}
+
+	v := url.Values{}
+	if err := oo.addValuesTo(&v, nil); err != nil {
+		t.Fatalf("ObjectOptions.addValuesTo() error = %v", err)
+	}
+	if v.Get("tariff") != "60" || v.Get("destination[2][time]") != "20.02.2019 12:00" || v.Get("sw1") != "1" {
+		t.Errorf("ObjectOptions.addValuesTo() = %v", v)
+	}
+}
+
+func TestAPI_GetObjectInfo_location(t *testing.T) {
+	api := poolTestAPI(t, "main", map[string]string{"object_get": "object_get4.json"}, nil)
+	api.Location = time.UTC
+
+	oi, err := api.GetObjectInfo("79630005272")
+	if err != nil {
+		t.Fatalf("GetObjectInfo() error = %v", err)
+	}
+	// Время из ответа сервиса - в часовом поясе API.Location.
+	if want := time.Date(2018, 11, 22, 22, 0, 0, 0, time.UTC); !oi.Destination[0].Time.Equal(want) {
+		t.Errorf("Destination.Time = %v, want %v", oi.Destination[0].Time, want)
+	}
+	if oi.Schedule == nil || oi.Schedule.FireAt[0].Location() != time.UTC || oi.Schedule.String() != "Mon-Fri 08:00,12:30,18:00" {
+		t.Errorf("Schedule = %v", oi.Schedule)
+	}
+	// Расписание возвращается в сервис без изменений.
+	v := url.Values{}
+	if err := oi.Schedule.addValuesTo(&v, api.Location); err != nil || v.Get("st[]") != "08:00" || v.Get("sw1") != "1" {
+		t.Errorf("Schedule.addValuesTo() = %v, %v", v, err)
+	}
+}